@@ -0,0 +1,168 @@
+package audio
+
+import (
+	"io"
+	"math"
+)
+
+const (
+	// DefaultWindow is the number of samples analyzed at a time: about 46ms
+	// at 44.1kHz, fine enough to resolve the bass band.
+	DefaultWindow = 2048
+
+	// Band edges in Hz.
+	bassLow    = 20
+	bassHigh   = 250
+	midHigh    = 4000
+	trebleHigh = 16000
+
+	// A beat is bass energy this many times its average over the last
+	// beatHistory seconds, at least beatMinGap seconds after the last one.
+	DefaultBeatSensitivity = 1.5
+	beatHistory            = 1.0
+	beatMinGap             = 0.2
+
+	// Band levels are relative to the loudest the band has recently been,
+	// which fades with this half-life in seconds, so quiet passages still
+	// move the lights.
+	peakHalfLife = 5.0
+	// silence is the energy below which a band counts as silent rather than
+	// being scaled up.
+	silence = 1e-6
+)
+
+// Source is anything that reads mono samples, like Reader and Realtime.
+type Source interface {
+	Read(samples []float64) (int, error)
+}
+
+// Levels describe one window of audio. Bass, Mid and Treble range from 0 to
+// 1 relative to how loud each band has recently been.
+type Levels struct {
+	Bass   float64 `json:"bass"`
+	Mid    float64 `json:"mid"`
+	Treble float64 `json:"treble"`
+	Beat   bool    `json:"beat"`
+}
+
+// Analyzer measures band levels and detects beats in overlapping windows of
+// samples.
+type Analyzer struct {
+	SampleRate      int
+	BeatSensitivity float64
+
+	window  []float64
+	spectra []complex128
+	samples []float64
+	hop     int
+
+	peaks    [3]float64
+	decay    float64
+	history  []float64
+	next     int
+	sinceHit int
+	minGap   int
+}
+
+func NewAnalyzer(sampleRate int) *Analyzer {
+	size := DefaultWindow
+	a := &Analyzer{
+		SampleRate:      sampleRate,
+		BeatSensitivity: DefaultBeatSensitivity,
+		window:          make([]float64, size),
+		spectra:         make([]complex128, size),
+		samples:         make([]float64, size),
+		hop:             size / 2,
+	}
+	// Hann window, to keep each window's edges from smearing the spectrum.
+	for i := range a.window {
+		a.window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size-1))
+	}
+	perSecond := float64(sampleRate) / float64(a.hop)
+	a.decay = math.Pow(0.5, 1/(peakHalfLife*perSecond))
+	a.history = make([]float64, 0, int(beatHistory*perSecond)+1)
+	a.minGap = int(beatMinGap * perSecond)
+	a.sinceHit = a.minGap
+	return a
+}
+
+// Interval is how much audio each Levels covers.
+func (a *Analyzer) Interval() float64 {
+	return float64(a.hop) / float64(a.SampleRate)
+}
+
+// Run reads src until it ends, sending the levels of each window to levels.
+// It returns nil once src is exhausted.
+func (a *Analyzer) Run(src Source, levels chan<- Levels) error {
+	hop := make([]float64, a.hop)
+	for {
+		n, err := src.Read(hop)
+		if n == len(hop) {
+			levels <- a.Analyze(hop)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Analyze adds samples, which should be half a window, and measures the
+// window ending with them.
+func (a *Analyzer) Analyze(samples []float64) Levels {
+	copy(a.samples, a.samples[len(samples):])
+	copy(a.samples[len(a.samples)-len(samples):], samples)
+	for i, sample := range a.samples {
+		a.spectra[i] = complex(sample*a.window[i], 0)
+	}
+	FFT(a.spectra)
+
+	binHz := float64(a.SampleRate) / float64(len(a.spectra))
+	edges := [4]float64{bassLow, bassHigh, midHigh, math.Min(trebleHigh, float64(a.SampleRate)/2)}
+	var energy [3]float64
+	for band := 0; band < 3; band++ {
+		low := int(math.Ceil(edges[band] / binHz))
+		high := int(edges[band+1] / binHz)
+		for k := low; k <= high && k < len(a.spectra)/2; k++ {
+			re, im := real(a.spectra[k]), imag(a.spectra[k])
+			energy[band] += re*re + im*im
+		}
+		// Scale to be independent of the window size.
+		energy[band] /= float64(len(a.spectra))
+	}
+
+	var levels [3]float64
+	for band, e := range energy {
+		a.peaks[band] = math.Max(e, a.peaks[band]*a.decay)
+		if a.peaks[band] > silence {
+			levels[band] = math.Sqrt(e / a.peaks[band])
+		}
+	}
+	return Levels{Bass: levels[0], Mid: levels[1], Treble: levels[2], Beat: a.beat(energy[0])}
+}
+
+func (a *Analyzer) beat(bass float64) bool {
+	var average float64
+	for _, e := range a.history {
+		average += e
+	}
+	full := len(a.history) == cap(a.history)
+	if len(a.history) > 0 {
+		average /= float64(len(a.history))
+	}
+	if full {
+		a.history[a.next] = bass
+		a.next = (a.next + 1) % len(a.history)
+	} else {
+		a.history = append(a.history, bass)
+	}
+
+	a.sinceHit++
+	if !full || bass <= silence || bass < a.BeatSensitivity*average || a.sinceHit < a.minGap {
+		return false
+	}
+	a.sinceHit = 0
+	return true
+}
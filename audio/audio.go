@@ -0,0 +1,261 @@
+// Package audio reads PCM audio and measures how much bass, mid and treble
+// it has and where its beats fall, for driving lights from music.
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"time"
+)
+
+const (
+	DefaultSampleRate = 44100
+	DefaultChannels   = 2
+)
+
+// Format describes interleaved, little-endian PCM samples. Bits is 8, 16, 24
+// or 32 for integer samples, or 32 with Float set. 8 bit samples are
+// unsigned, as in WAV files; the rest are signed.
+type Format struct {
+	SampleRate int
+	Channels   int
+	Bits       int
+	Float      bool
+}
+
+// DefaultFormat is CD audio, as most tools produce by default.
+var DefaultFormat = Format{SampleRate: DefaultSampleRate, Channels: DefaultChannels, Bits: 16}
+
+func (f Format) Validate() error {
+	if f.SampleRate <= 0 || f.Channels <= 0 {
+		return fmt.Errorf("audio: invalid sample rate %d or channels %d", f.SampleRate, f.Channels)
+	}
+	switch {
+	case f.Float && f.Bits == 32:
+	case !f.Float && (f.Bits == 8 || f.Bits == 16 || f.Bits == 24 || f.Bits == 32):
+	default:
+		return fmt.Errorf("audio: unsupported sample format: %d bit", f.Bits)
+	}
+	return nil
+}
+
+func (f Format) frameSize() int {
+	return f.Channels * f.Bits / 8
+}
+
+// Reader reads PCM, mixed down to mono samples between -1 and 1.
+type Reader struct {
+	Format Format
+
+	r     *bufio.Reader
+	frame []byte
+}
+
+func NewReader(r io.Reader, format Format) (*Reader, error) {
+	if err := format.Validate(); err != nil {
+		return nil, err
+	}
+	return &Reader{Format: format, r: bufio.NewReader(r), frame: make([]byte, format.frameSize())}, nil
+}
+
+// Read fills samples, returning how many it read. A partial frame at the end
+// of the stream is dropped.
+func (r *Reader) Read(samples []float64) (int, error) {
+	for i := range samples {
+		if _, err := io.ReadFull(r.r, r.frame); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return i, err
+		}
+		var sum float64
+		width := r.Format.Bits / 8
+		for c := 0; c < r.Format.Channels; c++ {
+			sum += r.decode(r.frame[c*width : (c+1)*width])
+		}
+		samples[i] = sum / float64(r.Format.Channels)
+	}
+	return len(samples), nil
+}
+
+func (r *Reader) decode(b []byte) float64 {
+	switch {
+	case r.Format.Float:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case r.Format.Bits == 8:
+		return (float64(b[0]) - 128) / 128
+	case r.Format.Bits == 16:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case r.Format.Bits == 24:
+		v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		return float64(v) / (1 << 23)
+	default:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	}
+}
+
+var errInvalidWAV = errors.New("audio: not a PCM WAV file")
+
+const (
+	wavPCM        = 1
+	wavFloat      = 3
+	wavExtensible = 0xfffe
+)
+
+// NewWAVReader reads the header of a WAV file, leaving the returned reader at
+// the start of its samples.
+func NewWAVReader(r io.Reader) (*Reader, error) {
+	var riff struct {
+		ID   [4]byte
+		Size uint32
+		Wave [4]byte
+	}
+	if err := binary.Read(r, binary.LittleEndian, &riff); err != nil {
+		return nil, errInvalidWAV
+	}
+	if string(riff.ID[:]) != "RIFF" || string(riff.Wave[:]) != "WAVE" {
+		return nil, errInvalidWAV
+	}
+	var format *Format
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+			return nil, errInvalidWAV
+		}
+		// Chunks are padded to an even length.
+		size := int64(chunk.Size) + int64(chunk.Size&1)
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			var fmtChunk struct {
+				Tag        uint16
+				Channels   uint16
+				SampleRate uint32
+				ByteRate   uint32
+				Align      uint16
+				Bits       uint16
+			}
+			if chunk.Size < 16 {
+				return nil, errInvalidWAV
+			}
+			if err := binary.Read(r, binary.LittleEndian, &fmtChunk); err != nil {
+				return nil, errInvalidWAV
+			}
+			tag := fmtChunk.Tag
+			if tag == wavExtensible {
+				// The actual format is the first two bytes of the sub-format
+				// GUID, after the extension size, valid bits and channel mask.
+				var ext struct {
+					Size      uint16
+					ValidBits uint16
+					Mask      uint32
+					SubFormat uint16
+				}
+				if size < 16+10 || binary.Read(r, binary.LittleEndian, &ext) != nil {
+					return nil, errInvalidWAV
+				}
+				tag = ext.SubFormat
+				size -= 10
+			}
+			if tag != wavPCM && tag != wavFloat {
+				return nil, fmt.Errorf("audio: unsupported WAV encoding %d", tag)
+			}
+			format = &Format{
+				SampleRate: int(fmtChunk.SampleRate),
+				Channels:   int(fmtChunk.Channels),
+				Bits:       int(fmtChunk.Bits),
+				Float:      tag == wavFloat,
+			}
+			if _, err := io.CopyN(ioutil.Discard, r, size-16); err != nil {
+				return nil, errInvalidWAV
+			}
+		case "data":
+			if format == nil {
+				return nil, errInvalidWAV
+			}
+			return NewReader(io.LimitReader(r, int64(chunk.Size)), *format)
+		default:
+			if _, err := io.CopyN(ioutil.Discard, r, size); err != nil {
+				return nil, errInvalidWAV
+			}
+		}
+	}
+}
+
+// Realtime paces reads from a file to the speed the audio would play at, so
+// lights follow it as if it were playing.
+type Realtime struct {
+	*Reader
+	start time.Time
+	read  int64
+}
+
+func NewRealtime(r *Reader) *Realtime {
+	return &Realtime{Reader: r}
+}
+
+func (r *Realtime) Read(samples []float64) (int, error) {
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+	n, err := r.Reader.Read(samples)
+	r.read += int64(n)
+	due := r.start.Add(time.Duration(r.read) * time.Second / time.Duration(r.Format.SampleRate))
+	if wait := due.Sub(time.Now()); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
+
+// ListenUDP receives raw PCM datagrams on a loopback address, so audio can be
+// streamed from another program on the same machine, as with
+//
+//	ffmpeg -re -i song.mp3 -f s16le -ac 2 -ar 44100 udp://127.0.0.1:9999
+//
+// Each datagram should hold whole frames.
+func ListenUDP(addr string) (io.ReadCloser, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if udpAddr.IP == nil || !udpAddr.IP.IsLoopback() {
+		return nil, fmt.Errorf("audio: %s is not a loopback address", addr)
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &udpStream{conn: conn, buf: make([]byte, 65536)}, nil
+}
+
+// udpStream reads datagrams as one stream of bytes.
+type udpStream struct {
+	conn    *net.UDPConn
+	buf     []byte
+	pending []byte
+}
+
+func (s *udpStream) Read(b []byte) (int, error) {
+	if len(s.pending) == 0 {
+		n, err := s.conn.Read(s.buf)
+		if err != nil {
+			return 0, err
+		}
+		s.pending = s.buf[:n]
+	}
+	n := copy(b, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *udpStream) Close() error {
+	return s.conn.Close()
+}
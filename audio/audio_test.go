@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// wav builds a WAV file from its chunks, each an ID followed by its body.
+func wav(chunks ...interface{}) []byte {
+	var body bytes.Buffer
+	body.WriteString("WAVE")
+	for i := 0; i < len(chunks); i += 2 {
+		var chunk bytes.Buffer
+		binary.Write(&chunk, binary.LittleEndian, chunks[i+1])
+		body.WriteString(chunks[i].(string))
+		binary.Write(&body, binary.LittleEndian, uint32(chunk.Len()))
+		body.Write(chunk.Bytes())
+		if chunk.Len()%2 == 1 {
+			body.WriteByte(0)
+		}
+	}
+	var file bytes.Buffer
+	file.WriteString("RIFF")
+	binary.Write(&file, binary.LittleEndian, uint32(body.Len()))
+	file.Write(body.Bytes())
+	return file.Bytes()
+}
+
+// fmtChunk is a plain fmt chunk body.
+func fmtChunk(tag, channels uint16, rate uint32, bits uint16) []uint16 {
+	align := channels * bits / 8
+	byteRate := rate * uint32(align)
+	return []uint16{tag, channels, uint16(rate), uint16(rate >> 16), uint16(byteRate), uint16(byteRate >> 16), align, bits}
+}
+
+func TestNewWAVReader(t *testing.T) {
+	extensible := append(fmtChunk(wavExtensible, 1, 48000, 32),
+		22, 32, 4, 0, wavFloat, 0x0000, 0x0010, 0x8000, 0x00aa, 0x3800, 0x719b)
+	tests := []struct {
+		name    string
+		file    []byte
+		format  Format
+		samples []float64
+	}{
+		{
+			"16 bit stereo",
+			wav("fmt ", fmtChunk(wavPCM, 2, 44100, 16), "data", []int16{16384, -16384, 32767, 32767, -32768, -32768}),
+			Format{SampleRate: 44100, Channels: 2, Bits: 16},
+			[]float64{0, 32767.0 / 32768, -1},
+		},
+		{
+			"8 bit after a list chunk",
+			wav("LIST", []byte("INFOISFT"), "fmt ", fmtChunk(wavPCM, 1, 8000, 8), "odd ", []byte{1}, "data", []byte{0, 128, 192}),
+			Format{SampleRate: 8000, Channels: 1, Bits: 8},
+			[]float64{-1, 0, 0.5},
+		},
+		{
+			"24 bit",
+			wav("fmt ", fmtChunk(wavPCM, 1, 96000, 24), "data", []byte{0, 0, 0x40, 0, 0, 0xc0, 0xff, 0xff, 0xff}),
+			Format{SampleRate: 96000, Channels: 1, Bits: 24},
+			[]float64{0.5, -0.5, -1.0 / (1 << 23)},
+		},
+		{
+			"extensible float",
+			wav("fmt ", extensible, "data", []float32{0.25, -0.75}),
+			Format{SampleRate: 48000, Channels: 1, Bits: 32, Float: true},
+			[]float64{0.25, -0.75},
+		},
+		{
+			// Whatever follows the data chunk isn't read as samples.
+			"trailing chunk",
+			append(wav("fmt ", fmtChunk(wavPCM, 1, 44100, 32), "data", []int32{math.MinInt32}), "LIST\x04\x00\x00\x00abcd"...),
+			Format{SampleRate: 44100, Channels: 1, Bits: 32},
+			[]float64{-1},
+		},
+	}
+	for _, test := range tests {
+		r, err := NewWAVReader(bytes.NewReader(test.file))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if r.Format != test.format {
+			t.Errorf("%s: format %+v, want %+v", test.name, r.Format, test.format)
+		}
+		samples := make([]float64, len(test.samples)+1)
+		n, err := r.Read(samples)
+		if err != io.EOF || !reflect.DeepEqual(samples[:n], test.samples) {
+			t.Errorf("%s: read %v, %v; want %v, EOF", test.name, samples[:n], err, test.samples)
+		}
+	}
+}
+
+func TestNewWAVReaderInvalid(t *testing.T) {
+	pcm := fmtChunk(wavPCM, 1, 44100, 16)
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"empty", nil},
+		{"not RIFF", []byte("RIFX\x00\x00\x00\x00WAVE")},
+		{"not WAVE", wav()[:8]},
+		{"no chunks", wav()},
+		{"data before fmt", wav("data", []int16{0}, "fmt ", pcm)},
+		{"short fmt", wav("fmt ", pcm[:6], "data", []int16{0})},
+		{"compressed", wav("fmt ", fmtChunk(2, 1, 44100, 4), "data", []byte{0})},
+		{"12 bit", wav("fmt ", fmtChunk(wavPCM, 1, 44100, 12), "data", []byte{0})},
+		{"no channels", wav("fmt ", fmtChunk(wavPCM, 0, 44100, 16), "data", []byte{0})},
+		{"truncated chunk", wav("fmt ", pcm)[:30]},
+		{"huge chunk", append(wav("fmt ", pcm), "LIST\xff\xff\xff\xff"...)},
+	}
+	for _, test := range tests {
+		if r, err := NewWAVReader(bytes.NewReader(test.file)); err == nil {
+			t.Errorf("%s: read %+v, want an error", test.name, r.Format)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		format  Format
+		input   interface{}
+		samples []float64
+	}{
+		{Format{SampleRate: 1, Channels: 1, Bits: 16}, []int16{0, 8192, -8192}, []float64{0, 0.25, -0.25}},
+		{Format{SampleRate: 1, Channels: 2, Bits: 32, Float: true}, []float32{1, 0, -0.5, -0.5}, []float64{0.5, -0.5}},
+		{Format{SampleRate: 1, Channels: 3, Bits: 8}, []byte{255, 255, 255, 0, 128, 128}, []float64{127.0 / 128, -1.0 / 3}},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, test.input)
+		// A partial frame at the end is dropped.
+		buf.WriteByte(1)
+		r, err := NewReader(&buf, test.format)
+		if err != nil {
+			t.Errorf("%+v: %v", test.format, err)
+			continue
+		}
+		var samples []float64
+		one := make([]float64, 1)
+		for {
+			n, err := r.Read(one)
+			samples = append(samples, one[:n]...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !reflect.DeepEqual(samples, test.samples) {
+			t.Errorf("%+v: read %v, want %v", test.format, samples, test.samples)
+		}
+	}
+
+	for _, format := range []Format{{}, {SampleRate: 1, Channels: 1, Bits: 12}, {SampleRate: 1, Channels: 1, Bits: 16, Float: true}} {
+		if _, err := NewReader(strings.NewReader(""), format); err == nil {
+			t.Errorf("%+v is valid", format)
+		}
+	}
+}
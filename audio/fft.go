@@ -0,0 +1,38 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// FFT replaces x, whose length must be a power of two, with its discrete
+// Fourier transform.
+func FFT(x []complex128) {
+	n := len(x)
+	if n&(n-1) != 0 {
+		panic("audio: FFT length is not a power of two")
+	}
+	// Reorder by bit-reversed index, then combine ever larger transforms.
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := x[start+k], w*x[start+k+size/2]
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
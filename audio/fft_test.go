@@ -0,0 +1,103 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestFFTSine(t *testing.T) {
+	tests := []struct {
+		n, cycles int
+		amplitude float64
+		phase     float64
+	}{
+		{8, 1, 1, 0},
+		{64, 5, 0.5, math.Pi / 3},
+		{1024, 100, 2, -math.Pi / 2},
+		{2048, 1023, 1, 0.25},
+	}
+	for _, test := range tests {
+		x := make([]complex128, test.n)
+		for i := range x {
+			x[i] = complex(test.amplitude*math.Cos(2*math.Pi*float64(test.cycles*i)/float64(test.n)+test.phase), 0)
+		}
+		FFT(x)
+		// A real sine puts half its amplitude, times n, in its own bin and
+		// its mirror image, and nothing anywhere else.
+		for k, v := range x {
+			var want complex128
+			if k == test.cycles {
+				want = cmplx.Rect(test.amplitude*float64(test.n)/2, test.phase)
+			} else if k == test.n-test.cycles {
+				want = cmplx.Rect(test.amplitude*float64(test.n)/2, -test.phase)
+			}
+			if cmplx.Abs(v-want) > 1e-9*float64(test.n) {
+				t.Errorf("n=%d cycles=%d: bin %d is %v, want %v", test.n, test.cycles, k, v, want)
+			}
+		}
+	}
+}
+
+func TestFFTMatchesDFT(t *testing.T) {
+	x := make([]complex128, 32)
+	for i := range x {
+		x[i] = complex(math.Sin(float64(i*i)), math.Cos(float64(3*i)))
+	}
+	want := make([]complex128, len(x))
+	for k := range want {
+		for i, v := range x {
+			want[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(k*i)/float64(len(x))))
+		}
+	}
+	FFT(x)
+	for k := range x {
+		if cmplx.Abs(x[k]-want[k]) > 1e-9 {
+			t.Errorf("bin %d is %v, want %v", k, x[k], want[k])
+		}
+	}
+}
+
+func TestFFTLength(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 4} {
+		FFT(make([]complex128, n))
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("a length of 6 didn't panic")
+		}
+	}()
+	FFT(make([]complex128, 6))
+}
+
+func TestAnalyzerBands(t *testing.T) {
+	tests := []struct {
+		hz   float64
+		band int
+	}{
+		{60, 0},
+		{1000, 1},
+		{8000, 2},
+	}
+	for _, test := range tests {
+		a := NewAnalyzer(DefaultSampleRate)
+		samples := make([]float64, DefaultWindow/2)
+		var levels Levels
+		for hop := 0; hop < 4; hop++ {
+			for i := range samples {
+				n := float64(hop*len(samples) + i)
+				samples[i] = 0.5 * math.Sin(2*math.Pi*test.hz*n/DefaultSampleRate)
+			}
+			levels = a.Analyze(samples)
+		}
+		got := [3]float64{levels.Bass, levels.Mid, levels.Treble}
+		for band, level := range got {
+			if band == test.band && level < 0.9 {
+				t.Errorf("%gHz: band %d is at %v, want about 1", test.hz, band, level)
+			}
+			if band != test.band && a.peaks[band] > a.peaks[test.band]/100 {
+				t.Errorf("%gHz: band %d peaked at %v, against %v in band %d", test.hz, band, a.peaks[band], a.peaks[test.band], test.band)
+			}
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package palette
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	DefaultAuditMaxSize  = 10 << 20
+	DefaultAuditMaxFiles = 5
+)
+
+// Change describes who asked for a change to lights, for the audit log.
+// Scheduled changes name the schedule as their caller.
+type Change struct {
+	Caller   string      `json:"caller"`
+	Endpoint string      `json:"endpoint"`
+	Request  interface{} `json:"request,omitempty"`
+}
+
+// AuditRecord is one change to a set of lights and how each light took it.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	Change
+	Lights []AuditResult `json:"lights"`
+}
+
+type AuditResult struct {
+	Id    string          `json:"id"`
+	Name  string          `json:"name"`
+	State *hue.LightState `json:"state,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// AuditQuery filters audit records. Zero times leave the range open, and
+// light patterns match as for SelectLights.
+type AuditQuery struct {
+	Since  time.Time
+	Until  time.Time
+	Lights []string
+	Limit  int
+}
+
+func (q AuditQuery) matches(record AuditRecord) bool {
+	if !q.Since.IsZero() && record.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && record.Time.After(q.Until) {
+		return false
+	}
+	if len(q.Lights) == 0 {
+		return true
+	}
+	lights := make([]hue.Light, len(record.Lights))
+	for i, result := range record.Lights {
+		lights[i] = hue.Light{Id: result.Id, Name: result.Name}
+	}
+	return len(SelectLights(lights, q.Lights)) > 0
+}
+
+// AuditLog appends records to a JSON Lines file, rotating it to Path.1,
+// Path.2 and so on once it grows past MaxSize.
+type AuditLog struct {
+	Path     string
+	MaxSize  int64
+	MaxFiles int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{
+		Path:     path,
+		MaxSize:  DefaultAuditMaxSize,
+		MaxFiles: DefaultAuditMaxFiles,
+	}
+}
+
+func (a *AuditLog) Append(record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		if err := a.open(); err != nil {
+			return err
+		}
+	}
+	if a.size > 0 && a.size+int64(len(line)) > a.MaxSize {
+		a.file.Close()
+		a.file = nil
+		if err := a.rotate(); err != nil {
+			return err
+		}
+		if err := a.open(); err != nil {
+			return err
+		}
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	return err
+}
+
+func (a *AuditLog) open() error {
+	f, err := os.OpenFile(a.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	a.file, a.size = f, info.Size()
+	return nil
+}
+
+func (a *AuditLog) rotated(n int) string {
+	if n == 0 {
+		return a.Path
+	}
+	return fmt.Sprintf("%s.%d", a.Path, n)
+}
+
+func (a *AuditLog) rotate() error {
+	os.Remove(a.rotated(a.MaxFiles))
+	for n := a.MaxFiles - 1; n >= 0; n-- {
+		err := os.Rename(a.rotated(n), a.rotated(n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// Query returns the matching records, newest first. With a limit, only the
+// newest records up to the limit are returned.
+func (a *AuditLog) Query(q AuditQuery) ([]AuditRecord, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var records []AuditRecord
+	for n := a.MaxFiles; n >= 0; n-- {
+		f, err := os.Open(a.rotated(n))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		records, err = readAuditRecords(f, q, records)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[:q.Limit]
+	}
+	return records, nil
+}
+
+func readAuditRecords(r io.Reader, q AuditQuery, records []AuditRecord) ([]AuditRecord, error) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var record AuditRecord
+			// Skip a line left partly written by a crash rather than failing
+			// every query.
+			if json.Unmarshal(line, &record) == nil && q.matches(record) {
+				records = append(records, record)
+			}
+		}
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+	}
+}
+
+// SetAuditLog makes every change made with SetGroupAs or RecordChange be
+// appended to a.
+func (p *Palette) SetAuditLog(a *AuditLog) {
+	p.mu.Lock()
+	p.audit = a
+	p.mu.Unlock()
+}
+
+func (p *Palette) AuditLog() *AuditLog {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.audit
+}
+
+// RecordChange appends a change and its results to the audit log, if any.
+func (p *Palette) RecordChange(change Change, results []AuditResult) error {
+	audit := p.AuditLog()
+	if audit == nil {
+		return nil
+	}
+	return audit.Append(AuditRecord{Time: time.Now().UTC(), Change: change, Lights: results})
+}
+
+// SetGroupAs sets lights like SetGroup, recording the change and the result
+// for each light in the audit log once every light has been set.
+func (p *Palette) SetGroupAs(change Change, lights []hue.Light, states []hue.LightState) <-chan error {
+	res := p.SetGroup(lights, states)
+	if p.AuditLog() == nil {
+		return res
+	}
+	out := make(chan error, len(lights))
+	go func() {
+		failed := make(map[string]error)
+		for err := range res {
+			if lightErr, ok := err.(*LightError); ok {
+				failed[lightErr.Light.Id] = lightErr.Err
+			}
+			out <- err
+		}
+		results := make([]AuditResult, len(lights))
+		for i, light := range lights {
+			state := states[i%len(states)]
+			results[i] = AuditResult{Id: light.Id, Name: light.Name, State: &state}
+			if err, ok := failed[light.Id]; ok {
+				results[i].Error = err.Error()
+			}
+		}
+		if err := p.RecordChange(change, results); err != nil {
+			log.WithField("error", err).Error("Failed to write audit record")
+		}
+		close(out)
+	}()
+	return out
+}
@@ -0,0 +1,91 @@
+package palette
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/BrianBland/palette/metrics"
+
+	"github.com/BrianBland/go-hue"
+)
+
+var (
+	bridgeCalls = metrics.Default.NewCounter("palette_bridge_calls_total",
+		"Calls made to the Hue bridge.", "method")
+	bridgeErrors = metrics.Default.NewCounter("palette_bridge_errors_total",
+		"Failed calls to the Hue bridge, by bridge API error type, or network, parse or other for failures the bridge didn't describe.", "method", "type")
+	bridgeDuration = metrics.Default.NewHistogram("palette_bridge_call_duration_seconds",
+		"Time taken by calls to the Hue bridge.", metrics.DefaultBuckets, "method")
+)
+
+// observeBridgeCall records a call to the bridge that began at start.
+func observeBridgeCall(method string, start time.Time, err error) {
+	bridgeCalls.Inc(method)
+	bridgeDuration.Observe(time.Since(start).Seconds(), method)
+	if err == nil {
+		return
+	}
+	switch e := err.(type) {
+	case *hue.APIError:
+		for _, detail := range e.Errors {
+			bridgeErrors.Inc(method, strconv.Itoa(detail.Type))
+		}
+	case *hue.APIParseError:
+		bridgeErrors.Inc(method, "parse")
+	case *url.Error, net.Error:
+		bridgeErrors.Inc(method, "network")
+	default:
+		bridgeErrors.Inc(method, "other")
+	}
+}
+
+// The bridge methods palette uses are routed to the light's bridge and
+// wrapped to record metrics.
+
+func (p *Palette) GetLightAttributes(lightId string) (*hue.LightAttributes, error) {
+	user, lightId, err := p.resolve(lightId)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	attrs, err := user.GetLightAttributes(lightId)
+	observeBridgeCall("GetLightAttributes", start, err)
+	return attrs, err
+}
+
+// SetLightState also applies the default transition and waits for the
+// command rate limit, if set.
+func (p *Palette) SetLightState(lightId string, state *hue.LightState) error {
+	p.mu.Lock()
+	transition, limiter := p.transition, p.limiter
+	p.mu.Unlock()
+	if transition != nil && state.TransitionTime == nil {
+		withTransition := *state
+		withTransition.TransitionTime = transition
+		state = &withTransition
+	}
+	user, lightId, err := p.resolve(lightId)
+	if err != nil {
+		return err
+	}
+	if limiter != nil {
+		limiter.Wait()
+	}
+	start := time.Now()
+	err = user.SetLightState(lightId, state)
+	observeBridgeCall("SetLightState", start, err)
+	return err
+}
+
+func (p *Palette) SetLightName(lightId string, name string) error {
+	user, lightId, err := p.resolve(lightId)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = user.SetLightName(lightId, name)
+	observeBridgeCall("SetLightName", start, err)
+	return err
+}
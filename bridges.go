@@ -0,0 +1,325 @@
+package palette
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	ErrNoBridges    = errors.New("No bridges connected")
+	ErrUnknownLight = errors.New("Unknown light")
+)
+
+// IdentifyBridge fills in the unique ID of a bridge known only by its
+// address. The ID is derived from the MAC address in the bridge's public
+// configuration, the same way the bridge derives it.
+func IdentifyBridge(bridge *hue.Bridge) error {
+	start := time.Now()
+	config, err := hue.NewUserWithBridge("palette", bridge).GetConfiguration()
+	observeBridgeCall("GetConfiguration", start, err)
+	if err != nil {
+		return err
+	}
+	id := bridgeIdFromMAC(config.MAC)
+	if id == "" {
+		return fmt.Errorf("bridge reported an invalid MAC address %q", config.MAC)
+	}
+	bridge.UniqueId = id
+	return nil
+}
+
+// Connect adds a discovered bridge to the bridges palette controls. It uses
+// the credentials saved for the bridge, or pairs with it when there are none
+// or the bridge no longer accepts them. Pairing fails with
+// ErrLinkButtonNotPressed unless the bridge's link button has just been
+// pressed; see Pair to wait for it.
+func (p *Palette) Connect(discovered DiscoveredBridge) error {
+	err := p.connect(discovered)
+	p.notePairing(discovered, err)
+	return err
+}
+
+func (p *Palette) connect(discovered DiscoveredBridge) error {
+	bridge := discovered.Bridge()
+	if bridge.UniqueId == "" {
+		if err := IdentifyBridge(bridge); err != nil {
+			return err
+		}
+	}
+	p.refresh()
+	p.mu.Lock()
+	saved, hasSaved := p.state.Bridges[bridge.UniqueId]
+	legacy, hasLegacy := p.state.Bridges[""]
+	p.mu.Unlock()
+
+	var username string
+	switch {
+	case hasSaved:
+		username = saved.Username
+	case hasLegacy:
+		username = legacy.Username
+	}
+	if username != "" {
+		start := time.Now()
+		valid, err := bridge.IsValidUser(username)
+		observeBridgeCall("IsValidUser", start, err)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			log.WithField("bridge", bridge.UniqueId).Warn("Bridge rejected the saved username, pairing again")
+			username = ""
+		}
+	}
+	if username == "" {
+		start := time.Now()
+		user, err := bridge.CreateUser(DEVICETYPE, "")
+		observeBridgeCall("CreateUser", start, err)
+		if isLinkButtonError(err) {
+			return ErrLinkButtonNotPressed
+		}
+		if err != nil {
+			return err
+		}
+		username = user.Username
+	}
+
+	p.mu.Lock()
+	users := make([]*hue.User, 0, len(p.users)+1)
+	for _, user := range p.users {
+		if user.Bridge.UniqueId != bridge.UniqueId {
+			users = append(users, user)
+		}
+	}
+	p.users = append(users, hue.NewUserWithBridge(username, bridge))
+	p.mu.Unlock()
+	log.WithField("bridge", bridge.UniqueId).Info("Connected to bridge")
+	adopted := hasLegacy && legacy.Username == username
+	if saved.Username == username && saved.Address == discovered.Address && !adopted {
+		return nil
+	}
+	return p.update(func(st *state) error {
+		if _, ok := st.Bridges[bridge.UniqueId]; !ok && !adopted {
+			// Light IDs are qualified from the second bridge on, so the
+			// first one's saved IDs are qualified to match.
+			if paired := pairedBridges(st); len(paired) == 1 {
+				qualifyLightIds(st, paired[0])
+			}
+		}
+		st.Bridges[bridge.UniqueId] = bridgeConfig{Username: username, Address: discovered.Address}
+		if legacy, ok := st.Bridges[""]; ok && legacy.Username == username {
+			delete(st.Bridges, "")
+		}
+		return nil
+	})
+}
+
+// pairedBridges returns the IDs of the bridges palette has credentials for.
+func pairedBridges(st *state) []string {
+	var ids []string
+	for id := range st.Bridges {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// qualifyLightIds qualifies the unqualified light IDs saved in scenes, and the
+// light patterns saved in schedules, light sets and tokens that are plain
+// IDs, with the bridge they belong to. Patterns matching names are left alone.
+func qualifyLightIds(st *state, bridge string) {
+	qualify := func(id string) string {
+		if id == "" || strings.Trim(id, "0123456789") != "" {
+			return id
+		}
+		return bridge + ":" + id
+	}
+	qualifyAll := func(patterns []string) {
+		for i, pattern := range patterns {
+			patterns[i] = qualify(pattern)
+		}
+	}
+	for name, scene := range st.Scenes {
+		lights := make(map[string]hue.LightState, len(scene.Lights))
+		for id, lightState := range scene.Lights {
+			lights[qualify(id)] = lightState
+		}
+		scene.Lights = lights
+		st.Scenes[name] = scene
+	}
+	if st.Schedules.Circadian != nil {
+		qualifyAll(st.Schedules.Circadian.Lights)
+	}
+	if st.Schedules.Sunrise != nil {
+		qualifyAll(st.Schedules.Sunrise.Lights)
+	}
+	for _, patterns := range st.LightSets {
+		qualifyAll(patterns)
+	}
+	for _, token := range st.Tokens {
+		qualifyAll(token.Lights)
+	}
+}
+
+// CachedBridges returns the bridges palette has credentials for, at the
+// addresses they had when last connected.
+func (p *Palette) CachedBridges() []DiscoveredBridge {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var bridges []DiscoveredBridge
+	for id, saved := range p.state.Bridges {
+		if id != "" && saved.Address != "" {
+			bridges = append(bridges, DiscoveredBridge{Id: id, Address: saved.Address, Method: DiscoverCached})
+		}
+	}
+	sort.Sort(byBridgeId(bridges))
+	return bridges
+}
+
+// Paired reports whether palette has credentials for the bridge.
+func (p *Palette) Paired(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.state.Bridges[id]
+	return ok && id != ""
+}
+
+// Bridges returns the unique IDs of the connected bridges.
+func (p *Palette) Bridges() []string {
+	users := p.connected()
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.Bridge.UniqueId
+	}
+	return ids
+}
+
+func (p *Palette) connected() []*hue.User {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*hue.User(nil), p.users...)
+}
+
+// multiBridge reports whether palette controls several bridges, whether or
+// not they're all connected. Light IDs are qualified by their bridge once it
+// does, so they don't change when a bridge is briefly unreachable.
+func (p *Palette) multiBridge(users []*hue.User) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make(map[string]bool)
+	for id := range p.state.Bridges {
+		if id != "" {
+			ids[id] = true
+		}
+	}
+	for _, user := range users {
+		ids[user.Bridge.UniqueId] = true
+	}
+	return len(ids) > 1
+}
+
+// lightId returns the ID palette uses for a bridge's light, qualified by the
+// bridge when palette controls several.
+func lightId(multiBridge bool, user *hue.User, id string) string {
+	if !multiBridge {
+		return id
+	}
+	return user.Bridge.UniqueId + ":" + id
+}
+
+// resolve finds the bridge a light belongs to and the light's ID on that
+// bridge. Unqualified IDs are only known while palette controls a single
+// bridge, and qualified IDs only for connected bridges.
+func (p *Palette) resolve(id string) (*hue.User, string, error) {
+	users := p.connected()
+	if len(users) == 0 {
+		return nil, "", ErrNoBridges
+	}
+	i := strings.Index(id, ":")
+	if i < 0 {
+		if p.multiBridge(users) {
+			return nil, "", ErrUnknownLight
+		}
+		return users[0], id, nil
+	}
+	for _, user := range users {
+		if user.Bridge.UniqueId == id[:i] {
+			return user, id[i+1:], nil
+		}
+	}
+	return nil, "", ErrUnknownLight
+}
+
+// GetLights returns the lights of every bridge. Bridges that can't be reached
+// are logged and skipped, unless none can be.
+func (p *Palette) GetLights() ([]hue.Light, error) {
+	users := p.connected()
+	if len(users) == 0 {
+		return nil, ErrNoBridges
+	}
+	type result struct {
+		lights []hue.Light
+		err    error
+	}
+	results := make([]result, len(users))
+	var wg sync.WaitGroup
+	wg.Add(len(users))
+	for i, user := range users {
+		go func(i int, user *hue.User) {
+			defer wg.Done()
+			start := time.Now()
+			lights, err := user.GetLights()
+			observeBridgeCall("GetLights", start, err)
+			results[i] = result{lights, err}
+		}(i, user)
+	}
+	wg.Wait()
+
+	multiBridge := p.multiBridge(users)
+	lights := make([]hue.Light, 0)
+	var firstErr error
+	for i, user := range users {
+		if err := results[i].err; err != nil {
+			if len(users) > 1 {
+				err = &BridgeError{Bridge: user.Bridge.UniqueId, Err: err}
+				log.WithField("error", err).Warn("Failed to get lights")
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, light := range results[i].lights {
+			light.Id = lightId(multiBridge, user, light.Id)
+			lights = append(lights, light)
+		}
+	}
+	if len(lights) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	sort.Sort(byID(lights))
+	return lights, nil
+}
+
+type byBridgeId []DiscoveredBridge
+
+func (s byBridgeId) Len() int {
+	return len(s)
+}
+
+func (s byBridgeId) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s byBridgeId) Less(i, j int) bool {
+	return s[i].Id < s[j].Id
+}
@@ -0,0 +1,362 @@
+package palette
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// Hue bulbs accept color temperatures between 153 (6500K) and 500 (2000K)
+	// mireds.
+	MinColorTemp = 153
+	MaxColorTemp = 500
+
+	DefaultCircadianInterval = time.Minute
+	DefaultPauseTimeout      = time.Hour
+
+	// Changes this small are treated as bridge rounding, not manual changes.
+	circadianTolerance = 2
+)
+
+// TimeOfDay is a wall-clock offset from midnight, encoded as "15:04" in JSON.
+type TimeOfDay time.Duration
+
+func ParseTimeOfDay(s string) (TimeOfDay, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return TimeOfDay(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute), nil
+}
+
+func TimeOfDayOf(t time.Time) TimeOfDay {
+	return TimeOfDay(time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second)
+}
+
+// Next returns the first instant at or after t falling on this time of day.
+func (d TimeOfDay) Next(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	next := midnight.Add(time.Duration(d))
+	if next.Before(t) {
+		next = midnight.AddDate(0, 0, 1).Add(time.Duration(d))
+	}
+	return next
+}
+
+func (d TimeOfDay) String() string {
+	minutes := int(time.Duration(d) / time.Minute)
+	return fmt.Sprintf("%02d:%02d", minutes/60%24, minutes%60)
+}
+
+func (d TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *TimeOfDay) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseTimeOfDay(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+type CircadianPoint struct {
+	Time       TimeOfDay `json:"time"`
+	ColorTemp  uint16    `json:"ct"`
+	Brightness uint8     `json:"bri"`
+}
+
+// CircadianCurve is a daily cycle of color temperature and brightness,
+// linearly interpolated between points and wrapping around midnight.
+type CircadianCurve []CircadianPoint
+
+var DefaultCircadianCurve = CircadianCurve{
+	{Time: TimeOfDay(0), ColorTemp: 500, Brightness: 40},
+	{Time: TimeOfDay(6 * time.Hour), ColorTemp: 454, Brightness: 80},
+	{Time: TimeOfDay(8 * time.Hour), ColorTemp: 300, Brightness: 200},
+	{Time: TimeOfDay(12 * time.Hour), ColorTemp: 200, Brightness: 254},
+	{Time: TimeOfDay(17 * time.Hour), ColorTemp: 300, Brightness: 230},
+	{Time: TimeOfDay(20 * time.Hour), ColorTemp: 400, Brightness: 160},
+	{Time: TimeOfDay(22 * time.Hour), ColorTemp: 454, Brightness: 80},
+}
+
+func (c CircadianCurve) Validate() error {
+	if len(c) == 0 {
+		return fmt.Errorf("circadian curve has no points")
+	}
+	for _, point := range c {
+		if point.ColorTemp < MinColorTemp || point.ColorTemp > MaxColorTemp {
+			return fmt.Errorf("color temperature %d at %s is outside %d-%d",
+				point.ColorTemp, point.Time, MinColorTemp, MaxColorTemp)
+		}
+		if point.Brightness > maxLevel {
+			return fmt.Errorf("brightness %d at %s is above %d", point.Brightness, point.Time, maxLevel)
+		}
+		if time.Duration(point.Time) >= 24*time.Hour {
+			return fmt.Errorf("time %s is not within a day", point.Time)
+		}
+	}
+	return nil
+}
+
+// At returns the color temperature and brightness the curve prescribes at t.
+func (c CircadianCurve) At(t time.Time) (uint16, uint8) {
+	points := make(CircadianCurve, len(c))
+	copy(points, c)
+	sort.Sort(byTimeOfDay(points))
+
+	now := TimeOfDayOf(t)
+	last := len(points) - 1
+	for i, point := range points {
+		if point.Time <= now {
+			last = i
+		}
+	}
+	prev, next := points[last], points[(last+1)%len(points)]
+
+	span := time.Duration(next.Time - prev.Time)
+	elapsed := time.Duration(now - prev.Time)
+	if span <= 0 {
+		span += 24 * time.Hour
+	}
+	if elapsed < 0 {
+		elapsed += 24 * time.Hour
+	}
+	frac := float64(elapsed) / float64(span)
+	ct := float64(prev.ColorTemp) + frac*(float64(next.ColorTemp)-float64(prev.ColorTemp))
+	bri := float64(prev.Brightness) + frac*(float64(next.Brightness)-float64(prev.Brightness))
+	return uint16(ct + 0.5), uint8(bri + 0.5)
+}
+
+type byTimeOfDay CircadianCurve
+
+func (s byTimeOfDay) Len() int {
+	return len(s)
+}
+
+func (s byTimeOfDay) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s byTimeOfDay) Less(i, j int) bool {
+	return s[i].Time < s[j].Time
+}
+
+// Circadian continuously moves the selected lights along a curve, leaving
+// alone any light that was changed by someone else until PauseTimeout passes.
+type Circadian struct {
+	Curve        CircadianCurve
+	Lights       []string
+	Interval     time.Duration
+	PauseTimeout time.Duration
+
+	palette *Palette
+	mu      sync.Mutex
+	applied map[string]hue.LightState
+	paused  map[string]time.Time
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type CircadianStatus struct {
+	Running    bool                 `json:"running"`
+	Curve      CircadianCurve       `json:"curve"`
+	Lights     []string             `json:"lights,omitempty"`
+	Interval   string               `json:"interval"`
+	ColorTemp  uint16               `json:"ct"`
+	Brightness uint8                `json:"bri"`
+	Paused     map[string]time.Time `json:"paused"`
+}
+
+func (p *Palette) NewCircadian(curve CircadianCurve, lights []string) *Circadian {
+	if curve == nil {
+		curve = DefaultCircadianCurve
+	}
+	return &Circadian{
+		Curve:        curve,
+		Lights:       lights,
+		Interval:     DefaultCircadianInterval,
+		PauseTimeout: DefaultPauseTimeout,
+		palette:      p,
+		applied:      make(map[string]hue.LightState),
+		paused:       make(map[string]time.Time),
+	}
+}
+
+func (c *Circadian) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil {
+		return
+	}
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go c.run(c.stop, c.done)
+}
+
+func (c *Circadian) Stop() {
+	c.mu.Lock()
+	stop, done := c.stop, c.done
+	c.stop, c.done = nil, nil
+	c.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (c *Circadian) Running() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stop != nil
+}
+
+// Pause suspends circadian control of the given lights for PauseTimeout, as
+// though they had been changed manually.
+func (c *Circadian) Pause(lights []hue.Light) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resume := time.Now().Add(c.PauseTimeout)
+	for _, light := range lights {
+		c.paused[light.Id] = resume
+		delete(c.applied, light.Id)
+	}
+}
+
+func (c *Circadian) Status() CircadianStatus {
+	ct, bri := c.Curve.At(time.Now())
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	paused := make(map[string]time.Time, len(c.paused))
+	for id, resume := range c.paused {
+		paused[id] = resume
+	}
+	return CircadianStatus{
+		Running:    c.stop != nil,
+		Curve:      c.Curve,
+		Lights:     c.Lights,
+		Interval:   c.Interval.String(),
+		ColorTemp:  ct,
+		Brightness: bri,
+		Paused:     paused,
+	}
+}
+
+func (c *Circadian) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		if err := c.step(time.Now()); err != nil {
+			log.WithField("error", err).Warn("Circadian update failed")
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Circadian) step(now time.Time) error {
+	lights, err := c.palette.GetLights()
+	if err != nil {
+		return err
+	}
+	lights = SelectLights(lights, c.Lights)
+
+	ct, bri := c.Curve.At(now)
+	transition := transitionTime(c.Interval)
+	target := hue.LightState{
+		ColorTemp:      &ct,
+		Brightness:     &bri,
+		TransitionTime: &transition,
+	}
+
+	update := make([]hue.Light, 0, len(lights))
+	for attrsOrErr := range c.palette.GetGroup(lights) {
+		if attrsOrErr.Error != nil {
+			err = attrsOrErr.Error
+			continue
+		}
+		if c.shouldUpdate(attrsOrErr.Light, attrsOrErr.State, now) {
+			update = append(update, attrsOrErr.Light)
+		}
+	}
+	if len(update) == 0 {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"ct":     ct,
+		"bri":    bri,
+		"lights": len(update),
+	}).Debug("Circadian update")
+	for setErr := range c.palette.SetGroupAs(Change{Caller: "circadian", Endpoint: "circadian"}, update, []hue.LightState{target}) {
+		if setErr != nil {
+			err = setErr
+		}
+	}
+	c.palette.Emit(EventScheduleFired, ScheduleEvent{Schedule: "circadian", Lights: LightIds(update), State: &target})
+
+	c.mu.Lock()
+	for _, light := range update {
+		c.applied[light.Id] = hue.LightState{ColorTemp: &ct, Brightness: &bri}
+	}
+	c.mu.Unlock()
+	return err
+}
+
+func (c *Circadian) shouldUpdate(light hue.Light, state *hue.LightState, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if resume, ok := c.paused[light.Id]; ok {
+		if now.Before(resume) {
+			return false
+		}
+		log.WithField("light", light.Id).Debug("Resuming circadian control")
+		delete(c.paused, light.Id)
+	}
+	if state == nil || state.On == nil || !*state.On {
+		delete(c.applied, light.Id)
+		return false
+	}
+	if applied, ok := c.applied[light.Id]; ok && manuallyChanged(applied, state) {
+		log.WithField("light", light.Id).Debug("Light changed manually, pausing circadian control")
+		c.paused[light.Id] = now.Add(c.PauseTimeout)
+		delete(c.applied, light.Id)
+		return false
+	}
+	return true
+}
+
+func manuallyChanged(applied hue.LightState, current *hue.LightState) bool {
+	if current.ColorMode != "" && current.ColorMode != "ct" {
+		return true
+	}
+	if current.ColorTemp == nil || current.Brightness == nil {
+		return true
+	}
+	return absDiff(int(*current.ColorTemp), int(*applied.ColorTemp)) > circadianTolerance ||
+		absDiff(int(*current.Brightness), int(*applied.Brightness)) > circadianTolerance
+}
+
+func absDiff(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,30 @@
+package palette
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircadianCurveValidate(t *testing.T) {
+	at := func(hour int) TimeOfDay {
+		return TimeOfDay(time.Duration(hour) * time.Hour)
+	}
+	tests := []struct {
+		name  string
+		curve CircadianCurve
+		ok    bool
+	}{
+		{"default", DefaultCircadianCurve, true},
+		{"limits", CircadianCurve{{at(0), MinColorTemp, 0}, {at(23), MaxColorTemp, 254}}, true},
+		{"empty", CircadianCurve{}, false},
+		{"too cool", CircadianCurve{{at(7), MinColorTemp - 1, 200}}, false},
+		{"too warm", CircadianCurve{{at(7), MaxColorTemp + 1, 200}}, false},
+		{"too bright", CircadianCurve{{at(7), 300, 200}, {at(12), 300, 255}}, false},
+		{"past midnight", CircadianCurve{{at(24), 300, 200}}, false},
+	}
+	for _, test := range tests {
+		if err := test.curve.Validate(); (err == nil) != test.ok {
+			t.Errorf("%s: got %v, want ok %t", test.name, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/BrianBland/palette"
+	"github.com/BrianBland/palette/audio"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const audioUsage = `usage: palette audio [-input -|file.wav|udp://127.0.0.1:port] [flags]
+
+Drives the lights from music: the bass sets their brightness and beats flash
+them, while the mids and treble turn their hues around their current scheme.
+Raw PCM is read from stdin or UDP in the -format, -rate and -channels given;
+WAV files describe their own format. For example:
+
+  ffmpeg -re -i song.mp3 -f s16le -ac 2 -ar 44100 - | palette audio
+  palette audio -input song.wav -lights 'living*'
+
+The lights get their previous states back when the audio ends or palette is
+interrupted. audio always talks to the bridge directly.`
+
+// audioFormats are the raw PCM formats, by their ffmpeg names.
+var audioFormats = map[string]audio.Format{
+	"u8":    {Bits: 8},
+	"s16le": {Bits: 16},
+	"s24le": {Bits: 24},
+	"s32le": {Bits: 32},
+	"f32le": {Bits: 32, Float: true},
+}
+
+func audioCommand(c config, args []string) {
+	flags := flag.NewFlagSet("audio", flag.ExitOnError)
+	input := flags.String("input", "-", "- for stdin, a WAV file, or udp://127.0.0.1:port")
+	format := flags.String("format", "s16le", "raw PCM sample format: u8, s16le, s24le, s32le or f32le")
+	rate := flags.Int("rate", audio.DefaultSampleRate, "raw PCM sample rate")
+	channels := flags.Int("channels", audio.DefaultChannels, "raw PCM channels")
+	lights := flags.String("lights", "", "comma separated light IDs, names or patterns; every light when omitted")
+	minBrightness := flags.Int("min-brightness", palette.DefaultReactiveMinBrightness, "brightness in silence, 0 to 254")
+	maxBrightness := flags.Int("max-brightness", palette.DefaultReactiveMaxBrightness, "brightness at the loudest bass and on beats, 0 to 254")
+	rotation := flags.Float64("rotation", palette.DefaultReactiveRotation, "degrees a second hues turn at the loudest mids")
+	sensitivity := flags.Float64("sensitivity", audio.DefaultBeatSensitivity, "how many times louder than average the bass must get for a beat")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, audioUsage)
+		fmt.Fprintln(os.Stderr, "\nflags:")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	pcm, ok := audioFormats[*format]
+	if flags.NArg() != 0 || !ok || *minBrightness < 0 || *maxBrightness > 254 ||
+		*minBrightness > *maxBrightness || *sensitivity <= 1 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+	if c.Server != "" {
+		fmt.Fprintln(os.Stderr, "palette: audio talks to the bridge directly and can't use -server")
+		os.Exit(exitUsage)
+	}
+	pcm.SampleRate, pcm.Channels = *rate, *channels
+
+	src, sampleRate, closer, err := openAudio(*input, pcm)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "palette:", err)
+		os.Exit(exitUsage)
+	}
+	defer closer.Close()
+
+	p := connect(c, true)
+	reactive := p.NewAudioReactive(p.ExpandLightSets(splitPatterns(*lights)))
+	reactive.MinBrightness = uint8(*minBrightness)
+	reactive.MaxBrightness = uint8(*maxBrightness)
+	reactive.Rotation = *rotation
+	analyzer := audio.NewAnalyzer(sampleRate)
+	analyzer.BeatSensitivity = *sensitivity
+
+	levels := make(chan audio.Levels, 16)
+	ended := make(chan error, 1)
+	go func() {
+		ended <- analyzer.Run(src, levels)
+	}()
+	failed := make(chan error, 1)
+	go func() {
+		failed <- reactive.Run()
+	}()
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+
+	for {
+		select {
+		case l := <-levels:
+			reactive.Update(l)
+			continue
+		case err = <-ended:
+			if err != nil {
+				log.WithField("error", err).Error("Failed to read audio")
+			}
+		case <-interrupted:
+		case err = <-failed:
+			if err == palette.ErrNoLights {
+				fmt.Fprintln(os.Stderr, "palette: no lights match")
+				os.Exit(exitNotFound)
+			}
+			fail(err)
+		}
+		break
+	}
+	reactive.Stop()
+	if err != nil {
+		os.Exit(exitFailure)
+	}
+}
+
+// openAudio opens the input, returning its samples and their rate.
+func openAudio(input string, format audio.Format) (audio.Source, int, io.Closer, error) {
+	if input == "-" {
+		r, err := audio.NewReader(os.Stdin, format)
+		return r, format.SampleRate, os.Stdin, err
+	}
+	if strings.HasPrefix(input, "udp://") {
+		conn, err := audio.ListenUDP(strings.TrimPrefix(input, "udp://"))
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		r, err := audio.NewReader(conn, format)
+		if err != nil {
+			conn.Close()
+		}
+		return r, format.SampleRate, conn, err
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	r, err := audio.NewWAVReader(f)
+	if err != nil {
+		f.Close()
+		return nil, 0, nil, fmt.Errorf("%s: %v", input, err)
+	}
+	// Files are read as fast as they would play, not as fast as they can be.
+	return audio.NewRealtime(r), r.Format.SampleRate, f, nil
+}
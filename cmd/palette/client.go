@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BrianBland/palette/server"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Exit statuses of the commands.
+const (
+	exitFailure      = 1
+	exitUsage        = 2
+	exitNotFound     = 3
+	exitUnauthorized = 4
+	exitUnavailable  = 5
+)
+
+const clientTimeout = 30 * time.Second
+
+// client calls the palette API, either on a running server or, without one,
+// in this process against the bridge directly, so commands behave the same
+// either way.
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+func newClient(c config) *client {
+	if c.Server != "" {
+		return &client{
+			base:  strings.TrimSuffix(c.Server, "/"),
+			token: c.Token,
+			http:  &http.Client{Timeout: clientTimeout},
+		}
+	}
+	s := server.New(connect(c, true))
+	return &client{
+		base: "http://palette",
+		http: &http.Client{Transport: s.LocalTransport()},
+	}
+}
+
+// apiError is an error response from the API.
+type apiError struct {
+	Status  int
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details []struct {
+		Light       string `json:"light"`
+		Bridge      string `json:"bridge"`
+		Field       string `json:"field"`
+		Description string `json:"description"`
+	} `json:"details"`
+}
+
+func (e *apiError) Error() string {
+	msg := e.Message
+	for _, detail := range e.Details {
+		if strings.Contains(e.Message, detail.Description) {
+			continue
+		}
+		var about []string
+		if detail.Bridge != "" {
+			about = append(about, "bridge "+detail.Bridge)
+		}
+		if detail.Light != "" {
+			about = append(about, "light "+detail.Light)
+		}
+		if detail.Field != "" {
+			about = append(about, detail.Field)
+		}
+		if len(about) > 0 {
+			msg += "\n  " + strings.Join(about, ", ") + ": " + detail.Description
+		} else {
+			msg += "\n  " + detail.Description
+		}
+	}
+	return msg
+}
+
+// unreachableError is a failure to reach the server at all.
+type unreachableError struct {
+	err error
+}
+
+func (e unreachableError) Error() string {
+	return "Failed to reach the server: " + e.err.Error()
+}
+
+// do sends body as JSON, when it isn't nil, and decodes the response into v,
+// when it isn't nil.
+func (c *client) do(method, path string, body, v interface{}) error {
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, c.base+path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	log.WithFields(log.Fields{
+		"method": method,
+		"path":   path,
+	}).Debug("Calling API")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return unreachableError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		var errResp struct {
+			Error apiError `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error.Message == "" {
+			errResp.Error.Message = resp.Status
+		}
+		errResp.Error.Status = resp.StatusCode
+		return &errResp.Error
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// exitStatus chooses the exit status for a command that failed with err.
+func exitStatus(err error) int {
+	switch e := err.(type) {
+	case unreachableError:
+		return exitUnavailable
+	case *apiError:
+		switch {
+		case e.Code == "bridge_unauthorized", e.Status == http.StatusUnauthorized, e.Status == http.StatusForbidden:
+			return exitUnauthorized
+		case e.Status == http.StatusNotFound:
+			return exitNotFound
+		case e.Status == http.StatusBadRequest, e.Status == 422:
+			return exitUsage
+		case e.Status == http.StatusBadGateway, e.Status == http.StatusServiceUnavailable, e.Status == http.StatusGatewayTimeout:
+			return exitUnavailable
+		}
+	}
+	return exitFailure
+}
+
+// fail reports err and exits with the matching status.
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "palette:", err)
+	os.Exit(exitStatus(err))
+}
+
+// pathEscape escapes a light or scene name for use as a path segment.
+func pathEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+// splitPatterns splits a comma separated list of light patterns.
+func splitPatterns(list string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(list, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// parseInterspersed parses flags wherever they appear among the arguments,
+// as in "scheme apply triad -color blue", returning the other arguments.
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var rest []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return rest
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+}
+
+// printJSON prints v as indented JSON.
+func printJSON(v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fail(err)
+	}
+	fmt.Println(string(b))
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BrianBland/palette"
+	"github.com/BrianBland/palette/mqtt"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const usage = `usage:
+  palette [flags] [listen address]
+  palette [flags] lights list|set ...
+  palette [flags] scheme list|apply ...
+  palette [flags] on|off [-lights patterns]
+  palette [flags] scene list|save|recall|delete ...
+  palette [flags] tui [-refresh 2s]
+  palette [flags] from-image <image> [lights...]
+  palette [flags] audio [-input -|file.wav|udp://127.0.0.1:port] [-lights patterns]
+  palette [flags] token list|create|revoke ...
+  palette [flags] discover [-all] [-json]
+  palette [flags] pair
+
+The lights, scheme, on, off, scene, tui and pair commands talk to the bridge
+directly, or with -server, to a running palette server; audio always talks to
+the bridge directly. Most take -json to print JSON instead of a table, and
+exit with:
+
+  0 on success, 1 on failure, 2 for invalid usage, 3 when a light, scene or
+  other resource isn't found, 4 when not authorized or not paired, and 5 when
+  the bridge or server can't be reached.
+
+Each setting is taken from, in increasing order of precedence: its default,
+the JSON config file given by -config, its PALETTE_* environment variable,
+and its flag. The config file uses the flag names as keys, in camel case:
+
+  {"listen": ":8080", "dir": "/var/lib/palette", "logLevel": "debug",
+   "transition": "1s", "rateLimit": 5}
+
+With -mqtt-broker, the server also publishes each light's state, retained, to
+<prefix>/lights/<id>/state, and takes the same JSON as the HTTP API on
+<prefix>/lights/<id>/set, <prefix>/scheme/set and <prefix>/scene/recall.
+<prefix>/status is "online" while palette is connected, and "offline" after.
+
+The bridge credentials, scenes and tokens are kept in palette.json in -dir,
+separately from the config file.
+
+flags:`
+
+// config holds the settings for the server and commands.
+type config struct {
+	Listen           string  `json:"listen,omitempty"`
+	Dir              string  `json:"dir,omitempty"`
+	Bridge           string  `json:"bridge,omitempty"`
+	DiscoveryTimeout string  `json:"discoveryTimeout,omitempty"`
+	PairingWindow    string  `json:"pairingWindow,omitempty"`
+	LogLevel         string  `json:"logLevel,omitempty"`
+	LogFormat        string  `json:"logFormat,omitempty"`
+	StaticDir        string  `json:"staticDir,omitempty"`
+	Transition       string  `json:"transition,omitempty"`
+	RateLimit        float64 `json:"rateLimit,omitempty"`
+	RateBurst        int     `json:"rateBurst,omitempty"`
+	BridgeRateLimit  float64 `json:"bridgeRateLimit,omitempty"`
+	Server           string  `json:"server,omitempty"`
+	Token            string  `json:"token,omitempty"`
+	MQTTBroker       string  `json:"mqttBroker,omitempty"`
+	MQTTClientID     string  `json:"mqttClientId,omitempty"`
+	MQTTUsername     string  `json:"mqttUsername,omitempty"`
+	MQTTPassword     string  `json:"mqttPassword,omitempty"`
+	MQTTPrefix       string  `json:"mqttPrefix,omitempty"`
+}
+
+var defaults = config{
+	Listen:           ":8080",
+	Dir:              ".",
+	DiscoveryTimeout: palette.DefaultDiscoveryTimeout.String(),
+	PairingWindow:    palette.DefaultPairingWindow.String(),
+	LogLevel:         "info",
+	LogFormat:        "text",
+	// The bridge handles about 10 light commands a second before it starts
+	// dropping them.
+	BridgeRateLimit: 10,
+	MQTTClientID:    "palette",
+	MQTTPrefix:      "palette",
+}
+
+// setting ties a config field to its flag and environment variable.
+type setting struct {
+	name  string
+	usage string
+	get   func(c *config) string
+	set   func(c *config, value string) error
+}
+
+// env is the environment variable for the setting, like PALETTE_LOG_LEVEL
+// for -log-level.
+func (s setting) env() string {
+	return "PALETTE_" + strings.ToUpper(strings.Replace(s.name, "-", "_", -1))
+}
+
+func stringSetting(name, usage string, field func(c *config) *string) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		get:   func(c *config) string { return *field(c) },
+		set: func(c *config, value string) error {
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+func floatSetting(name, usage string, field func(c *config) *float64) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		get:   func(c *config) string { return strconv.FormatFloat(*field(c), 'g', -1, 64) },
+		set: func(c *config, value string) (err error) {
+			*field(c), err = strconv.ParseFloat(value, 64)
+			return err
+		},
+	}
+}
+
+func intSetting(name, usage string, field func(c *config) *int) setting {
+	return setting{
+		name:  name,
+		usage: usage,
+		get:   func(c *config) string { return strconv.Itoa(*field(c)) },
+		set: func(c *config, value string) (err error) {
+			*field(c), err = strconv.Atoi(value)
+			return err
+		},
+	}
+}
+
+var settings = []setting{
+	stringSetting("listen", "address for the server to listen on",
+		func(c *config) *string { return &c.Listen }),
+	stringSetting("dir", "directory for palette.json and the audit log",
+		func(c *config) *string { return &c.Dir }),
+	stringSetting("bridge", "comma separated bridge addresses, skipping discovery",
+		func(c *config) *string { return &c.Bridge }),
+	stringSetting("discovery-timeout", "how long each bridge discovery method may take",
+		func(c *config) *string { return &c.DiscoveryTimeout }),
+	stringSetting("pairing-window", "how long pairing waits for a bridge's link button to be pressed",
+		func(c *config) *string { return &c.PairingWindow }),
+	stringSetting("log-level", "debug, info, warning or error",
+		func(c *config) *string { return &c.LogLevel }),
+	stringSetting("log-format", "text or json",
+		func(c *config) *string { return &c.LogFormat }),
+	stringSetting("static-dir", "serve the web UI from this directory instead of the built-in copy",
+		func(c *config) *string { return &c.StaticDir }),
+	stringSetting("transition", "default transition for changes that don't give one, like 1s",
+		func(c *config) *string { return &c.Transition }),
+	floatSetting("rate-limit", "requests a second allowed per API client, or 0 for no limit",
+		func(c *config) *float64 { return &c.RateLimit }),
+	intSetting("rate-burst", "requests an API client may make at once; 0 means the rate limit",
+		func(c *config) *int { return &c.RateBurst }),
+	floatSetting("bridge-rate-limit", "light commands a second sent to the bridge, or 0 for no limit",
+		func(c *config) *float64 { return &c.BridgeRateLimit }),
+	stringSetting("server", "URL of a running palette server for commands to use instead of the bridge",
+		func(c *config) *string { return &c.Server }),
+	stringSetting("token", "API token for -server; prefer the environment variable, which others can't see",
+		func(c *config) *string { return &c.Token }),
+	stringSetting("mqtt-broker", "MQTT broker for the server to publish light state to and take commands from, like tcp://localhost:1883",
+		func(c *config) *string { return &c.MQTTBroker }),
+	stringSetting("mqtt-client-id", "client ID to connect to the MQTT broker with",
+		func(c *config) *string { return &c.MQTTClientID }),
+	stringSetting("mqtt-username", "user name for the MQTT broker",
+		func(c *config) *string { return &c.MQTTUsername }),
+	stringSetting("mqtt-password", "password for the MQTT broker; prefer the environment variable",
+		func(c *config) *string { return &c.MQTTPassword }),
+	stringSetting("mqtt-prefix", "prefix of the MQTT topics, as in palette/lights/1/state",
+		func(c *config) *string { return &c.MQTTPrefix }),
+}
+
+// loadConfig parses the command line flags and merges them with the config
+// file and environment. The remaining arguments are left in flag.Args.
+func loadConfig(args []string) (config, error) {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	configFile := flag.String("config", "", "JSON config file (env PALETTE_CONFIG)")
+	for _, s := range settings {
+		flag.String(s.name, s.get(&defaults), s.usage+" (env "+s.env()+")")
+	}
+	flag.CommandLine.Parse(args)
+
+	c := defaults
+	path := os.Getenv("PALETTE_CONFIG")
+	if *configFile != "" {
+		path = *configFile
+	}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return c, err
+		}
+		if err := json.Unmarshal(b, &c); err != nil {
+			return c, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	byName := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byName[s.name] = s
+		if value := os.Getenv(s.env()); value != "" {
+			if err := s.set(&c, value); err != nil {
+				return c, fmt.Errorf("%s: %v", s.env(), err)
+			}
+		}
+	}
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		if s, ok := byName[f.Name]; ok && err == nil {
+			if setErr := s.set(&c, f.Value.String()); setErr != nil {
+				err = fmt.Errorf("-%s: %v", f.Name, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return c, err
+	}
+	return c, c.validate()
+}
+
+func (c config) validate() error {
+	if _, err := log.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("log level: %v", err)
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("log format: %q is not text or json", c.LogFormat)
+	}
+	if _, err := c.discoveryTimeout(); err != nil {
+		return fmt.Errorf("discovery timeout: %v", err)
+	}
+	if _, err := c.pairingWindow(); err != nil {
+		return fmt.Errorf("pairing window: %v", err)
+	}
+	if _, err := c.transition(); err != nil {
+		return fmt.Errorf("transition: %v", err)
+	}
+	if c.RateLimit < 0 || c.RateBurst < 0 || c.BridgeRateLimit < 0 {
+		return fmt.Errorf("rate limits can't be negative")
+	}
+	if c.Server != "" {
+		if u, err := url.Parse(c.Server); err != nil || u.Host == "" {
+			return fmt.Errorf("server: %q is not a URL like http://localhost:8080", c.Server)
+		}
+	}
+	if c.MQTTBroker != "" {
+		if _, err := mqtt.Address(c.MQTTBroker); err != nil {
+			return fmt.Errorf("mqtt broker: %v", err)
+		}
+		if c.MQTTPrefix == "" || strings.ContainsAny(c.MQTTPrefix, "+#") {
+			return fmt.Errorf("mqtt prefix: %q is empty or has a wildcard", c.MQTTPrefix)
+		}
+	}
+	return nil
+}
+
+// mqttOptions returns the options for connecting to the MQTT broker.
+func (c config) mqttOptions() mqtt.Options {
+	opts := mqtt.NewOptions(c.MQTTBroker, c.MQTTClientID)
+	opts.Username = c.MQTTUsername
+	opts.Password = c.MQTTPassword
+	return opts
+}
+
+// transition returns the default transition, or a negative duration if none
+// is set.
+func (c config) transition() (time.Duration, error) {
+	if c.Transition == "" {
+		return -1, nil
+	}
+	d, err := time.ParseDuration(c.Transition)
+	if err == nil && d < 0 {
+		err = fmt.Errorf("%q is negative", c.Transition)
+	}
+	return d, err
+}
+
+func (c config) discoveryTimeout() (time.Duration, error) {
+	return positiveDuration(c.DiscoveryTimeout)
+}
+
+func (c config) pairingWindow() (time.Duration, error) {
+	return positiveDuration(c.PairingWindow)
+}
+
+func positiveDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("%q is not positive", s)
+	}
+	return d, err
+}
+
+// discovery finds the configured bridges, or discovers them.
+func (c config) discovery(p *palette.Palette) *palette.Discovery {
+	d := palette.NewDiscovery(c.bridgeAddresses(), p.CachedBridges())
+	d.Timeout, _ = c.discoveryTimeout()
+	return d
+}
+
+// bridgeAddresses returns the configured bridges' addresses as URLs, as
+// go-hue expects.
+func (c config) bridgeAddresses() []string {
+	var addrs []string
+	for _, addr := range strings.Split(c.Bridge, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if !strings.Contains(addr, "://") {
+			addr = "http://" + addr
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+func (c config) configureLogging() {
+	level, _ := log.ParseLevel(c.LogLevel)
+	log.SetLevel(level)
+	if c.LogFormat == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/BrianBland/palette"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const discoverUsage = `usage: palette discover [-all] [-json]`
+
+// discover lists the bridges found the way the server finds them, or with
+// -all, by every method.
+func discover(c config, args []string) {
+	flags := flag.NewFlagSet("discover", flag.ExitOnError)
+	all := flags.Bool("all", false, "try every discovery method instead of stopping at the first that finds bridges")
+	asJSON := flags.Bool("json", false, "print the bridges as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, discoverUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	p, err := palette.LoadFromConfig(c.Dir)
+	if err != nil {
+		log.Fatal("Failed to load config: ", err)
+	}
+	d := c.discovery(p)
+	d.All = *all
+	bridges, err := d.Run()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUnavailable)
+	}
+
+	if *asJSON {
+		b, _ := json.MarshalIndent(bridges, "", "  ")
+		fmt.Println(string(b))
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tADDRESS\tMETHOD\tPAIRED")
+	for _, bridge := range bridges {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", bridge.Id, bridge.Address, bridge.Method, p.Paired(bridge.Id))
+	}
+	w.Flush()
+}
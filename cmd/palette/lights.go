@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/BrianBland/palette"
+
+	"github.com/BrianBland/go-hue"
+)
+
+const lightsUsage = `usage:
+  palette lights list [-json] [patterns...]
+  palette lights set <id> [-color c] [-brightness n] [-hue n] [-saturation n] [-ct n] [-on] [-off] [-json]`
+
+type lightsResponse struct {
+	Lights []palette.LightStatus `json:"lights"`
+}
+
+// lights lists and sets lights:
+//
+//	palette lights list 'desk*'
+//	palette lights set 3 -color orange -brightness 200
+func lights(c config, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, lightsUsage)
+		os.Exit(exitUsage)
+	}
+	switch args[0] {
+	case "list":
+		listLights(c, args[1:])
+	case "set":
+		setLight(c, args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, lightsUsage)
+		os.Exit(exitUsage)
+	}
+}
+
+func listLights(c config, args []string) {
+	flags := flag.NewFlagSet("lights list", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the lights as JSON")
+	patterns := parseInterspersed(flags, args)
+
+	var resp lightsResponse
+	if err := newClient(c).do("GET", "/lights", nil, &resp); err != nil {
+		fail(err)
+	}
+	if len(patterns) > 0 {
+		var selected []palette.LightStatus
+		for _, status := range resp.Lights {
+			if len(palette.SelectLights([]hue.Light{{Id: status.Id, Name: status.Name}}, patterns)) > 0 {
+				selected = append(selected, status)
+			}
+		}
+		if len(selected) == 0 {
+			fmt.Fprintln(os.Stderr, "palette: no lights match")
+			os.Exit(exitNotFound)
+		}
+		resp.Lights = selected
+	}
+	printLights(resp, *asJSON)
+}
+
+// setLight sends only the settings given as flags.
+func setLight(c config, args []string) {
+	flags := flag.NewFlagSet("lights set", flag.ExitOnError)
+	flags.String("color", "", "color as #rrggbb or by name")
+	flags.Int("brightness", 0, "brightness, 1 to 254")
+	flags.Int("hue", 0, "hue, 0 to 65535")
+	flags.Int("saturation", 0, "saturation, 0 to 254")
+	flags.Int("ct", 0, "color temperature in mireds, 153 to 500")
+	flags.Bool("on", false, "turn the light on")
+	flags.Bool("off", false, "turn the light off")
+	asJSON := flags.Bool("json", false, "print the lights as JSON")
+	ids := parseInterspersed(flags, args)
+	if len(ids) != 1 {
+		fmt.Fprintln(os.Stderr, lightsUsage)
+		os.Exit(exitUsage)
+	}
+
+	req := make(map[string]interface{})
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "json":
+		case "on":
+			req["on"] = f.Value.(flag.Getter).Get().(bool)
+		case "off":
+			req["on"] = !f.Value.(flag.Getter).Get().(bool)
+		default:
+			req[f.Name] = f.Value.(flag.Getter).Get()
+		}
+	})
+	if len(req) == 0 {
+		fmt.Fprintln(os.Stderr, "palette: nothing to set")
+		os.Exit(exitUsage)
+	}
+	var resp lightsResponse
+	if err := newClient(c).do("PUT", "/lights/"+pathEscape(ids[0]), req, &resp); err != nil {
+		fail(err)
+	}
+	printLights(resp, *asJSON)
+}
+
+func printLights(resp lightsResponse, asJSON bool) {
+	if asJSON {
+		printJSON(resp)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tON\tBRI\tCOLOR\tREACHABLE")
+	for _, light := range resp.Lights {
+		on := light.On != nil && *light.On
+		bri := "-"
+		if light.Brightness != nil {
+			bri = strconv.Itoa(int(*light.Brightness))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\t%t\n", light.Id, light.Name, on, bri, light.Color, light.Reachable)
+	}
+	w.Flush()
+}
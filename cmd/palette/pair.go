@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BrianBland/palette"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// pair waits for someone to press the bridge's link button. Interactive
+// commands prompt for it and wait; the server starts waiting in the
+// background and carries on.
+func pair(c config, p *palette.Palette, bridge palette.DiscoveredBridge, interactive bool) error {
+	window, _ := c.pairingWindow()
+	if !interactive {
+		log.WithFields(log.Fields{
+			"bridge":  bridge.Id,
+			"address": bridge.Address,
+		}).Warnf("Press the bridge's link button within %s, or pair from the /setup page", window)
+		return p.StartPairing(bridge.Id, window)
+	}
+	fmt.Fprintf(os.Stderr, "Press the link button on the bridge at %s to pair with it (waiting %s)...\n", bridge.Address, window)
+	if err := p.Pair(bridge, window); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Paired with bridge %s.\n", bridge.Id)
+	return nil
+}
+
+// pairCommand pairs with every bridge found that palette has no working
+// credentials for, or with -server, every bridge the server is waiting to pair
+// with:
+//
+//	palette pair
+func pairCommand(c config, args []string) {
+	flags := flag.NewFlagSet("pair", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the bridges as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: palette pair [-json]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	var statuses []palette.PairingStatus
+	if c.Server != "" {
+		statuses = pairRemote(c)
+	} else {
+		p := connect(c, true)
+		addresses := make(map[string]string)
+		for _, bridge := range p.CachedBridges() {
+			addresses[bridge.Id] = bridge.Address
+		}
+		for _, id := range p.Bridges() {
+			statuses = append(statuses, palette.PairingStatus{
+				Bridge: palette.DiscoveredBridge{Id: id, Address: addresses[id]},
+				State:  palette.PairingPaired,
+			})
+		}
+	}
+
+	if *asJSON {
+		printJSON(pairingResponse{Bridges: statuses})
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "BRIDGE\tADDRESS\tSTATE\tERROR")
+		for _, status := range statuses {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status.Bridge.Id, status.Bridge.Address, status.State, status.Error)
+		}
+		w.Flush()
+	}
+	for _, status := range statuses {
+		if status.State != palette.PairingPaired {
+			os.Exit(exitUnauthorized)
+		}
+	}
+}
+
+type pairingResponse struct {
+	Bridges []palette.PairingStatus `json:"bridges"`
+}
+
+// pairRemote starts the server pairing with the bridges it's waiting on, and
+// waits for it to finish.
+func pairRemote(c config) []palette.PairingStatus {
+	client := newClient(c)
+	var resp pairingResponse
+	if err := client.do("GET", "/pairing", nil, &resp); err != nil {
+		fail(err)
+	}
+	for _, status := range resp.Bridges {
+		if status.State == palette.PairingPaired || status.State == palette.PairingWaiting {
+			continue
+		}
+		if err := client.do("POST", "/pairing/"+pathEscape(status.Bridge.Id), nil, &resp); err != nil {
+			fail(err)
+		}
+		fmt.Fprintf(os.Stderr, "Press the link button on the bridge at %s to pair with it...\n", status.Bridge.Address)
+	}
+	for waiting(resp.Bridges) {
+		time.Sleep(2 * time.Second)
+		if err := client.do("GET", "/pairing", nil, &resp); err != nil {
+			fail(err)
+		}
+	}
+	return resp.Bridges
+}
+
+func waiting(statuses []palette.PairingStatus) bool {
+	for _, status := range statuses {
+		if status.State == palette.PairingWaiting {
+			return true
+		}
+	}
+	return false
+}
@@ -1,43 +1,179 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
 	"os"
+	"strings"
 
 	"github.com/BrianBland/palette"
 	"github.com/BrianBland/palette/server"
 
-	"github.com/BrianBland/go-hue"
 	log "github.com/Sirupsen/logrus"
 )
 
 func main() {
-	addr := ":8080"
-	if len(os.Args) > 1 {
-		addr = os.Args[1]
+	c, err := loadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "palette:", err)
+		os.Exit(2)
+	}
+	c.configureLogging()
+
+	args := flag.Args()
+	if len(args) > 0 {
+		switch args[0] {
+		case "lights":
+			lights(c, args[1:])
+			return
+		case "scheme":
+			scheme(c, args[1:])
+			return
+		case "on", "off":
+			power(c, args[0], args[1:])
+			return
+		case "scene":
+			scene(c, args[1:])
+			return
+		case "tui":
+			tuiCommand(c, args[1:])
+			return
+		case "from-image":
+			fromImage(c, args[1:])
+			return
+		case "audio":
+			audioCommand(c, args[1:])
+			return
+		case "token":
+			token(c, args[1:])
+			return
+		case "discover":
+			discover(c, args[1:])
+			return
+		case "pair":
+			pairCommand(c, args[1:])
+			return
+		}
+	}
+
+	// The listen address used to be the only argument, and is still accepted
+	// in place of -listen.
+	if len(args) > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if len(args) == 1 {
+		c.Listen = args[0]
+	}
+	s := server.New(connect(c, false))
+	s.StaticDir = c.StaticDir
+	s.RateLimit = c.RateLimit
+	s.RateBurst = c.RateBurst
+	s.PairingWindow, _ = c.pairingWindow()
+	s.RestoreSchedules()
+	s.EnableWebhooks()
+	if c.MQTTBroker != "" {
+		go s.ServeMQTT(c.mqttOptions(), strings.TrimSuffix(c.MQTTPrefix, "/"))
 	}
-	bridges, err := hue.FindBridgesUsingCloud()
+	log.Fatal(s.ListenAndServe(c.Listen))
+}
+
+// connect connects to the configured bridges or, without any, the
+// discovered ones, pairing with any that palette has no credentials for.
+// Interactive commands wait for the link button to be pressed, prompting on
+// the terminal; the server waits in the background, so pairing can also be
+// finished from its setup page.
+func connect(c config, interactive bool) *palette.Palette {
+	p, err := palette.LoadFromConfig(c.Dir)
 	if err != nil {
-		log.Fatal("Failed to find bridge:", err)
+		log.Fatal("Failed to load config:", err)
 	}
-	if len(bridges) == 0 {
-		log.Fatal("No bridges found")
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		log.Fatal("Failed to create config directory:", err)
 	}
-	log.Print("Found bridges:", bridges)
-	bridge := bridges[0]
 
-	p, err := palette.LoadFromConfig(bridge)
+	bridges, err := c.discovery(p).Run()
 	if err != nil {
-		log.Print("Failed to load config, making new user. Error:", err)
-		p, err = palette.New(bridge)
-		if err != nil {
-			log.Fatal("Failed to create new config:", err)
+		log.Error("Failed to find bridge: ", err)
+		os.Exit(exitUnavailable)
+	}
+	for _, bridge := range bridges {
+		log.WithFields(log.Fields{
+			"bridge":  bridge.Id,
+			"address": bridge.Address,
+			"method":  bridge.Method,
+		}).Info("Found bridge")
+		err := p.Connect(bridge)
+		if err == palette.ErrLinkButtonNotPressed {
+			err = pair(c, p, bridge, interactive)
 		}
-		err = p.SaveToConfig()
 		if err != nil {
-			log.Fatal("Failed to save config:", err)
+			log.WithFields(log.Fields{
+				"bridge": bridge.Id,
+				"error":  err,
+			}).Error("Failed to connect to bridge")
 		}
 	}
+	if len(p.Bridges()) == 0 && (interactive || len(p.Pairing()) == 0) {
+		log.Error("Failed to connect to any bridge")
+		if len(p.Pairing()) > 0 {
+			os.Exit(exitUnauthorized)
+		}
+		os.Exit(exitUnavailable)
+	}
+	if transition, _ := c.transition(); transition >= 0 {
+		p.SetDefaultTransition(transition)
+	}
+	p.SetCommandRate(c.BridgeRateLimit, int(math.Ceil(c.BridgeRateLimit)))
+	return p
+}
+
+// fromImage sets the lights to a palette extracted from an image:
+//
+//	palette from-image photo.jpg [light patterns...]
+func fromImage(c config, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "usage: palette from-image <image> [lights...]")
+		os.Exit(2)
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		log.Fatal("Failed to open image:", err)
+	}
+	img, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		log.Fatal("Failed to decode image:", err)
+	}
 
-	s := server.New(p)
-	log.Fatal(s.ListenAndServe(addr))
+	p := connect(c, true)
+	lights, err := p.GetLights()
+	if err != nil {
+		log.Fatal("Failed to get lights:", err)
+	}
+	lights = palette.SelectLights(lights, args[1:])
+	swatches := palette.ExtractSwatches(img, len(lights))
+	if len(swatches) == 0 {
+		log.Fatal("No colors found in image")
+	}
+	states := palette.StatesFromSwatches(swatches)
+	for i, light := range lights {
+		fmt.Printf("%s\t%s\t%s\n", light.Id, light.Name, swatches[i%len(swatches)].Hex)
+	}
+	failed := false
+	change := palette.Change{Caller: "cli", Endpoint: "from-image", Request: args}
+	for err := range p.SetGroupAs(change, lights, states) {
+		if err != nil {
+			log.Error("Failed to set light: ", err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
 }
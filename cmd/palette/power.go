@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// power turns lights on or off:
+//
+//	palette on -lights 'desk*'
+//	palette off
+func power(c config, command string, args []string) {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	lights := flags.String("lights", "", "comma separated light IDs, names or patterns; every light when omitted")
+	asJSON := flags.Bool("json", false, "print the lights as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: palette %s [-lights patterns] [-json]\n", command)
+		flags.PrintDefaults()
+	}
+	if rest := parseInterspersed(flags, args); len(rest) != 0 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	var req interface{}
+	if patterns := splitPatterns(*lights); len(patterns) > 0 {
+		req = map[string][]string{"lights": patterns}
+	}
+	var resp lightsResponse
+	if err := newClient(c).do("PUT", "/"+command, req, &resp); err != nil {
+		fail(err)
+	}
+	printLights(resp, *asJSON)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BrianBland/palette"
+)
+
+const sceneUsage = `usage:
+  palette scene list [-json]
+  palette scene save <name> [-lights patterns] [-json]
+  palette scene recall <name> [-json]
+  palette scene delete <name> [-json]`
+
+type scenesResponse struct {
+	Scenes []palette.Scene `json:"scenes"`
+}
+
+// scene saves the current state of lights as a named scene and recalls it:
+//
+//	palette scene save evening -lights 'living*'
+//	palette scene recall evening
+func scene(c config, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, sceneUsage)
+		os.Exit(exitUsage)
+	}
+	command := args[0]
+	flags := flag.NewFlagSet("scene "+command, flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the result as JSON")
+	var lights *string
+	if command == "save" {
+		lights = flags.String("lights", "", "comma separated light IDs, names or patterns; every light when omitted")
+	}
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, sceneUsage)
+		flags.PrintDefaults()
+	}
+	rest := parseInterspersed(flags, args[1:])
+	wantArgs := 1
+	if command == "list" {
+		wantArgs = 0
+	}
+	if len(rest) != wantArgs {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	switch command {
+	case "list":
+		var resp scenesResponse
+		if err := newClient(c).do("GET", "/scenes", nil, &resp); err != nil {
+			fail(err)
+		}
+		printScenes(resp.Scenes, *asJSON)
+	case "save":
+		var req interface{}
+		if patterns := splitPatterns(*lights); len(patterns) > 0 {
+			req = map[string][]string{"lights": patterns}
+		}
+		var saved palette.Scene
+		if err := newClient(c).do("PUT", "/scenes/"+pathEscape(rest[0]), req, &saved); err != nil {
+			fail(err)
+		}
+		printScenes([]palette.Scene{saved}, *asJSON)
+	case "recall":
+		var resp lightsResponse
+		if err := newClient(c).do("PUT", "/scenes/"+pathEscape(rest[0])+"/recall", nil, &resp); err != nil {
+			fail(err)
+		}
+		printLights(resp, *asJSON)
+	case "delete":
+		var resp scenesResponse
+		if err := newClient(c).do("DELETE", "/scenes/"+pathEscape(rest[0]), nil, &resp); err != nil {
+			fail(err)
+		}
+		printScenes(resp.Scenes, *asJSON)
+	default:
+		fmt.Fprintln(os.Stderr, sceneUsage)
+		os.Exit(exitUsage)
+	}
+}
+
+func printScenes(scenes []palette.Scene, asJSON bool) {
+	if asJSON {
+		printJSON(scenesResponse{Scenes: scenes})
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tLIGHTS")
+	for _, scene := range scenes {
+		ids := make([]string, 0, len(scene.Lights))
+		for id := range scene.Lights {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		fmt.Fprintf(w, "%s\t%s\n", scene.Name, strings.Join(ids, ","))
+	}
+	w.Flush()
+}
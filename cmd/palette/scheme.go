@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BrianBland/palette"
+)
+
+const schemeUsage = `usage:
+  palette scheme list
+  palette scheme apply [scheme] [-color c] [-brightness n] [-saturation n] [-seed s] [-lights patterns] [-json]`
+
+// scheme lists the color schemes, or sets lights to one, generating the
+// colors the server would for a /palette request:
+//
+//	palette scheme apply triad -color blue -lights 'desk*'
+func scheme(c config, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, schemeUsage)
+		os.Exit(exitUsage)
+	}
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, schemeUsage)
+			os.Exit(exitUsage)
+		}
+		for _, name := range palette.SchemeNames {
+			fmt.Println(name)
+		}
+	case "apply":
+		applyScheme(c, args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, schemeUsage)
+		os.Exit(exitUsage)
+	}
+}
+
+func applyScheme(c config, args []string) {
+	flags := flag.NewFlagSet("scheme apply", flag.ExitOnError)
+	flags.String("color", "", "primary color by name or as #rrggbb; generated when omitted")
+	flags.Int("brightness", 0, "brightness, 1 to 254")
+	flags.Int("saturation", 0, "saturation, 0 to 254")
+	flags.String("seed", "", "derive any unset scheme, color, saturation and brightness from this string")
+	lights := flags.String("lights", "", "comma separated light IDs, names or patterns; every light when omitted")
+	asJSON := flags.Bool("json", false, "print the lights as JSON")
+	rest := parseInterspersed(flags, args)
+	if len(rest) > 1 {
+		fmt.Fprintln(os.Stderr, schemeUsage)
+		os.Exit(exitUsage)
+	}
+
+	req := make(map[string]interface{})
+	if len(rest) == 1 {
+		req["palette"] = strings.ToLower(rest[0])
+	}
+	if patterns := splitPatterns(*lights); len(patterns) > 0 {
+		req["lights"] = patterns
+	}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "color", "seed":
+			req[f.Name] = f.Value.String()
+		case "brightness", "saturation":
+			req[f.Name] = f.Value.(flag.Getter).Get()
+		}
+	})
+	var resp lightsResponse
+	if err := newClient(c).do("PUT", "/palette", req, &resp); err != nil {
+		fail(err)
+	}
+	printLights(resp, *asJSON)
+}
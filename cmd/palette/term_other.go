@@ -0,0 +1,24 @@
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+type terminal struct{}
+
+func openTerminal(f *os.File) (*terminal, error) {
+	return nil, errors.New("the terminal UI isn't supported on this system")
+}
+
+func (t *terminal) restore() error {
+	return nil
+}
+
+func (t *terminal) size() (int, int, error) {
+	return 0, 0, errors.New("the terminal UI isn't supported on this system")
+}
+
+func notifyResize(c chan<- os.Signal) {}
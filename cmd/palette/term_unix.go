@@ -0,0 +1,63 @@
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// terminal is a terminal put into raw mode, so keys are read as they're
+// pressed, without echo.
+type terminal struct {
+	fd       uintptr
+	original syscall.Termios
+}
+
+func openTerminal(f *os.File) (*terminal, error) {
+	t := &terminal{fd: f.Fd()}
+	if err := ioctl(t.fd, ioctlGetTermios, unsafe.Pointer(&t.original)); err != nil {
+		return nil, err
+	}
+	raw := t.original
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(t.fd, ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// restore puts the terminal back the way it was.
+func (t *terminal) restore() error {
+	return ioctl(t.fd, ioctlSetTermios, unsafe.Pointer(&t.original))
+}
+
+// size returns the terminal's width and height.
+func (t *terminal) size() (int, int, error) {
+	var ws struct {
+		rows, cols, xpixel, ypixel uint16
+	}
+	if err := ioctl(t.fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return 0, 0, err
+	}
+	return int(ws.cols), int(ws.rows), nil
+}
+
+// notifyResize sends on c when the terminal is resized.
+func notifyResize(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGWINCH)
+}
+
+func ioctl(fd, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BrianBland/palette"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const tokenUsage = `usage:
+  palette token list
+  palette token create [-scope read|control|admin] [-lights patterns] <name>
+  palette token revoke <name>`
+
+// token manages the API tokens the server accepts. A running server using the
+// same config directory picks up changes made here within a second or so.
+func token(c config, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, tokenUsage)
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "list":
+		listTokens(loadPalette(c))
+	case "create":
+		createToken(c, args[1:])
+	case "revoke":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, tokenUsage)
+			os.Exit(2)
+		}
+		if err := loadPalette(c).RevokeToken(args[1]); err != nil {
+			log.Fatal("Failed to revoke token: ", err)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, tokenUsage)
+		os.Exit(2)
+	}
+}
+
+// loadPalette loads the config without connecting to a bridge, so tokens can
+// be managed while the bridge is unreachable.
+func loadPalette(c config) *palette.Palette {
+	p, err := palette.LoadFromConfig(c.Dir)
+	if err != nil {
+		log.Fatal("Failed to load config: ", err)
+	}
+	return p
+}
+
+func listTokens(p *palette.Palette) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSCOPE\tLIGHTS\tCREATED")
+	for _, t := range p.Tokens() {
+		lights := strings.Join(t.Lights, ",")
+		if lights == "" {
+			lights = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Name, t.Scope, lights, t.Created.Format("2006-01-02 15:04"))
+	}
+	w.Flush()
+}
+
+func createToken(c config, args []string) {
+	flags := flag.NewFlagSet("token create", flag.ExitOnError)
+	scope := flags.String("scope", palette.ScopeControl, "what the token may do: read, control or admin")
+	lights := flags.String("lights", "", "comma separated light IDs or names the token is limited to")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, tokenUsage)
+		os.Exit(2)
+	}
+
+	secret, _, err := loadPalette(c).CreateToken(flags.Arg(0), *scope, splitPatterns(*lights))
+	if err != nil {
+		log.Fatal("Failed to create token: ", err)
+	}
+	fmt.Println(secret)
+	fmt.Fprintln(os.Stderr, "Save this token now; it can't be shown again.")
+}
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BrianBland/palette"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const tuiUsage = `usage: palette tui [-refresh 2s]
+
+keys:
+  up/down, j/k     move between lights
+  space            select the light; a selects them all
+  left/right, h/l  rotate hue
+  -/+              brightness
+  [/]              saturation
+  p                toggle power
+  s/S              pick the next or previous scheme
+  enter            apply the scheme to the selected lights, or every light,
+                   from the color of the light under the cursor
+  r                refresh
+  q                quit`
+
+const (
+	hueStep        = 65536 / 36
+	brightnessStep = 16
+	saturationStep = 16
+)
+
+// Keys other than printable characters.
+const (
+	keyUp rune = -1 - iota
+	keyDown
+	keyLeft
+	keyRight
+	keyEnter
+	keyInterrupt
+)
+
+// ANSI escape sequences.
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	clearScreen = "\x1b[H\x1b[2J"
+	bold        = "\x1b[1m"
+	dim         = "\x1b[2m"
+	reverse     = "\x1b[7m"
+	reset       = "\x1b[0m"
+)
+
+// tuiCommand runs a full screen terminal interface for the lights:
+//
+//	palette tui
+func tuiCommand(c config, args []string) {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	refresh := flags.Duration("refresh", 2*time.Second, "how often to refresh the lights")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, tuiUsage)
+		fmt.Fprintln(os.Stderr, "\nflags:")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 || *refresh <= 0 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	// Connect first, so any pairing prompt is shown on the normal screen.
+	t := &tui{client: newClient(c), selected: make(map[string]bool)}
+	if err := t.refresh(); err != nil {
+		fail(err)
+	}
+	term, err := openTerminal(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "palette: tui needs a terminal:", err)
+		os.Exit(exitFailure)
+	}
+	t.term = term
+	// Logs would scribble over the screen; failures are shown in the status
+	// line instead.
+	log.SetOutput(ioutil.Discard)
+	fmt.Print(enterScreen)
+	defer func() {
+		fmt.Print(leaveScreen)
+		term.restore()
+		log.SetOutput(os.Stderr)
+	}()
+	t.run(*refresh)
+}
+
+// tui is the state of the terminal interface.
+type tui struct {
+	client    *client
+	term      *terminal
+	lights    []palette.LightStatus
+	cursor    int
+	top       int
+	selected  map[string]bool
+	scheme    int
+	status    string
+	refreshed time.Time
+}
+
+func (t *tui) run(refresh time.Duration) {
+	keys := make(chan rune, 16)
+	go readKeys(os.Stdin, keys)
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+
+	t.draw()
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok || !t.handle(key) {
+				return
+			}
+		case <-ticker.C:
+			if err := t.refresh(); err != nil {
+				t.status = err.Error()
+			}
+		case <-resize:
+		}
+		t.draw()
+	}
+}
+
+// readKeys decodes key presses, including the escape sequences for the arrow
+// keys.
+func readKeys(r io.Reader, keys chan<- rune) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		for b := buf[:n]; len(b) > 0; {
+			if len(b) >= 3 && b[0] == 0x1b && (b[1] == '[' || b[1] == 'O') {
+				switch b[2] {
+				case 'A':
+					keys <- keyUp
+				case 'B':
+					keys <- keyDown
+				case 'C':
+					keys <- keyRight
+				case 'D':
+					keys <- keyLeft
+				}
+				b = b[3:]
+				continue
+			}
+			key, size := utf8.DecodeRune(b)
+			b = b[size:]
+			switch key {
+			case '\r', '\n':
+				key = keyEnter
+			case 3, 4:
+				key = keyInterrupt
+			}
+			keys <- key
+		}
+	}
+}
+
+// handle acts on a key, reporting whether to carry on.
+func (t *tui) handle(key rune) bool {
+	t.status = ""
+	switch key {
+	case 'q', 'Q', keyInterrupt:
+		return false
+	case keyUp, 'k':
+		t.move(-1)
+	case keyDown, 'j':
+		t.move(1)
+	case ' ':
+		if light, ok := t.current(); ok {
+			t.selected[light.Id] = !t.selected[light.Id]
+		}
+	case 'a':
+		all := len(t.selectedLights()) == len(t.lights)
+		for _, light := range t.lights {
+			t.selected[light.Id] = !all
+		}
+	case keyLeft, 'h':
+		t.rotateHue(-hueStep)
+	case keyRight, 'l':
+		t.rotateHue(hueStep)
+	case '-', '_':
+		t.adjust("brightness", -brightnessStep)
+	case '+', '=':
+		t.adjust("brightness", brightnessStep)
+	case '[':
+		t.adjust("saturation", -saturationStep)
+	case ']':
+		t.adjust("saturation", saturationStep)
+	case 'p':
+		t.togglePower()
+	case 's':
+		t.scheme = (t.scheme + 1) % len(palette.SchemeNames)
+	case 'S':
+		t.scheme = (t.scheme + len(palette.SchemeNames) - 1) % len(palette.SchemeNames)
+	case keyEnter:
+		t.applyScheme()
+	case 'r':
+		if err := t.refresh(); err != nil {
+			t.status = err.Error()
+		}
+	}
+	return true
+}
+
+func (t *tui) refresh() error {
+	var resp lightsResponse
+	if err := t.client.do("GET", "/lights", nil, &resp); err != nil {
+		return err
+	}
+	t.setLights(resp.Lights)
+	return nil
+}
+
+// setLights replaces the lights, keeping the cursor on the same light.
+func (t *tui) setLights(lights []palette.LightStatus) {
+	current, ok := t.current()
+	t.lights = lights
+	t.refreshed = time.Now()
+	if ok {
+		for i, light := range lights {
+			if light.Id == current.Id {
+				t.cursor = i
+			}
+		}
+	}
+	t.move(0)
+}
+
+func (t *tui) move(by int) {
+	t.cursor += by
+	if t.cursor >= len(t.lights) {
+		t.cursor = len(t.lights) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+}
+
+func (t *tui) current() (palette.LightStatus, bool) {
+	if t.cursor < len(t.lights) {
+		return t.lights[t.cursor], true
+	}
+	return palette.LightStatus{}, false
+}
+
+func (t *tui) selectedLights() []palette.LightStatus {
+	var lights []palette.LightStatus
+	for _, light := range t.lights {
+		if t.selected[light.Id] {
+			lights = append(lights, light)
+		}
+	}
+	return lights
+}
+
+// targets are the lights keys act on: the selected lights, or the one under
+// the cursor.
+func (t *tui) targets() []palette.LightStatus {
+	if lights := t.selectedLights(); len(lights) > 0 {
+		return lights
+	}
+	if light, ok := t.current(); ok {
+		return []palette.LightStatus{light}
+	}
+	return nil
+}
+
+// setEach sends each target the change returned for it, skipping the lights
+// it returns nil for.
+func (t *tui) setEach(change func(light palette.LightStatus) map[string]interface{}) {
+	var skipped []string
+	for _, light := range t.targets() {
+		req := change(light)
+		if req == nil {
+			skipped = append(skipped, light.Name)
+			continue
+		}
+		var resp lightsResponse
+		if err := t.client.do("PUT", "/lights/"+pathEscape(light.Id), req, &resp); err != nil {
+			t.status = err.Error()
+			return
+		}
+		t.setLights(resp.Lights)
+	}
+	if len(skipped) > 0 {
+		t.status = "Can't change " + strings.Join(skipped, ", ")
+	}
+}
+
+func (t *tui) rotateHue(by int) {
+	t.setEach(func(light palette.LightStatus) map[string]interface{} {
+		if light.Hue == nil {
+			return nil
+		}
+		return map[string]interface{}{"hue": (int(*light.Hue) + by + 65536) % 65536}
+	})
+}
+
+// adjust changes the brightness or saturation of the targets by delta.
+func (t *tui) adjust(field string, by int) {
+	t.setEach(func(light palette.LightStatus) map[string]interface{} {
+		value, min := light.Brightness, 1
+		if field == "saturation" {
+			value, min = light.Saturation, 0
+		}
+		if value == nil {
+			return nil
+		}
+		return map[string]interface{}{field: clamp(int(*value)+by, min, 254)}
+	})
+}
+
+// togglePower turns the targets on if any are off, or off otherwise.
+func (t *tui) togglePower() {
+	path := "/off"
+	var ids []string
+	for _, light := range t.targets() {
+		if light.On == nil || !*light.On {
+			path = "/on"
+		}
+		ids = append(ids, light.Id)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	var resp lightsResponse
+	if err := t.client.do("PUT", path, map[string][]string{"lights": ids}, &resp); err != nil {
+		t.status = err.Error()
+		return
+	}
+	t.setLights(resp.Lights)
+}
+
+// applyScheme sets the selected lights, or every light, to the scheme, built
+// on the color of the light under the cursor.
+func (t *tui) applyScheme() {
+	req := map[string]interface{}{"palette": palette.SchemeNames[t.scheme]}
+	if light, ok := t.current(); ok && light.Hue != nil {
+		req["hue"] = *light.Hue
+		if light.Saturation != nil {
+			req["saturation"] = *light.Saturation
+		}
+		if light.Brightness != nil {
+			req["brightness"] = *light.Brightness
+		}
+	}
+	if selected := t.selectedLights(); len(selected) > 0 {
+		ids := make([]string, len(selected))
+		for i, light := range selected {
+			ids[i] = light.Id
+		}
+		req["lights"] = ids
+	}
+	var resp lightsResponse
+	if err := t.client.do("PUT", "/palette", req, &resp); err != nil {
+		t.status = err.Error()
+		return
+	}
+	t.setLights(resp.Lights)
+	t.status = "Applied " + palette.SchemeNames[t.scheme]
+}
+
+func (t *tui) draw() {
+	width, height, err := t.term.size()
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	var b bytes.Buffer
+	b.WriteString(clearScreen)
+
+	title := fmt.Sprintf("palette - %d lights", len(t.lights))
+	updated := "updated " + t.refreshed.Format("15:04:05")
+	gap := width - len(title) - len(updated)
+	if gap < 1 {
+		gap = 1
+	}
+	b.WriteString(bold + truncate(title+strings.Repeat(" ", gap)+updated, width) + reset + "\n")
+	b.WriteString(dim + truncate(fmt.Sprintf("            %s", lightColumns("ID", "NAME", "POWER", "BRI", "SAT", "HUE")), width) + reset + "\n")
+
+	// Keep the cursor on screen, below the two header lines and above the
+	// three footer lines.
+	rows := height - 5
+	if rows < 1 {
+		rows = 1
+	}
+	if t.cursor < t.top {
+		t.top = t.cursor
+	}
+	if t.cursor >= t.top+rows {
+		t.top = t.cursor - rows + 1
+	}
+	for i := t.top; i < len(t.lights) && i < t.top+rows; i++ {
+		b.WriteString(t.lightRow(i, width))
+		b.WriteString("\n")
+	}
+	for i := len(t.lights) - t.top; i < rows; i++ {
+		b.WriteString("\n")
+	}
+
+	b.WriteString(truncate("scheme: "+palette.SchemeNames[t.scheme]+"  (s/S to change, enter to apply)", width) + "\n")
+	b.WriteString(dim + truncate("up/down move  space select  a all  left/right hue  -/+ bri  [/] sat  p power  r refresh  q quit", width) + reset + "\n")
+	if t.status != "" {
+		b.WriteString(bold + truncate(strings.Replace(t.status, "\n", " ", -1), width) + reset)
+	}
+	os.Stdout.Write(b.Bytes())
+}
+
+// lightRow draws a light with a swatch of its current color.
+func (t *tui) lightRow(i, width int) string {
+	light := t.lights[i]
+	cursor, mark := " ", " "
+	if i == t.cursor {
+		cursor = ">"
+	}
+	if t.selected[light.Id] {
+		mark = "*"
+	}
+	power := "off"
+	if light.On != nil && *light.On {
+		power = "on"
+	}
+	if !light.Reachable {
+		power = "unreachable"
+	}
+	text := lightColumns(light.Id, light.Name, power, uint8Field(light.Brightness), uint8Field(light.Saturation), hueField(light.Hue))
+	text = truncate(text, width-12)
+	if i == t.cursor {
+		text = reverse + text + reset
+	}
+	return fmt.Sprintf("%s %s %s  %s", cursor, mark, swatch(light), text)
+}
+
+func lightColumns(id, name, power, bri, sat, hue string) string {
+	return fmt.Sprintf("%-20s %-20s %-11s %4s %4s %5s", truncate(id, 20), truncate(name, 20), power, bri, sat, hue)
+}
+
+// swatch is six cells in the light's color, in truecolor, or dark when the
+// light is off.
+func swatch(light palette.LightStatus) string {
+	r, g, b := 48, 48, 48
+	if light.On != nil && *light.On && light.Reachable {
+		if v, err := strconv.ParseUint(strings.TrimPrefix(light.Color, "#"), 16, 32); err == nil {
+			r, g, b = int(v>>16&0xff), int(v>>8&0xff), int(v&0xff)
+		}
+	}
+	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm      %s", r, g, b, reset)
+}
+
+func uint8Field(v *uint8) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+// hueField shows a hue in degrees.
+func hueField(v *uint16) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.Itoa(int(*v)*360/65536) + "°"
+}
+
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
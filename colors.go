@@ -1,16 +1,21 @@
 package palette
 
 import (
+	"fmt"
+	"image/color"
+	"math"
+
 	"github.com/BrianBland/go-hue"
 )
 
 const hueResolution = 2 << 15
 
 func RotateDegrees(state hue.LightState, degrees float64) hue.LightState {
-	rotatedHue := (uint16)(
-		(uint32)(
-			(float64)(*state.Hue)+hueResolution*(degrees/360.0),
-		) % hueResolution)
+	rotated := math.Mod((float64)(*state.Hue)+hueResolution*(degrees/360.0), hueResolution)
+	if rotated < 0 {
+		rotated += hueResolution
+	}
+	rotatedHue := (uint16)((uint32)(rotated) % hueResolution)
 	return hue.LightState{
 		On:         state.On,
 		Brightness: state.Brightness,
@@ -20,3 +25,303 @@ func RotateDegrees(state hue.LightState, degrees float64) hue.LightState {
 		Effect:     state.Effect,
 	}
 }
+
+const maxLevel = 254
+
+// StateFromColor returns the light state closest to c in the bulb's
+// hue/saturation/brightness space.
+func StateFromColor(c color.Color) hue.LightState {
+	r, g, b := normalizedRGB(c)
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	delta := max - min
+
+	var h float64
+	switch {
+	case delta == 0:
+		h = 0
+	case max == r:
+		h = math.Mod((g-b)/delta, 6)
+	case max == g:
+		h = (b-r)/delta + 2
+	default:
+		h = (r-g)/delta + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	var s float64
+	if max > 0 {
+		s = delta / max
+	}
+
+	hueValue := uint16(uint32(h/360*hueResolution) % hueResolution)
+	sat := uint8(s*maxLevel + 0.5)
+	bri := uint8(max*maxLevel + 0.5)
+	return hue.LightState{
+		Brightness: &bri,
+		Hue:        &hueValue,
+		Saturation: &sat,
+	}
+}
+
+func normalizedRGB(c color.Color) (float64, float64, float64) {
+	r, g, b, _ := c.RGBA()
+	return float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff
+}
+
+// Lab is a color in the CIE L*a*b* space, where euclidean distance roughly
+// matches perceived difference.
+type Lab struct {
+	L, A, B float64
+}
+
+// D65 reference white
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+func LabFromColor(c color.Color) Lab {
+	r, g, b := normalizedRGB(c)
+	r, g, b = linearize(r), linearize(g), linearize(b)
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / whiteX
+	y := (0.2126729*r + 0.7151522*g + 0.0721750*b) / whiteY
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / whiteZ
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return Lab{L: 116*fy - 16, A: 500 * (fx - fy), B: 200 * (fy - fz)}
+}
+
+func (l Lab) Color() color.RGBA {
+	fy := (l.L + 16) / 116
+	fx := fy + l.A/500
+	fz := fy - l.B/200
+	x, y, z := labFInv(fx)*whiteX, labFInv(fy)*whiteY, labFInv(fz)*whiteZ
+	r := 3.2404542*x - 1.5371385*y - 0.4985314*z
+	g := -0.9692660*x + 1.8760108*y + 0.0415560*z
+	b := 0.0556434*x - 0.2040259*y + 1.0572252*z
+	return color.RGBA{R: toByte(delinearize(r)), G: toByte(delinearize(g)), B: toByte(delinearize(b)), A: 0xff}
+}
+
+// Distance is the CIE76 color difference between two colors.
+func (l Lab) Distance(o Lab) float64 {
+	dl, da, db := l.L-o.L, l.A-o.A, l.B-o.B
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
+
+func linearize(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func delinearize(v float64) float64 {
+	if v <= 0.0031308 {
+		return 12.92 * v
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func labF(t float64) float64 {
+	if t > 216.0/24389 {
+		return math.Cbrt(t)
+	}
+	return (24389.0/27*t + 16) / 116
+}
+
+func labFInv(t float64) float64 {
+	if t*t*t > 216.0/24389 {
+		return t * t * t
+	}
+	return (116*t - 16) * 27 / 24389
+}
+
+func toByte(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(1, v))*255 + 0.5)
+}
+
+func HexFromColor(c color.Color) string {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
+
+// Gamut is the triangle of CIE xy colors, red, green then blue, that a bulb
+// can reproduce.
+type Gamut [3][2]float64
+
+var (
+	GamutA = Gamut{{0.704, 0.296}, {0.2151, 0.7106}, {0.138, 0.08}}
+	GamutB = Gamut{{0.675, 0.322}, {0.409, 0.518}, {0.167, 0.04}}
+	GamutC = Gamut{{0.692, 0.308}, {0.17, 0.7}, {0.153, 0.048}}
+)
+
+// GamutForModel returns the gamut of a bulb model, defaulting to that of the
+// original Hue bulbs.
+func GamutForModel(modelId string) Gamut {
+	switch modelId {
+	case "LLC001", "LLC005", "LLC006", "LLC007", "LLC010", "LLC011", "LLC012", "LLC013", "LLC014", "LST001":
+		return GamutA
+	case "LCT010", "LCT011", "LCT012", "LCT014", "LCT015", "LCT016", "LST002", "LLC020":
+		return GamutC
+	}
+	return GamutB
+}
+
+// Clamp returns the closest point to (x, y) within the gamut.
+func (g Gamut) Clamp(x, y float64) (float64, float64) {
+	p := [2]float64{x, y}
+	if g.contains(p) {
+		return x, y
+	}
+	best, bestDistance := p, math.Inf(1)
+	for i := range g {
+		q := closestOnSegment(g[i], g[(i+1)%3], p)
+		if d := math.Hypot(q[0]-p[0], q[1]-p[1]); d < bestDistance {
+			best, bestDistance = q, d
+		}
+	}
+	return best[0], best[1]
+}
+
+func (g Gamut) contains(p [2]float64) bool {
+	cross := func(a, b, c [2]float64) float64 {
+		return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+	}
+	d1, d2, d3 := cross(g[0], g[1], p), cross(g[1], g[2], p), cross(g[2], g[0], p)
+	hasNeg := d1 < 0 || d2 < 0 || d3 < 0
+	hasPos := d1 > 0 || d2 > 0 || d3 > 0
+	return !(hasNeg && hasPos)
+}
+
+func closestOnSegment(a, b, p [2]float64) [2]float64 {
+	ab := [2]float64{b[0] - a[0], b[1] - a[1]}
+	t := ((p[0]-a[0])*ab[0] + (p[1]-a[1])*ab[1]) / (ab[0]*ab[0] + ab[1]*ab[1])
+	t = math.Max(0, math.Min(1, t))
+	return [2]float64{a[0] + t*ab[0], a[1] + t*ab[1]}
+}
+
+// XYFromColor converts an sRGB color to CIE xy chromaticity using the wide
+// gamut conversion the bridge applies.
+func XYFromColor(c color.Color) (float64, float64) {
+	r, g, b := normalizedRGB(c)
+	r, g, b = linearize(r), linearize(g), linearize(b)
+	X := r*0.664511 + g*0.154324 + b*0.162028
+	Y := r*0.283881 + g*0.668433 + b*0.047685
+	Z := r*0.000088 + g*0.072310 + b*0.986039
+	if X+Y+Z == 0 {
+		return 0, 0
+	}
+	return X / (X + Y + Z), Y / (X + Y + Z)
+}
+
+// ColorFromState approximates the sRGB color a light shows in the given state,
+// honoring whichever color mode the bridge reports and clamping to the gamut
+// of the original Hue bulbs.
+func ColorFromState(state hue.LightState) color.RGBA {
+	return ColorFromStateInGamut(state, GamutB)
+}
+
+func ColorFromStateInGamut(state hue.LightState, gamut Gamut) color.RGBA {
+	bri := 1.0
+	if state.Brightness != nil {
+		bri = float64(*state.Brightness) / maxLevel
+	}
+	switch {
+	case state.ColorMode == "ct" && state.ColorTemp != nil,
+		state.ColorMode == "" && state.ColorTemp != nil && state.Hue == nil && len(state.XY) == 0:
+		return colorFromColorTemp(*state.ColorTemp, bri)
+	case state.ColorMode == "xy" && len(state.XY) == 2,
+		state.ColorMode == "" && len(state.XY) == 2 && state.Hue == nil:
+		x, y := gamut.Clamp(state.XY[0], state.XY[1])
+		return colorFromXY(x, y, bri)
+	}
+	var h, s float64
+	if state.Hue != nil {
+		h = float64(*state.Hue) / hueResolution * 360
+	}
+	if state.Saturation != nil {
+		s = float64(*state.Saturation) / maxLevel
+	}
+	if s == 0 {
+		return colorFromHSV(h, s, bri)
+	}
+	x, y := gamut.Clamp(XYFromColor(colorFromHSV(h, s, 1)))
+	return colorFromXY(x, y, bri)
+}
+
+func colorFromHSV(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.RGBA{R: toByte(r + m), G: toByte(g + m), B: toByte(b + m), A: 0xff}
+}
+
+func colorFromXY(x, y, bri float64) color.RGBA {
+	if y == 0 {
+		return color.RGBA{A: 0xff}
+	}
+	Y := 1.0
+	X := Y / y * x
+	Z := Y / y * (1 - x - y)
+	r := 1.656492*X - 0.354851*Y - 0.255038*Z
+	g := -0.707196*X + 1.655397*Y + 0.036152*Z
+	b := 0.051713*X - 0.121364*Y + 1.011530*Z
+	r, g, b = math.Max(r, 0), math.Max(g, 0), math.Max(b, 0)
+	if max := math.Max(r, math.Max(g, b)); max > 0 {
+		r, g, b = r/max, g/max, b/max
+	}
+	return color.RGBA{
+		R: toByte(delinearize(r) * bri),
+		G: toByte(delinearize(g) * bri),
+		B: toByte(delinearize(b) * bri),
+		A: 0xff,
+	}
+}
+
+// colorFromColorTemp uses Tanner Helland's blackbody approximation.
+func colorFromColorTemp(mireds uint16, bri float64) color.RGBA {
+	if mireds == 0 {
+		mireds = MinColorTemp
+	}
+	k := 1e6 / float64(mireds) / 100
+	var r, g, b float64
+	if k <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(k) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(k-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(k-60, -0.0755148492)
+	}
+	switch {
+	case k >= 66:
+		b = 255
+	case k <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(k-10) - 305.0447927307
+	}
+	return color.RGBA{
+		R: toByte(r / 255 * bri),
+		G: toByte(g / 255 * bri),
+		B: toByte(b / 255 * bri),
+		A: 0xff,
+	}
+}
@@ -0,0 +1,138 @@
+package palette
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/BrianBland/go-hue"
+)
+
+const (
+	Protanopia   = "protanopia"
+	Deuteranopia = "deuteranopia"
+	Tritanopia   = "tritanopia"
+
+	// DefaultMinContrast is the CIE76 distance two colors must keep under
+	// simulation to be told apart comfortably.
+	DefaultMinContrast = 20
+)
+
+var Deficiencies = []string{Protanopia, Deuteranopia, Tritanopia}
+
+// Machado, Oliveira & Fernandes (2009) simulation matrices for full severity
+// dichromacy, applied to linear RGB.
+var cvdMatrices = map[string][3][3]float64{
+	Protanopia: {
+		{0.152286, 1.052583, -0.204868},
+		{0.114503, 0.786281, 0.099216},
+		{-0.003882, -0.048116, 1.051998},
+	},
+	Deuteranopia: {
+		{0.367322, 0.860646, -0.227968},
+		{0.280085, 0.672501, 0.047413},
+		{-0.011820, 0.042940, 0.968881},
+	},
+	Tritanopia: {
+		{1.255528, -0.076749, -0.178779},
+		{-0.078411, 0.930809, 0.147602},
+		{0.004733, 0.691367, 0.303900},
+	},
+}
+
+func ValidDeficiency(deficiency string) error {
+	if _, ok := cvdMatrices[deficiency]; !ok {
+		return fmt.Errorf("unknown color vision deficiency %q", deficiency)
+	}
+	return nil
+}
+
+// SimulateCVD returns how c appears to someone with the given deficiency.
+func SimulateCVD(c color.Color, deficiency string) color.RGBA {
+	m, ok := cvdMatrices[deficiency]
+	if !ok {
+		return color.RGBAModel.Convert(c).(color.RGBA)
+	}
+	r, g, b := normalizedRGB(c)
+	in := [3]float64{linearize(r), linearize(g), linearize(b)}
+	var out [3]float64
+	for i := range out {
+		out[i] = m[i][0]*in[0] + m[i][1]*in[1] + m[i][2]*in[2]
+	}
+	return color.RGBA{
+		R: toByte(delinearize(math.Max(0, out[0]))),
+		G: toByte(delinearize(math.Max(0, out[1]))),
+		B: toByte(delinearize(math.Max(0, out[2]))),
+		A: 0xff,
+	}
+}
+
+// MinSimulatedDistance is the smallest distance between any two of the states
+// as seen under every one of the deficiencies, or 0 for fewer than two states.
+func MinSimulatedDistance(states []hue.LightState, deficiencies []string) float64 {
+	if len(states) < 2 || len(deficiencies) == 0 {
+		return 0
+	}
+	min := math.Inf(1)
+	for _, deficiency := range deficiencies {
+		labs := simulatedLabs(states, deficiency)
+		for i := range labs {
+			for j := 0; j < i; j++ {
+				min = math.Min(min, labs[i].Distance(labs[j]))
+			}
+		}
+	}
+	return min
+}
+
+func simulatedLabs(states []hue.LightState, deficiency string) []Lab {
+	labs := make([]Lab, len(states))
+	for i, state := range states {
+		labs[i] = LabFromColor(SimulateCVD(ColorFromState(state), deficiency))
+	}
+	return labs
+}
+
+// cvdAdjustments are tried in order when a color is too close to an earlier
+// one: small hue shifts first, then larger ones, each combined with dimming
+// since lightness survives every kind of dichromacy.
+var (
+	cvdRotations         = []float64{0, 15, -15, 30, -30, 45, -45, 60, -60, 90, -90, 120, -120, 150, -150, 180}
+	cvdBrightnessFactors = []float64{1, 0.7, 0.45, 0.25}
+)
+
+// CVDSafe adjusts states, keeping the first as-is, until every pair is at
+// least minContrast apart under each of the deficiencies. If that can't be
+// met the most distinguishable adjustment found is used. It returns the
+// adjusted states and the distance actually achieved.
+func CVDSafe(states []hue.LightState, deficiencies []string, minContrast float64) ([]hue.LightState, float64) {
+	if minContrast <= 0 {
+		minContrast = DefaultMinContrast
+	}
+	safe := make([]hue.LightState, 0, len(states))
+	for i, state := range states {
+		if i == 0 || state.Hue == nil {
+			safe = append(safe, state)
+			continue
+		}
+		best, bestDistance := state, -1.0
+	search:
+		for _, factor := range cvdBrightnessFactors {
+			for _, degrees := range cvdRotations {
+				candidate := RotateDegrees(state, degrees)
+				if state.Brightness != nil && factor != 1 {
+					candidate.Brightness = uint8Ptr(uint8(math.Max(1, float64(*state.Brightness)*factor)))
+				}
+				d := MinSimulatedDistance(append(safe, candidate), deficiencies)
+				if d > bestDistance {
+					best, bestDistance = candidate, d
+				}
+				if d >= minContrast {
+					break search
+				}
+			}
+		}
+		safe = append(safe, best)
+	}
+	return safe, MinSimulatedDistance(safe, deficiencies)
+}
@@ -0,0 +1,95 @@
+package palette
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/BrianBland/go-hue"
+)
+
+func TestSimulateCVD(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	green := color.RGBA{0, 255, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	black := color.RGBA{0, 0, 0, 255}
+	tests := []struct {
+		deficiency string
+		in, want   color.RGBA
+	}{
+		{Protanopia, red, color.RGBA{109, 95, 0, 255}},
+		{Protanopia, green, color.RGBA{255, 229, 0, 255}},
+		{Protanopia, blue, color.RGBA{0, 89, 255, 255}},
+		{Deuteranopia, red, color.RGBA{163, 144, 0, 255}},
+		{Deuteranopia, green, color.RGBA{239, 214, 58, 255}},
+		{Deuteranopia, blue, color.RGBA{0, 61, 251, 255}},
+		{Tritanopia, red, color.RGBA{255, 0, 15, 255}},
+		{Tritanopia, green, color.RGBA{0, 247, 217, 255}},
+		{Tritanopia, blue, color.RGBA{0, 107, 150, 255}},
+		// Every row of the matrices sums to about 1, so neutrals stay put.
+		{Protanopia, white, white},
+		{Deuteranopia, black, black},
+		{Tritanopia, color.RGBA{128, 128, 128, 255}, color.RGBA{128, 128, 128, 255}},
+		// Unknown deficiencies leave colors alone.
+		{"achromatopsia", red, red},
+	}
+	for _, test := range tests {
+		if got := SimulateCVD(test.in, test.deficiency); !closeRGBA(got, test.want, 1) {
+			t.Errorf("SimulateCVD(%v, %s) = %v, want %v", test.in, test.deficiency, got, test.want)
+		}
+	}
+}
+
+func TestValidDeficiency(t *testing.T) {
+	for _, deficiency := range Deficiencies {
+		if err := ValidDeficiency(deficiency); err != nil {
+			t.Errorf("%s: %v", deficiency, err)
+		}
+	}
+	for _, deficiency := range []string{"", "Protanopia", "achromatopsia"} {
+		if err := ValidDeficiency(deficiency); err == nil {
+			t.Errorf("%q is valid", deficiency)
+		}
+	}
+}
+
+func TestCVDSafe(t *testing.T) {
+	redGreen := []hue.LightState{
+		StateFromColor(color.RGBA{255, 0, 0, 255}),
+		StateFromColor(color.RGBA{0, 255, 0, 255}),
+	}
+	tests := []struct {
+		deficiency  string
+		minContrast float64
+	}{
+		// Already far enough apart.
+		{Protanopia, DefaultMinContrast},
+		{Deuteranopia, DefaultMinContrast},
+		// Too close, so the second color has to move.
+		{Deuteranopia, 50},
+		{Protanopia, 70},
+	}
+	for _, test := range tests {
+		deficiencies := []string{test.deficiency}
+		before := MinSimulatedDistance(redGreen, deficiencies)
+		safe, distance := CVDSafe(redGreen, deficiencies, test.minContrast)
+		if len(safe) != len(redGreen) {
+			t.Fatalf("%s: got %d states, want %d", test.deficiency, len(safe), len(redGreen))
+		}
+		if *safe[0].Hue != *redGreen[0].Hue {
+			t.Errorf("%s: the first state changed", test.deficiency)
+		}
+		if before >= test.minContrast && *safe[1].Hue != *redGreen[1].Hue {
+			t.Errorf("%s %v: the second state changed though it was %v away", test.deficiency, test.minContrast, before)
+		}
+		if distance < test.minContrast {
+			t.Errorf("%s: distance went from %v to %v, want at least %v", test.deficiency, before, distance, test.minContrast)
+		}
+		if d := MinSimulatedDistance(safe, deficiencies); d != distance {
+			t.Errorf("%s: reported distance %v, measured %v", test.deficiency, distance, d)
+		}
+	}
+	if d := MinSimulatedDistance(redGreen[:1], Deficiencies); d != 0 {
+		t.Errorf("distance of one state is %v, want 0", d)
+	}
+}
@@ -0,0 +1,240 @@
+package palette
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+	"github.com/bcurren/go-ssdp"
+)
+
+// Discovery methods, in the order they're tried.
+const (
+	DiscoverManual = "manual"
+	DiscoverCached = "cached"
+	DiscoverSSDP   = "ssdp"
+	DiscoverCloud  = "cloud"
+)
+
+const DefaultDiscoveryTimeout = 5 * time.Second
+
+// CloudDiscoveryURL lists the bridges on the caller's network, as seen by the
+// Hue cloud.
+var CloudDiscoveryURL = "https://discovery.meethue.com/"
+
+var errDiscoveryTimeout = errors.New("timed out")
+
+// DiscoveredBridge is a bridge found on the network, and how it was found.
+type DiscoveredBridge struct {
+	Id      string `json:"id"`
+	Address string `json:"address"`
+	Method  string `json:"method"`
+}
+
+func (d DiscoveredBridge) Bridge() *hue.Bridge {
+	return hue.NewBridge(d.Id, d.Address)
+}
+
+// Discovery finds bridges, trying each method in turn until one finds them:
+// the configured addresses, then the addresses bridges had when last
+// connected, then SSDP on the local network, then the Hue cloud. Configured
+// addresses, when given, are the only ones tried.
+type Discovery struct {
+	// Addresses are bridge addresses given by the user.
+	Addresses []string
+	// Cached are the bridges palette last connected to.
+	Cached []DiscoveredBridge
+	// Timeout limits each method.
+	Timeout time.Duration
+	// All tries every method and returns everything found, rather than
+	// stopping at the first method that finds the bridges.
+	All bool
+}
+
+func NewDiscovery(addresses []string, cached []DiscoveredBridge) *Discovery {
+	return &Discovery{Addresses: addresses, Cached: cached, Timeout: DefaultDiscoveryTimeout}
+}
+
+// Run discovers the bridges. Errors are only returned when no method finds any.
+func (d *Discovery) Run() ([]DiscoveredBridge, error) {
+	if len(d.Addresses) > 0 && !d.All {
+		bridges, err := d.byMethod(DiscoverManual)
+		if len(bridges) == 0 {
+			return nil, err
+		}
+		if err != nil {
+			log.WithField("error", err).Warn("Some bridges could not be reached")
+		}
+		return bridges, nil
+	}
+	var found []DiscoveredBridge
+	var errs []string
+	seen := make(map[string]bool)
+	for _, method := range []string{DiscoverManual, DiscoverCached, DiscoverSSDP, DiscoverCloud} {
+		bridges, err := d.byMethod(method)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"method": method,
+				"error":  err,
+			}).Warn("Bridge discovery failed")
+			errs = append(errs, method+": "+err.Error())
+		}
+		for _, bridge := range bridges {
+			if !seen[bridge.Id] || d.All {
+				seen[bridge.Id] = true
+				found = append(found, bridge)
+			}
+		}
+		// Cached addresses are good enough while every bridge still answers
+		// at its old address; otherwise look for the ones that moved.
+		if method == DiscoverCached && len(bridges) < len(d.Cached) {
+			continue
+		}
+		if len(found) > 0 && !d.All {
+			break
+		}
+	}
+	if len(found) == 0 {
+		if len(errs) == 0 {
+			return nil, errors.New("No bridges found")
+		}
+		return nil, fmt.Errorf("No bridges found (%s)", strings.Join(errs, "; "))
+	}
+	return found, nil
+}
+
+// byMethod runs one discovery method, giving up on it after the timeout. The
+// bridge client has no timeouts of its own, so a method that times out is left
+// to finish in the background.
+func (d *Discovery) byMethod(method string) ([]DiscoveredBridge, error) {
+	type result struct {
+		bridges []DiscoveredBridge
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var r result
+		switch method {
+		case DiscoverManual:
+			r.bridges, r.err = d.manual()
+		case DiscoverCached:
+			r.bridges = d.cached()
+		case DiscoverSSDP:
+			r.bridges, r.err = d.ssdp()
+		case DiscoverCloud:
+			r.bridges, r.err = d.cloud()
+		}
+		done <- r
+	}()
+	select {
+	case r := <-done:
+		return r.bridges, r.err
+	case <-time.After(d.Timeout):
+		return nil, errDiscoveryTimeout
+	}
+}
+
+func (d *Discovery) manual() ([]DiscoveredBridge, error) {
+	var bridges []DiscoveredBridge
+	var errs []string
+	for _, addr := range d.Addresses {
+		bridge := hue.NewBridge("", addr)
+		if err := IdentifyBridge(bridge); err != nil {
+			errs = append(errs, addr+": "+err.Error())
+			continue
+		}
+		bridges = append(bridges, DiscoveredBridge{Id: bridge.UniqueId, Address: addr, Method: DiscoverManual})
+	}
+	if len(errs) > 0 {
+		return bridges, errors.New(strings.Join(errs, "; "))
+	}
+	return bridges, nil
+}
+
+// cached returns the cached bridges still answering at their old address.
+func (d *Discovery) cached() []DiscoveredBridge {
+	var bridges []DiscoveredBridge
+	for _, cached := range d.Cached {
+		bridge := hue.NewBridge("", cached.Address)
+		if err := IdentifyBridge(bridge); err != nil || bridge.UniqueId != cached.Id {
+			log.WithFields(log.Fields{
+				"bridge":  cached.Id,
+				"address": cached.Address,
+			}).Info("Bridge is no longer at its cached address")
+			continue
+		}
+		cached.Method = DiscoverCached
+		bridges = append(bridges, cached)
+	}
+	return bridges
+}
+
+// ssdp searches the local network like hue.FindBridges, keeping each bridge's
+// address.
+func (d *Discovery) ssdp() ([]DiscoveredBridge, error) {
+	// Leave time to fetch the device descriptions after the search.
+	devices, err := ssdp.SearchForDevices("upnp:rootdevice", d.Timeout/2)
+	if err != nil {
+		return nil, err
+	}
+	var bridges []DiscoveredBridge
+	for _, device := range devices {
+		if device.ModelURL != hue.HueModelURL {
+			continue
+		}
+		addr := strings.TrimSuffix(device.URLBase, "/")
+		id := bridgeIdFromMAC(device.SerialNumber)
+		if id == "" {
+			bridge := hue.NewBridge("", addr)
+			if err := IdentifyBridge(bridge); err != nil {
+				continue
+			}
+			id = bridge.UniqueId
+		}
+		bridges = append(bridges, DiscoveredBridge{Id: id, Address: addr, Method: DiscoverSSDP})
+	}
+	return bridges, nil
+}
+
+func (d *Discovery) cloud() ([]DiscoveredBridge, error) {
+	client := http.Client{Timeout: d.Timeout}
+	resp, err := client.Get(CloudDiscoveryURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cloud discovery responded %s", resp.Status)
+	}
+	var response []struct {
+		Id                string `json:"id"`
+		InternalIPAddress string `json:"internalipaddress"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	bridges := make([]DiscoveredBridge, len(response))
+	for i, info := range response {
+		bridges[i] = DiscoveredBridge{
+			Id:      strings.ToLower(info.Id),
+			Address: "http://" + info.InternalIPAddress,
+			Method:  DiscoverCloud,
+		}
+	}
+	return bridges, nil
+}
+
+// bridgeIdFromMAC derives a bridge's unique ID from its MAC address, as the
+// bridge does.
+func bridgeIdFromMAC(mac string) string {
+	mac = strings.ToLower(strings.Replace(mac, ":", "", -1))
+	if len(mac) != 12 {
+		return ""
+	}
+	return mac[:6] + "fffe" + mac[6:]
+}
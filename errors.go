@@ -0,0 +1,39 @@
+package palette
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BrianBland/go-hue"
+)
+
+// LightError is the failure of an operation on a single light.
+type LightError struct {
+	Light hue.Light
+	Err   error
+}
+
+func (e *LightError) Error() string {
+	return fmt.Sprintf("light %s: %s", e.Light.Id, e.Err)
+}
+
+// LightErrors collects the failures of an operation on a group of lights.
+type LightErrors []*LightError
+
+func (e LightErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// BridgeError is the failure of an operation on one of several bridges.
+type BridgeError struct {
+	Bridge string
+	Err    error
+}
+
+func (e *BridgeError) Error() string {
+	return fmt.Sprintf("bridge %s: %s", e.Bridge, e.Err)
+}
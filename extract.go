@@ -0,0 +1,177 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/BrianBland/go-hue"
+)
+
+const (
+	maxSamples        = 20000
+	kMeansIterations  = 20
+	kMeansConvergence = 0.5
+)
+
+// Swatch is one color of a palette extracted from an image, along with the
+// fraction of the image's pixels it represents.
+type Swatch struct {
+	Color     color.RGBA `json:"-"`
+	Hex       string     `json:"color"`
+	Dominance float64    `json:"dominance"`
+}
+
+// ExtractSwatches clusters the pixels of img into k colors in L*a*b* space
+// using k-means, ordered from most to least dominant. The result is
+// deterministic for a given image.
+func ExtractSwatches(img image.Image, k int) []Swatch {
+	samples := samplePixels(img)
+	if len(samples) == 0 || k <= 0 {
+		return nil
+	}
+	if k > len(samples) {
+		k = len(samples)
+	}
+
+	centroids := initCentroids(samples, k, rand.New(rand.NewSource(1)))
+	assignments := make([]int, len(samples))
+	for iter := 0; iter < kMeansIterations; iter++ {
+		for i, sample := range samples {
+			assignments[i] = nearest(centroids, sample)
+		}
+		sums := make([]Lab, k)
+		counts := make([]int, k)
+		for i, sample := range samples {
+			c := assignments[i]
+			sums[c].L += sample.L
+			sums[c].A += sample.A
+			sums[c].B += sample.B
+			counts[c]++
+		}
+		var moved float64
+		for c := range centroids {
+			if counts[c] == 0 {
+				continue
+			}
+			n := float64(counts[c])
+			next := Lab{L: sums[c].L / n, A: sums[c].A / n, B: sums[c].B / n}
+			moved = math.Max(moved, next.Distance(centroids[c]))
+			centroids[c] = next
+		}
+		if moved < kMeansConvergence {
+			break
+		}
+	}
+
+	counts := make([]int, k)
+	for _, sample := range samples {
+		counts[nearest(centroids, sample)]++
+	}
+	swatches := make([]Swatch, 0, k)
+	for c, centroid := range centroids {
+		if counts[c] == 0 {
+			continue
+		}
+		rgb := centroid.Color()
+		swatches = append(swatches, Swatch{
+			Color:     rgb,
+			Hex:       HexFromColor(rgb),
+			Dominance: float64(counts[c]) / float64(len(samples)),
+		})
+	}
+	sort.Stable(byDominance(swatches))
+	return swatches
+}
+
+// StatesFromImage returns one light state per extracted swatch, most dominant
+// first.
+func StatesFromImage(img image.Image, k int) []hue.LightState {
+	return StatesFromSwatches(ExtractSwatches(img, k))
+}
+
+func StatesFromSwatches(swatches []Swatch) []hue.LightState {
+	states := make([]hue.LightState, len(swatches))
+	for i, swatch := range swatches {
+		states[i] = StateFromColor(swatch.Color)
+		states[i].On = boolPtr(true)
+	}
+	return states
+}
+
+func samplePixels(img image.Image) []Lab {
+	bounds := img.Bounds()
+	pixels := bounds.Dx() * bounds.Dy()
+	stride := 1
+	for pixels/(stride*stride) > maxSamples {
+		stride++
+	}
+	samples := make([]Lab, 0, pixels/(stride*stride)+1)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stride {
+		for x := bounds.Min.X; x < bounds.Max.X; x += stride {
+			c := img.At(x, y)
+			if _, _, _, a := c.RGBA(); a < 0x8000 {
+				continue
+			}
+			samples = append(samples, LabFromColor(c))
+		}
+	}
+	return samples
+}
+
+// initCentroids picks starting centroids with k-means++, favoring samples far
+// from the centroids chosen so far.
+func initCentroids(samples []Lab, k int, r *rand.Rand) []Lab {
+	centroids := make([]Lab, 0, k)
+	centroids = append(centroids, samples[r.Intn(len(samples))])
+	distances := make([]float64, len(samples))
+	for len(centroids) < k {
+		var total float64
+		for i, sample := range samples {
+			d := sample.Distance(centroids[nearest(centroids, sample)])
+			distances[i] = d * d
+			total += distances[i]
+		}
+		if total == 0 {
+			centroids = append(centroids, samples[r.Intn(len(samples))])
+			continue
+		}
+		target := r.Float64() * total
+		chosen := len(samples) - 1
+		for i, d := range distances {
+			target -= d
+			if target <= 0 {
+				chosen = i
+				break
+			}
+		}
+		centroids = append(centroids, samples[chosen])
+	}
+	return centroids
+}
+
+func nearest(centroids []Lab, sample Lab) int {
+	best, bestDistance := 0, math.Inf(1)
+	for i, centroid := range centroids {
+		if d := sample.Distance(centroid); d < bestDistance {
+			best, bestDistance = i, d
+		}
+	}
+	return best
+}
+
+type byDominance []Swatch
+
+func (s byDominance) Len() int {
+	return len(s)
+}
+
+func (s byDominance) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s byDominance) Less(i, j int) bool {
+	return s[i].Dominance > s[j].Dominance
+}
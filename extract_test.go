@@ -0,0 +1,91 @@
+package palette
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// bands returns a 100 pixel wide image with a horizontal band of each color,
+// as many rows tall as its height.
+func bands(colors []color.RGBA, heights []int) *image.RGBA {
+	total := 0
+	for _, h := range heights {
+		total += h
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 100, total))
+	y := 0
+	for i, c := range colors {
+		for end := y + heights[i]; y < end; y++ {
+			for x := 0; x < 100; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	return img
+}
+
+func TestExtractSwatches(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	green := color.RGBA{0, 255, 0, 255}
+	tests := []struct {
+		img  image.Image
+		k    int
+		want []color.RGBA
+		dom  []float64
+	}{
+		{bands([]color.RGBA{blue, red, green}, []int{3, 6, 1}), 3, []color.RGBA{red, blue, green}, []float64{.6, .3, .1}},
+		{bands([]color.RGBA{green, red}, []int{1, 3}), 2, []color.RGBA{red, green}, []float64{.75, .25}},
+		// Asking for more colors than the image has leaves the rest empty.
+		{bands([]color.RGBA{blue}, []int{4}), 3, []color.RGBA{blue}, []float64{1}},
+		{image.NewRGBA(image.Rect(0, 0, 0, 0)), 3, nil, nil},
+	}
+	for i, test := range tests {
+		swatches := ExtractSwatches(test.img, test.k)
+		if len(swatches) != len(test.want) {
+			t.Errorf("%d: got %d swatches, want %d", i, len(swatches), len(test.want))
+			continue
+		}
+		for j, swatch := range swatches {
+			if !closeRGBA(swatch.Color, test.want[j], 2) || swatch.Hex != HexFromColor(swatch.Color) {
+				t.Errorf("%d: swatch %d is %v (%s), want %v", i, j, swatch.Color, swatch.Hex, test.want[j])
+			}
+			if d := swatch.Dominance - test.dom[j]; d < -1e-9 || d > 1e-9 {
+				t.Errorf("%d: swatch %d dominance is %v, want %v", i, j, swatch.Dominance, test.dom[j])
+			}
+		}
+	}
+}
+
+func TestExtractSwatchesDeterministic(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256)), 255
+	}
+	for _, k := range []int{1, 3, 5, 8} {
+		first := ExtractSwatches(img, k)
+		if len(first) != k {
+			t.Errorf("k=%d: got %d swatches", k, len(first))
+		}
+		for j := 1; j < len(first); j++ {
+			if first[j].Dominance > first[j-1].Dominance {
+				t.Errorf("k=%d: swatch %d is more dominant than swatch %d", k, j, j-1)
+			}
+		}
+		if again := ExtractSwatches(img, k); !reflect.DeepEqual(first, again) {
+			t.Errorf("k=%d: got %v, then %v", k, first, again)
+		}
+	}
+}
+
+func closeRGBA(a, b color.RGBA, tolerance int) bool {
+	near := func(x, y uint8) bool {
+		d := int(x) - int(y)
+		return d >= -tolerance && d <= tolerance
+	}
+	return near(a.R, b.R) && near(a.G, b.G) && near(a.B, b.B) && a.A == b.A
+}
@@ -0,0 +1,183 @@
+package palette
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/BrianBland/go-hue"
+)
+
+const generateAttempts = 500
+
+var ErrUnsatisfiable = errors.New("no palette satisfies the constraints")
+
+// HueRange is an arc of the color wheel in degrees, running clockwise from
+// From to To. Ranges may wrap past 360.
+type HueRange struct {
+	From float64 `json:"from"`
+	To   float64 `json:"to"`
+}
+
+func (r HueRange) Contains(degrees float64) bool {
+	from, to, d := normalizeDegrees(r.From), normalizeDegrees(r.To), normalizeDegrees(degrees)
+	if from <= to {
+		return d >= from && d <= to
+	}
+	return d >= from || d <= to
+}
+
+func normalizeDegrees(d float64) float64 {
+	d = math.Mod(d, 360)
+	if d < 0 {
+		d += 360
+	}
+	return d
+}
+
+// Constraints bound the palettes Generate may produce. Zero values are
+// replaced by DefaultConstraints.
+type Constraints struct {
+	MinSaturation uint8      `json:"minSaturation"`
+	MaxSaturation uint8      `json:"maxSaturation"`
+	MinBrightness uint8      `json:"minBrightness"`
+	MaxBrightness uint8      `json:"maxBrightness"`
+	AvoidHues     []HueRange `json:"avoidHues"`
+	MinDistance   float64    `json:"minDistance"`
+	Schemes       []string   `json:"schemes"`
+}
+
+// DefaultConstraints avoid the fully saturated, full brightness colors that
+// tend to look garish, while keeping lights clearly distinguishable.
+var DefaultConstraints = Constraints{
+	MinSaturation: 140,
+	MaxSaturation: 230,
+	MinBrightness: 150,
+	MaxBrightness: maxLevel,
+	MinDistance:   20,
+	Schemes:       SchemeNames,
+}
+
+func (c Constraints) withDefaults() Constraints {
+	if c.MinSaturation == 0 && c.MaxSaturation == 0 {
+		c.MinSaturation, c.MaxSaturation = DefaultConstraints.MinSaturation, DefaultConstraints.MaxSaturation
+	}
+	if c.MinBrightness == 0 && c.MaxBrightness == 0 {
+		c.MinBrightness, c.MaxBrightness = DefaultConstraints.MinBrightness, DefaultConstraints.MaxBrightness
+	}
+	if c.MaxSaturation == 0 {
+		c.MaxSaturation = maxLevel
+	}
+	if c.MaxBrightness == 0 {
+		c.MaxBrightness = maxLevel
+	}
+	if c.MinDistance == 0 {
+		c.MinDistance = DefaultConstraints.MinDistance
+	}
+	if len(c.Schemes) == 0 {
+		c.Schemes = DefaultConstraints.Schemes
+	}
+	return c
+}
+
+func (c Constraints) Validate() error {
+	c = c.withDefaults()
+	if c.MinSaturation > c.MaxSaturation || c.MaxSaturation > maxLevel {
+		return fmt.Errorf("invalid saturation range %d-%d", c.MinSaturation, c.MaxSaturation)
+	}
+	if c.MinBrightness > c.MaxBrightness || c.MaxBrightness > maxLevel {
+		return fmt.Errorf("invalid brightness range %d-%d", c.MinBrightness, c.MaxBrightness)
+	}
+	if c.MinDistance < 0 {
+		return fmt.Errorf("invalid minimum distance %g", c.MinDistance)
+	}
+	for _, scheme := range c.Schemes {
+		if _, err := SchemeStates(scheme, hue.LightState{Hue: uint16Ptr(0)}); err != nil {
+			return fmt.Errorf("unknown scheme %q", scheme)
+		}
+	}
+	return nil
+}
+
+type GeneratedPalette struct {
+	Scheme string           `json:"scheme"`
+	States []hue.LightState `json:"states"`
+	Colors []string         `json:"colors"`
+}
+
+// Generate produces a random palette of up to n colors following one of the
+// allowed schemes. Saturation and brightness vary a little around a shared
+// base so the colors stay harmonious.
+func Generate(r *rand.Rand, n int, c Constraints) (GeneratedPalette, error) {
+	if err := c.Validate(); err != nil {
+		return GeneratedPalette{}, err
+	}
+	c = c.withDefaults()
+
+	for attempt := 0; attempt < generateAttempts; attempt++ {
+		scheme := strings.ToLower(c.Schemes[r.Intn(len(c.Schemes))])
+		primary := hue.LightState{
+			On:         boolPtr(true),
+			Hue:        uint16Ptr(uint16(r.Intn(hueResolution))),
+			Saturation: uint8Ptr(randomLevel(r, c.MinSaturation, c.MaxSaturation)),
+			Brightness: uint8Ptr(randomLevel(r, c.MinBrightness, c.MaxBrightness)),
+		}
+		states, _ := SchemeStates(scheme, primary)
+		if n > 0 && n < len(states) {
+			states = states[:n]
+		}
+		for i := 1; i < len(states); i++ {
+			states[i].Saturation = uint8Ptr(jitterLevel(r, *primary.Saturation, c.MinSaturation, c.MaxSaturation))
+			states[i].Brightness = uint8Ptr(jitterLevel(r, *primary.Brightness, c.MinBrightness, c.MaxBrightness))
+		}
+		if c.satisfiedBy(states) {
+			colors := make([]string, len(states))
+			for i, state := range states {
+				colors[i] = HexFromColor(ColorFromState(state))
+			}
+			return GeneratedPalette{Scheme: scheme, States: states, Colors: colors}, nil
+		}
+	}
+	return GeneratedPalette{}, ErrUnsatisfiable
+}
+
+func (c Constraints) satisfiedBy(states []hue.LightState) bool {
+	labs := make([]Lab, len(states))
+	for i, state := range states {
+		degrees := float64(*state.Hue) / hueResolution * 360
+		for _, avoid := range c.AvoidHues {
+			if avoid.Contains(degrees) {
+				return false
+			}
+		}
+		labs[i] = LabFromColor(ColorFromState(state))
+		for j := 0; j < i; j++ {
+			if labs[i].Distance(labs[j]) < c.MinDistance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func randomLevel(r *rand.Rand, min, max uint8) uint8 {
+	return min + uint8(r.Intn(int(max)-int(min)+1))
+}
+
+func jitterLevel(r *rand.Rand, base, min, max uint8) uint8 {
+	const jitter = 20
+	v := int(base) + r.Intn(2*jitter+1) - jitter
+	if v < int(min) {
+		v = int(min)
+	}
+	if v > int(max) {
+		v = int(max)
+	}
+	return uint8(v)
+}
+
+func uint8Ptr(u uint8) *uint8 {
+	return &u
+}
@@ -1,12 +1,25 @@
 package palette
 
 import (
+	"errors"
+	"strings"
 	"sync"
 
+	"github.com/BrianBland/palette/metrics"
+
 	"github.com/BrianBland/go-hue"
 )
 
+var fanoutInFlight = metrics.Default.NewGauge("palette_fanout_goroutines_in_flight",
+	"Goroutines getting or setting a single light for GetGroup or SetGroup.", "operation")
+
+func init() {
+	fanoutInFlight.Set(0, "GetGroup")
+	fanoutInFlight.Set(0, "SetGroup")
+}
+
 type LightAttributesOrError struct {
+	Light hue.Light
 	*hue.LightAttributes
 	Error error
 }
@@ -17,8 +30,13 @@ func (p *Palette) GetGroup(lights []hue.Light) <-chan LightAttributesOrError {
 	wg.Add(len(lights))
 
 	getLight := func(i int, res chan<- LightAttributesOrError) {
+		fanoutInFlight.Add(1, "GetGroup")
+		defer fanoutInFlight.Add(-1, "GetGroup")
 		attrs, err := p.GetLightAttributes(lights[i].Id)
-		res <- LightAttributesOrError{LightAttributes: attrs, Error: err}
+		if err != nil {
+			err = &LightError{Light: lights[i], Err: err}
+		}
+		res <- LightAttributesOrError{Light: lights[i], LightAttributes: attrs, Error: err}
 		wg.Done()
 	}
 	for i := range lights {
@@ -39,7 +57,14 @@ func (p *Palette) SetGroup(lights []hue.Light, states []hue.LightState) <-chan e
 	wg.Add(len(lights))
 
 	setLight := func(i int, res chan<- error) {
-		res <- p.SetLightState(lights[i].Id, &states[i%len(states)])
+		fanoutInFlight.Add(1, "SetGroup")
+		defer fanoutInFlight.Add(-1, "SetGroup")
+		err := p.SetLightState(lights[i].Id, &states[i%len(states)])
+		if err != nil {
+			res <- &LightError{Light: lights[i], Err: err}
+		} else {
+			res <- nil
+		}
 		wg.Done()
 	}
 	for i := range lights {
@@ -54,45 +79,88 @@ func (p *Palette) SetGroup(lights []hue.Light, states []hue.LightState) <-chan e
 	return res
 }
 
+// SchemeNames lists the supported color schemes. Seeded palettes choose a
+// scheme by index, so new schemes must be appended to keep them stable.
+var SchemeNames = []string{
+	"complementary",
+	"triad",
+	"analogous",
+	"split",
+	"rectangle",
+	"square",
+}
+
+// schemeRotations gives the hue rotation, in degrees, of each color in a scheme
+// relative to the primary.
+var schemeRotations = map[string][]float64{
+	"complementary": {0, 180},
+	"triad":         {0, 120, 240},
+	"analogous":     {0, 30, -30},
+	"split":         {0, 150, 210},
+	"rectangle":     {0, 60, 180, 240},
+	"square":        {0, 90, 180, 270},
+}
+
+// SchemeAliases maps alternative names to schemes in SchemeNames.
+var SchemeAliases = map[string]string{
+	"adjacent":           "analogous",
+	"splitcomplementary": "split",
+}
+
+var ErrUnknownScheme = errors.New("Invalid palette")
+
+// SchemeStates returns the light states making up the named scheme around
+// primary.
+func SchemeStates(name string, primary hue.LightState) ([]hue.LightState, error) {
+	name = strings.ToLower(name)
+	if alias, ok := SchemeAliases[name]; ok {
+		name = alias
+	}
+	rotations, ok := schemeRotations[name]
+	if !ok {
+		return nil, ErrUnknownScheme
+	}
+	states := make([]hue.LightState, len(rotations))
+	for i, degrees := range rotations {
+		states[i] = RotateDegrees(primary, degrees)
+	}
+	return states, nil
+}
+
+func (p *Palette) SetScheme(lights []hue.Light, name string, primary hue.LightState) (<-chan error, error) {
+	states, err := SchemeStates(name, primary)
+	if err != nil {
+		return nil, err
+	}
+	return p.SetGroup(lights, states), nil
+}
+
 func (p *Palette) SetComplementary(lights []hue.Light, primary hue.LightState) <-chan error {
-	secondary := RotateDegrees(primary, 180)
-	states := []hue.LightState{primary, secondary}
+	states, _ := SchemeStates("complementary", primary)
 	return p.SetGroup(lights, states)
 }
 
 func (p *Palette) SetTriad(lights []hue.Light, primary hue.LightState) <-chan error {
-	secondary := RotateDegrees(primary, 120)
-	tertiary := RotateDegrees(primary, 240)
-	states := []hue.LightState{primary, secondary, tertiary}
+	states, _ := SchemeStates("triad", primary)
 	return p.SetGroup(lights, states)
 }
 
 func (p *Palette) SetAnalogous(lights []hue.Light, primary hue.LightState) <-chan error {
-	secondary := RotateDegrees(primary, 30)
-	tertiary := RotateDegrees(primary, -30)
-	states := []hue.LightState{primary, secondary, tertiary}
+	states, _ := SchemeStates("analogous", primary)
 	return p.SetGroup(lights, states)
 }
 
 func (p *Palette) SetSplitComplementary(lights []hue.Light, primary hue.LightState) <-chan error {
-	secondary := RotateDegrees(primary, 150)
-	tertiary := RotateDegrees(primary, 210)
-	states := []hue.LightState{primary, secondary, tertiary}
+	states, _ := SchemeStates("split", primary)
 	return p.SetGroup(lights, states)
 }
 
 func (p *Palette) SetRectangle(lights []hue.Light, primary hue.LightState) <-chan error {
-	accent := RotateDegrees(primary, 60)
-	complementary := RotateDegrees(primary, 180)
-	complementaryAccent := RotateDegrees(primary, 240)
-	states := []hue.LightState{primary, accent, complementary, complementaryAccent}
+	states, _ := SchemeStates("rectangle", primary)
 	return p.SetGroup(lights, states)
 }
 
 func (p *Palette) SetSquare(lights []hue.Light, primary hue.LightState) <-chan error {
-	accent := RotateDegrees(primary, 90)
-	complementary := RotateDegrees(primary, 180)
-	complementaryAccent := RotateDegrees(primary, 270)
-	states := []hue.LightState{primary, accent, complementary, complementaryAccent}
+	states, _ := SchemeStates("square", primary)
 	return p.SetGroup(lights, states)
 }
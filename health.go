@@ -0,0 +1,113 @@
+package palette
+
+import (
+	"time"
+
+	"github.com/BrianBland/go-hue"
+)
+
+const (
+	HealthReady       = "ready"
+	HealthDegraded    = "degraded"
+	HealthUnavailable = "unavailable"
+)
+
+type HealthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Health is the result of checking the bridges. Palette is degraded, but
+// still usable, while some bridges or lights can't be reached.
+type Health struct {
+	Status            string         `json:"status"`
+	CheckedAt         time.Time      `json:"checkedAt"`
+	Bridges           []BridgeHealth `json:"bridges"`
+	Lights            int            `json:"lights"`
+	UnreachableLights []string       `json:"unreachableLights,omitempty"`
+}
+
+type BridgeHealth struct {
+	Id              string        `json:"id"`
+	Status          string        `json:"status"`
+	SoftwareVersion string        `json:"softwareVersion,omitempty"`
+	Checks          []HealthCheck `json:"checks"`
+}
+
+func (h *BridgeHealth) check(name string, err error) bool {
+	check := HealthCheck{Name: name, OK: err == nil}
+	if err != nil {
+		check.Error = err.Error()
+		h.Status = HealthUnavailable
+	}
+	h.Checks = append(h.Checks, check)
+	return err == nil
+}
+
+// CheckHealth checks that each bridge is reachable and still accepts
+// palette's username, and how many lights they can reach.
+func (p *Palette) CheckHealth() Health {
+	h := Health{Status: HealthUnavailable, CheckedAt: time.Now().UTC()}
+	for _, user := range p.connected() {
+		bridge := checkBridge(user)
+		if bridge.Status == HealthReady {
+			h.Status = HealthReady
+		}
+		h.Bridges = append(h.Bridges, bridge)
+	}
+	if h.Status == HealthUnavailable {
+		return h
+	}
+
+	lights, err := p.GetLights()
+	if err != nil {
+		h.Status = HealthUnavailable
+		return h
+	}
+	h.Lights = len(lights)
+	statuses, _ := p.GetStatus(lights)
+	reachable := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		reachable[status.Id] = status.Reachable
+	}
+	for _, light := range lights {
+		if !reachable[light.Id] {
+			h.UnreachableLights = append(h.UnreachableLights, light.Id)
+		}
+	}
+	for _, bridge := range h.Bridges {
+		if bridge.Status != HealthReady {
+			h.Status = HealthDegraded
+		}
+	}
+	if len(h.UnreachableLights) > 0 {
+		h.Status = HealthDegraded
+	}
+	return h
+}
+
+func checkBridge(user *hue.User) BridgeHealth {
+	h := BridgeHealth{Id: user.Bridge.UniqueId, Status: HealthReady}
+
+	start := time.Now()
+	valid, err := user.Bridge.IsValidUser(user.Username)
+	observeBridgeCall("IsValidUser", start, err)
+	if !h.check("bridge", err) {
+		return h
+	}
+	if !valid {
+		h.check("username", ErrInvalidUser)
+		return h
+	}
+	h.check("username", nil)
+
+	start = time.Now()
+	config, err := user.GetConfiguration()
+	observeBridgeCall("GetConfiguration", start, err)
+	if !h.check("configuration", err) {
+		return h
+	}
+	h.SoftwareVersion = config.SoftwareVersion
+	return h
+}
@@ -0,0 +1,82 @@
+package palette
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// LightSetPrefix marks a light pattern as the name of a light set.
+const LightSetPrefix = "@"
+
+var ErrUnknownLightSet = errors.New("Unknown light set")
+
+// LightSet is a named list of light patterns, which requests can use as
+// "@name" wherever they take light patterns.
+type LightSet struct {
+	Name   string   `json:"name"`
+	Lights []string `json:"lights"`
+}
+
+func (p *Palette) LightSets() []LightSet {
+	p.refresh()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sets := make([]LightSet, 0, len(p.state.LightSets))
+	for name, lights := range p.state.LightSets {
+		sets = append(sets, LightSet{Name: name, Lights: lights})
+	}
+	sort.Sort(byLightSetName(sets))
+	return sets
+}
+
+// SaveLightSet saves the named light set, replacing any of the same name.
+func (p *Palette) SaveLightSet(set LightSet) error {
+	return p.update(func(st *state) error {
+		st.LightSets[set.Name] = set.Lights
+		return nil
+	})
+}
+
+func (p *Palette) DeleteLightSet(name string) error {
+	return p.update(func(st *state) error {
+		if _, ok := st.LightSets[name]; !ok {
+			return ErrUnknownLightSet
+		}
+		delete(st.LightSets, name)
+		return nil
+	})
+}
+
+// ExpandLightSets replaces the light sets among patterns with their
+// patterns. An unknown or empty light set is left as it is, so it matches
+// nothing rather than every light.
+func (p *Palette) ExpandLightSets(patterns []string) []string {
+	p.refresh()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	expanded := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		lights := p.state.LightSets[strings.TrimPrefix(pattern, LightSetPrefix)]
+		if !strings.HasPrefix(pattern, LightSetPrefix) || len(lights) == 0 {
+			expanded = append(expanded, pattern)
+			continue
+		}
+		expanded = append(expanded, lights...)
+	}
+	return expanded
+}
+
+type byLightSetName []LightSet
+
+func (s byLightSetName) Len() int {
+	return len(s)
+}
+
+func (s byLightSetName) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s byLightSetName) Less(i, j int) bool {
+	return s[i].Name < s[j].Name
+}
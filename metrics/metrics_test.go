@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	tests := []struct {
+		observations []float64
+		want         string
+	}{
+		{
+			nil,
+			"",
+		},
+		{
+			[]float64{0.1},
+			`latency_bucket{le="0.1"} 1
+latency_bucket{le="1"} 1
+latency_bucket{le="10"} 1
+latency_bucket{le="+Inf"} 1
+latency_sum 0.1
+latency_count 1
+`,
+		},
+		{
+			// Values on a boundary fall in that bucket; buckets are cumulative.
+			[]float64{0.05, 1, 2, 20, 30},
+			`latency_bucket{le="0.1"} 1
+latency_bucket{le="1"} 2
+latency_bucket{le="10"} 3
+latency_bucket{le="+Inf"} 5
+latency_sum 53.05
+latency_count 5
+`,
+		},
+		{
+			[]float64{math.Inf(1)},
+			`latency_bucket{le="0.1"} 0
+latency_bucket{le="1"} 0
+latency_bucket{le="10"} 0
+latency_bucket{le="+Inf"} 1
+latency_sum +Inf
+latency_count 1
+`,
+		},
+	}
+	for _, test := range tests {
+		r := NewRegistry()
+		h := r.NewHistogram("latency", "How long it took.", []float64{.1, 1, 10})
+		for _, v := range test.observations {
+			h.Observe(v)
+		}
+		want := "# HELP latency How long it took.\n# TYPE latency histogram\n" + test.want
+		var buf bytes.Buffer
+		if _, err := r.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("after %v got\n%s\nwant\n%s", test.observations, buf.String(), want)
+		}
+	}
+}
+
+func TestHistogramLabels(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("request_seconds", "Request latency.", []float64{1}, "method", "path")
+	h.Observe(2, "GET", "/lights")
+	h.Observe(0.5, "GET", `/"quoted"`)
+	want := `# HELP request_seconds Request latency.
+# TYPE request_seconds histogram
+request_seconds_bucket{method="GET",path="/\"quoted\"",le="1"} 1
+request_seconds_bucket{method="GET",path="/\"quoted\"",le="+Inf"} 1
+request_seconds_sum{method="GET",path="/\"quoted\""} 0.5
+request_seconds_count{method="GET",path="/\"quoted\""} 1
+request_seconds_bucket{method="GET",path="/lights",le="1"} 0
+request_seconds_bucket{method="GET",path="/lights",le="+Inf"} 1
+request_seconds_sum{method="GET",path="/lights"} 2
+request_seconds_count{method="GET",path="/lights"} 1
+`
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want || n != int64(len(want)) {
+		t.Errorf("wrote %d bytes\n%s\nwant %d bytes\n%s", n, buf.String(), len(want), want)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("b_total", "Things.\nCounted.", "kind")
+	g := r.NewGauge("a", "A gauge.")
+	c.Inc("x")
+	c.Add(2.5, "x")
+	g.Set(3)
+	g.Add(-4)
+	want := `# HELP a A gauge.
+# TYPE a gauge
+a -1
+# HELP b_total Things.\nCounted.
+# TYPE b_total counter
+b_total{kind="x"} 3.5
+`
+	var buf bytes.Buffer
+	r.WriteTo(&buf)
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	c.Reset()
+	buf.Reset()
+	r.WriteTo(&buf)
+	if want := "# HELP a A gauge.\n# TYPE a gauge\na -1\n# HELP b_total Things.\\nCounted.\n# TYPE b_total counter\n"; buf.String() != want {
+		t.Errorf("after reset got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestMisuse(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(r *Registry)
+	}{
+		{"registered twice", func(r *Registry) {
+			r.NewGauge("a", "")
+			r.NewCounter("a", "")
+		}},
+		{"too few labels", func(r *Registry) {
+			r.NewHistogram("a", "", DefaultBuckets, "method").Observe(1)
+		}},
+		{"too many labels", func(r *Registry) {
+			r.NewGauge("a", "").Set(1, "GET")
+		}},
+		{"decreasing counter", func(r *Registry) {
+			r.NewCounter("a", "").Add(-1)
+		}},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: didn't panic", test.name)
+				}
+			}()
+			test.f(NewRegistry())
+		}()
+	}
+}
@@ -0,0 +1,183 @@
+package palette
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+)
+
+const DefaultMonitorInterval = 2 * time.Second
+
+// LightStatus is a light's identity and current state, along with the color it
+// is showing.
+type LightStatus struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	hue.LightState
+	Color string `json:"color"`
+}
+
+// GetStatus fetches the state of each light, in the same order as lights. On
+// error the statuses of the lights that could be read are still returned,
+// along with LightErrors for the rest.
+func (p *Palette) GetStatus(lights []hue.Light) ([]LightStatus, error) {
+	byID := make(map[string]*hue.LightAttributes, len(lights))
+	var errs LightErrors
+	for attrsOrErr := range p.GetGroup(lights) {
+		if attrsOrErr.Error != nil {
+			errs = append(errs, attrsOrErr.Error.(*LightError))
+			continue
+		}
+		byID[attrsOrErr.Light.Id] = attrsOrErr.LightAttributes
+	}
+	statuses := make([]LightStatus, 0, len(lights))
+	for _, light := range lights {
+		attrs := byID[light.Id]
+		if attrs == nil || attrs.State == nil {
+			continue
+		}
+		status := LightStatus{Id: light.Id, Name: light.Name, LightState: *attrs.State}
+		if attrs.Name != "" {
+			status.Name = attrs.Name
+		}
+		if status.On != nil && *status.On {
+			status.Color = HexFromColor(ColorFromStateInGamut(status.LightState, GamutForModel(attrs.ModelId)))
+		} else {
+			status.Color = "#000000"
+		}
+		statuses = append(statuses, status)
+	}
+	if errs != nil {
+		return statuses, errs
+	}
+	return statuses, nil
+}
+
+// Gamuts returns the color gamut of each light by ID, as GetStatus uses to
+// convert their states to colors. Lights that can't be read get the default
+// gamut.
+func (p *Palette) Gamuts(lights []hue.Light) map[string]Gamut {
+	gamuts := make(map[string]Gamut, len(lights))
+	for _, light := range lights {
+		gamuts[light.Id] = GamutForModel("")
+	}
+	for attrsOrErr := range p.GetGroup(lights) {
+		if attrsOrErr.Error == nil {
+			gamuts[attrsOrErr.Light.Id] = GamutForModel(attrsOrErr.LightAttributes.ModelId)
+		}
+	}
+	return gamuts
+}
+
+// Monitor polls the bridge while anyone is subscribed, and sends subscribers
+// every snapshot of the lights that differs from the last.
+type Monitor struct {
+	Interval time.Duration
+
+	palette     *Palette
+	mu          sync.Mutex
+	subscribers map[chan []LightStatus]bool
+	last        []LightStatus
+	lastJSON    []byte
+	stop        chan struct{}
+}
+
+func (p *Palette) NewMonitor() *Monitor {
+	return &Monitor{
+		Interval:    DefaultMonitorInterval,
+		palette:     p,
+		subscribers: make(map[chan []LightStatus]bool),
+	}
+}
+
+// Subscribe returns a channel of light snapshots, starting with the latest
+// one, and a function to cancel the subscription. Slow subscribers only
+// receive the most recent snapshot.
+func (m *Monitor) Subscribe() (<-chan []LightStatus, func()) {
+	ch := make(chan []LightStatus, 1)
+	m.mu.Lock()
+	m.subscribers[ch] = true
+	if m.last != nil {
+		ch <- m.last
+	}
+	if m.stop == nil {
+		m.stop = make(chan struct{})
+		go m.run(m.stop)
+	}
+	m.mu.Unlock()
+
+	cancel := func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if !m.subscribers[ch] {
+			return
+		}
+		delete(m.subscribers, ch)
+		if len(m.subscribers) == 0 && m.stop != nil {
+			close(m.stop)
+			m.stop = nil
+		}
+	}
+	return ch, cancel
+}
+
+// Last returns the most recent snapshot, if any.
+func (m *Monitor) Last() []LightStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+// Refresh polls the bridge immediately if anyone is subscribed, so that
+// changes are seen without waiting for the next interval.
+func (m *Monitor) Refresh() {
+	m.mu.Lock()
+	active := len(m.subscribers) > 0
+	m.mu.Unlock()
+	if active {
+		go m.poll()
+	}
+}
+
+func (m *Monitor) run(stop chan struct{}) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		m.poll()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Monitor) poll() {
+	lights, err := m.palette.GetLights()
+	if err != nil {
+		log.WithField("error", err).Debug("Monitor failed to get lights")
+		return
+	}
+	statuses, err := m.palette.GetStatus(lights)
+	if err != nil {
+		log.WithField("error", err).Debug("Monitor failed to get light states")
+	}
+	b, _ := json.Marshal(statuses)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if string(b) == string(m.lastJSON) {
+		return
+	}
+	m.last, m.lastJSON = statuses, b
+	for ch := range m.subscribers {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- statuses
+	}
+}
@@ -0,0 +1,455 @@
+// Package mqtt is a minimal MQTT 3.1.1 client: enough to publish, subscribe
+// at QoS 0 and leave a last will with the broker.
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPort      = "1883"
+	DefaultKeepAlive = 30 * time.Second
+	DefaultTimeout   = 10 * time.Second
+)
+
+// Control packet types.
+const (
+	typeConnect     = 1
+	typeConnack     = 2
+	typePublish     = 3
+	typePuback      = 4
+	typeSubscribe   = 8
+	typeSuback      = 9
+	typePingreq     = 12
+	typePingresp    = 13
+	typeDisconnect  = 14
+	protocolLevel   = 4
+	maxRemainingLen = 268435455
+)
+
+var (
+	ErrClosed           = errors.New("mqtt: connection closed")
+	ErrKeepAliveTimeout = errors.New("mqtt: broker stopped responding")
+)
+
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// Message is a message published to a topic.
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// Will is the message the broker publishes for the client if it disconnects
+// without saying goodbye.
+type Will struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+type Options struct {
+	// Broker is the broker's address, as host:port or tcp://host:port.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// KeepAlive is how often the broker expects to hear from the client.
+	KeepAlive time.Duration
+	// Timeout limits connecting and each write.
+	Timeout time.Duration
+	Will    *Will
+}
+
+func NewOptions(broker, clientID string) Options {
+	return Options{Broker: broker, ClientID: clientID, KeepAlive: DefaultKeepAlive, Timeout: DefaultTimeout}
+}
+
+// Client is a connection to a broker. Received messages arrive on Messages
+// until the connection ends, when Done is closed.
+type Client struct {
+	opts Options
+	conn net.Conn
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextId  uint16
+	acks    map[uint16]chan []byte
+	err     error
+	pong    time.Time
+
+	messages chan Message
+	done     chan struct{}
+}
+
+// Address returns the broker's host:port from a host, host:port or
+// tcp://host:port.
+func Address(broker string) (string, error) {
+	if strings.Contains(broker, "://") {
+		u, err := url.Parse(broker)
+		if err != nil {
+			return "", err
+		}
+		if u.Scheme != "tcp" && u.Scheme != "mqtt" {
+			return "", fmt.Errorf("mqtt: unsupported scheme %q", u.Scheme)
+		}
+		broker = u.Host
+	}
+	if _, _, err := net.SplitHostPort(broker); err != nil {
+		broker = net.JoinHostPort(broker, DefaultPort)
+	}
+	return broker, nil
+}
+
+// Dial connects to the broker and waits for it to accept the connection.
+func Dial(opts Options) (*Client, error) {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = DefaultKeepAlive
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	addr, err := Address(opts.Broker)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("tcp", addr, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		opts:     opts,
+		conn:     conn,
+		acks:     make(map[uint16]chan []byte),
+		pong:     time.Now(),
+		messages: make(chan Message, 64),
+		done:     make(chan struct{}),
+	}
+	r := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(opts.Timeout))
+	if err := c.write(typeConnect<<4, c.connectPacket()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	header, body, err := readPacket(r)
+	if err == nil && (header>>4 != typeConnack || len(body) != 2) {
+		err = fmt.Errorf("mqtt: expected CONNACK, got packet type %d", header>>4)
+	}
+	if err == nil && body[1] != 0 {
+		reason := connackErrors[body[1]]
+		if reason == "" {
+			reason = fmt.Sprintf("code %d", body[1])
+		}
+		err = errors.New("mqtt: connection refused: " + reason)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	go c.read(r)
+	go c.keepAlive()
+	return c, nil
+}
+
+func (c *Client) connectPacket() []byte {
+	var flags byte = 0x02 // clean session
+	var payload []byte
+	payload = appendString(payload, c.opts.ClientID)
+	if w := c.opts.Will; w != nil {
+		flags |= 0x04 | (w.QoS&3)<<3
+		if w.Retain {
+			flags |= 0x20
+		}
+		payload = appendString(payload, w.Topic)
+		payload = appendBytes(payload, w.Payload)
+	}
+	if c.opts.Username != "" {
+		flags |= 0x80
+		payload = appendString(payload, c.opts.Username)
+		if c.opts.Password != "" {
+			flags |= 0x40
+			payload = appendString(payload, c.opts.Password)
+		}
+	}
+	keepAlive := uint16(c.opts.KeepAlive / time.Second)
+	packet := appendString(nil, "MQTT")
+	packet = append(packet, protocolLevel, flags, byte(keepAlive>>8), byte(keepAlive))
+	return append(packet, payload...)
+}
+
+// Messages delivers the messages published to the client's subscriptions.
+func (c *Client) Messages() <-chan Message {
+	return c.messages
+}
+
+// Done is closed when the connection ends.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the connection ended.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Publish sends a message at QoS 0, which the broker keeps for future
+// subscribers if retain is set.
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	var header byte = typePublish << 4
+	if retain {
+		header |= 0x01
+	}
+	return c.write(header, append(appendString(nil, topic), payload...))
+}
+
+// Subscribe subscribes to topic filters at QoS 0, waiting for the broker to
+// acknowledge them.
+func (c *Client) Subscribe(filters ...string) error {
+	c.mu.Lock()
+	c.nextId++
+	if c.nextId == 0 {
+		c.nextId = 1
+	}
+	id := c.nextId
+	ack := make(chan []byte, 1)
+	c.acks[id] = ack
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.acks, id)
+		c.mu.Unlock()
+	}()
+
+	packet := []byte{byte(id >> 8), byte(id)}
+	for _, filter := range filters {
+		packet = append(appendString(packet, filter), 0)
+	}
+	if err := c.write(typeSubscribe<<4|0x02, packet); err != nil {
+		return err
+	}
+	select {
+	case codes := <-ack:
+		for i, code := range codes {
+			if code == 0x80 && i < len(filters) {
+				return fmt.Errorf("mqtt: subscription to %q refused", filters[i])
+			}
+		}
+		return nil
+	case <-c.done:
+		return c.Err()
+	case <-time.After(c.opts.Timeout):
+		return errors.New("mqtt: timed out waiting for SUBACK")
+	}
+}
+
+// Close disconnects cleanly, so the broker doesn't publish the will.
+func (c *Client) Close() error {
+	err := c.write(typeDisconnect<<4, nil)
+	c.close(ErrClosed)
+	return err
+}
+
+func (c *Client) close(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	c.conn.Close()
+	close(c.done)
+}
+
+func (c *Client) write(header byte, body []byte) error {
+	if len(body) > maxRemainingLen {
+		return errors.New("mqtt: packet too large")
+	}
+	packet := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	packet = append(packet, body...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.Timeout))
+	if _, err := c.conn.Write(packet); err != nil {
+		c.close(err)
+		return err
+	}
+	return nil
+}
+
+func (c *Client) read(r *bufio.Reader) {
+	defer close(c.messages)
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			if err == io.EOF {
+				err = ErrClosed
+			}
+			c.close(err)
+			return
+		}
+		switch header >> 4 {
+		case typePublish:
+			msg, id, err := parsePublish(header, body)
+			if err != nil {
+				c.close(err)
+				return
+			}
+			if header&0x06 != 0 {
+				c.write(typePuback<<4, []byte{byte(id >> 8), byte(id)})
+			}
+			select {
+			case c.messages <- msg:
+			case <-c.done:
+				return
+			}
+		case typeSuback:
+			if len(body) < 2 {
+				continue
+			}
+			id := binary.BigEndian.Uint16(body)
+			c.mu.Lock()
+			ack := c.acks[id]
+			c.mu.Unlock()
+			if ack != nil {
+				ack <- body[2:]
+			}
+		case typePingresp:
+			c.mu.Lock()
+			c.pong = time.Now()
+			c.mu.Unlock()
+		}
+	}
+}
+
+// keepAlive pings the broker, and gives up on it if it stops answering.
+func (c *Client) keepAlive() {
+	ticker := time.NewTicker(c.opts.KeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		c.mu.Lock()
+		since := time.Since(c.pong)
+		c.mu.Unlock()
+		if since > c.opts.KeepAlive+c.opts.Timeout {
+			c.close(ErrKeepAliveTimeout)
+			return
+		}
+		c.write(typePingreq<<4, nil)
+	}
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, multiplier := 0, 1
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, errors.New("mqtt: malformed remaining length")
+		}
+		multiplier *= 128
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+func parsePublish(header byte, body []byte) (Message, uint16, error) {
+	topic, rest, err := readString(body)
+	if err != nil {
+		return Message{}, 0, err
+	}
+	var id uint16
+	if header&0x06 != 0 {
+		if len(rest) < 2 {
+			return Message{}, 0, errors.New("mqtt: malformed PUBLISH")
+		}
+		id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	return Message{Topic: topic, Payload: rest, Retain: header&0x01 != 0}, id, nil
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("mqtt: malformed string")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("mqtt: malformed string")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+func appendString(b []byte, s string) []byte {
+	return appendBytes(b, []byte(s))
+}
+
+func appendBytes(b []byte, s []byte) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// Match reports whether topic matches a subscription filter, with its + and
+// # wildcards.
+func Match(filter, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, part := range f {
+		if part == "#" {
+			return true
+		}
+		if i >= len(t) || (part != "+" && part != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
@@ -0,0 +1,285 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		match         bool
+	}{
+		{"palette/light/1/set", "palette/light/1/set", true},
+		{"palette/light/1/set", "palette/light/2/set", false},
+		{"palette/light/+/set", "palette/light/2/set", true},
+		{"palette/light/+/set", "palette/light/2/state", false},
+		{"palette/light/+", "palette/light/2/set", false},
+		{"palette/+/+/set", "palette/group/living/set", true},
+		{"palette/#", "palette/light/2/set", true},
+		{"palette/#", "palette", true},
+		{"#", "palette/light/2/set", true},
+		{"palette/light/#", "palette/scene", false},
+		{"+", "palette", true},
+		{"+", "palette/light", false},
+		{"palette/+", "palette/", true},
+		{"palette", "palette/light", false},
+		{"palette/light", "palette", false},
+		{"Palette/#", "palette/light", false},
+	}
+	for _, test := range tests {
+		if match := Match(test.filter, test.topic); match != test.match {
+			t.Errorf("Match(%q, %q) = %t, want %t", test.filter, test.topic, match, test.match)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		broker, addr string
+		ok           bool
+	}{
+		{"localhost", "localhost:1883", true},
+		{"10.0.0.5:8883", "10.0.0.5:8883", true},
+		{"tcp://broker.local", "broker.local:1883", true},
+		{"mqtt://broker.local:1884", "broker.local:1884", true},
+		{"::1", "[::1]:1883", true},
+		{"ws://broker.local", "", false},
+	}
+	for _, test := range tests {
+		addr, err := Address(test.broker)
+		if addr != test.addr || (err == nil) != test.ok {
+			t.Errorf("Address(%q) = %q, %v; want %q, ok %t", test.broker, addr, err, test.addr, test.ok)
+		}
+	}
+}
+
+func TestConnectPacket(t *testing.T) {
+	header := "\x00\x04MQTT\x04"
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		{
+			NewOptions("localhost", "palette"),
+			header + "\x02\x00\x1e" + "\x00\x07palette",
+		},
+		{
+			Options{ClientID: "p", KeepAlive: 300 * time.Second, Username: "user"},
+			header + "\x82\x01\x2c" + "\x00\x01p" + "\x00\x04user",
+		},
+		{
+			Options{ClientID: "p", KeepAlive: time.Minute, Username: "user", Password: "secret",
+				Will: &Will{Topic: "palette/status", Payload: []byte("offline"), QoS: 1, Retain: true}},
+			header + "\xee\x00\x3c" + "\x00\x01p" + "\x00\x0epalette/status" + "\x00\x07offline" + "\x00\x04user" + "\x00\x06secret",
+		},
+	}
+	for _, test := range tests {
+		c := &Client{opts: test.opts}
+		if packet := c.connectPacket(); string(packet) != test.want {
+			t.Errorf("%+v: got %q, want %q", test.opts, packet, test.want)
+		}
+	}
+}
+
+func TestWriteRemainingLength(t *testing.T) {
+	tests := []struct {
+		length int
+		want   []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+	}
+	for _, test := range tests {
+		client, broker := net.Pipe()
+		c := &Client{opts: Options{Timeout: time.Second}, conn: client, done: make(chan struct{})}
+		body := bytes.Repeat([]byte{'x'}, test.length)
+		go c.write(typePublish<<4|0x01, body)
+
+		r := bufio.NewReader(broker)
+		prefix := make([]byte, 1+len(test.want))
+		if _, err := io.ReadFull(r, prefix); err != nil {
+			t.Fatal(err)
+		}
+		if want := append([]byte{typePublish<<4 | 0x01}, test.want...); !bytes.Equal(prefix, want) {
+			t.Errorf("%d: header % x, want % x", test.length, prefix, want)
+		}
+		read := make([]byte, test.length)
+		if _, err := io.ReadFull(r, read); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(read, body) {
+			t.Errorf("%d: body differs", test.length)
+		}
+		client.Close()
+		broker.Close()
+	}
+}
+
+func TestReadPacket(t *testing.T) {
+	tests := []struct {
+		input  string
+		header byte
+		body   string
+		ok     bool
+	}{
+		{"\xd0\x00", typePingresp << 4, "", true},
+		{"\x20\x02\x00\x00", typeConnack << 4, "\x00\x00", true},
+		{"\x30\x80\x01" + strings.Repeat("y", 128), typePublish << 4, strings.Repeat("y", 128), true},
+		{"", 0, "", false},
+		{"\x30", 0, "", false},
+		{"\x30\x05abc", 0, "", false},
+		{"\x30\xff\xff\xff\xff\x01", 0, "", false},
+	}
+	for _, test := range tests {
+		header, body, err := readPacket(bufio.NewReader(strings.NewReader(test.input)))
+		if (err == nil) != test.ok || header != test.header || string(body) != test.body {
+			t.Errorf("readPacket(%q) = %x, %q, %v; want %x, %q, ok %t", test.input, header, body, err, test.header, test.body, test.ok)
+		}
+	}
+}
+
+func TestParsePublish(t *testing.T) {
+	tests := []struct {
+		header byte
+		body   string
+		msg    Message
+		id     uint16
+		ok     bool
+	}{
+		{0x30, "\x00\x03a/b{\"on\":true}", Message{Topic: "a/b", Payload: []byte(`{"on":true}`)}, 0, true},
+		{0x31, "\x00\x03a/b", Message{Topic: "a/b", Payload: []byte{}, Retain: true}, 0, true},
+		{0x32, "\x00\x03a/b\x01\x02on", Message{Topic: "a/b", Payload: []byte("on")}, 0x0102, true},
+		{0x32, "\x00\x03a/b\x01", Message{}, 0, false},
+		{0x30, "\x00\x09a/b", Message{}, 0, false},
+		{0x30, "\x00", Message{}, 0, false},
+	}
+	for _, test := range tests {
+		msg, id, err := parsePublish(test.header, []byte(test.body))
+		if (err == nil) != test.ok || id != test.id || !reflect.DeepEqual(msg, test.msg) {
+			t.Errorf("parsePublish(%x, %q) = %+v, %d, %v; want %+v, %d, ok %t", test.header, test.body, msg, id, err, test.msg, test.id, test.ok)
+		}
+	}
+}
+
+// fakeBroker accepts one connection, answers its CONNECT with code, and
+// hands the connection to serve.
+func fakeBroker(t *testing.T, code byte, serve func(r *bufio.Reader, conn net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer l.Close()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if header, _, err := readPacket(r); err != nil || header != typeConnect<<4 {
+			return
+		}
+		conn.Write([]byte{typeConnack << 4, 2, 0, code})
+		if serve != nil {
+			serve(r, conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestDialRefused(t *testing.T) {
+	tests := []struct {
+		code byte
+		err  string
+	}{
+		{4, "mqtt: connection refused: bad user name or password"},
+		{5, "mqtt: connection refused: not authorized"},
+		{9, "mqtt: connection refused: code 9"},
+	}
+	for _, test := range tests {
+		addr := fakeBroker(t, test.code, nil)
+		c, err := Dial(NewOptions(addr, "palette"))
+		if err == nil {
+			c.Close()
+		}
+		if err == nil || err.Error() != test.err {
+			t.Errorf("code %d: got %v, want %s", test.code, err, test.err)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	published := make(chan []byte, 1)
+	addr := fakeBroker(t, 0, func(r *bufio.Reader, conn net.Conn) {
+		// SUBSCRIBE, answered with one granted and one refused filter.
+		header, body, err := readPacket(r)
+		if err != nil || header != typeSubscribe<<4|0x02 {
+			return
+		}
+		conn.Write(append([]byte{typeSuback << 4, 4}, body[0], body[1], 0, 0x80))
+		header, body, err = readPacket(r)
+		if err != nil || header != typeSubscribe<<4|0x02 {
+			return
+		}
+		conn.Write(append([]byte{typeSuback << 4, 3}, body[0], body[1], 0))
+		// A QoS 1 message, which the client must acknowledge.
+		conn.Write([]byte("\x32\x0b\x00\x05a/b/c\x00\x07hi"))
+		if header, body, err = readPacket(r); err == nil && header == typePuback<<4 {
+			published <- body
+		}
+		header, body, err = readPacket(r)
+		if err == nil && header == typePublish<<4|0x01 {
+			published <- body
+		}
+		readPacket(r)
+	})
+
+	c, err := Dial(NewOptions(addr, "palette"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Subscribe("a/#", "$SYS/#"); err == nil || !strings.Contains(err.Error(), `"$SYS/#"`) {
+		t.Errorf("refused subscription: got %v", err)
+	}
+	if err := c.Subscribe("a/+/c"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-c.Messages():
+		if msg.Topic != "a/b/c" || string(msg.Payload) != "hi" || msg.Retain {
+			t.Errorf("received %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message")
+	}
+	if ack := <-published; !bytes.Equal(ack, []byte{0, 7}) {
+		t.Errorf("acknowledged % x, want 00 07", ack)
+	}
+
+	if err := c.Publish("palette/state", []byte("on"), true); err != nil {
+		t.Fatal(err)
+	}
+	if body := <-published; string(body) != "\x00\x0dpalette/stateon" {
+		t.Errorf("published %q", body)
+	}
+	c.Close()
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("not done after closing")
+	}
+	if c.Err() != ErrClosed {
+		t.Errorf("closed with %v, want %v", c.Err(), ErrClosed)
+	}
+}
@@ -0,0 +1,166 @@
+package palette
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultPairingWindow is how long pairing waits for someone to press the
+// bridge's link button.
+const DefaultPairingWindow = 90 * time.Second
+
+// pairingInterval is how often pairing is retried while waiting. Pressing the
+// link button allows pairing for 30 seconds, so this is well within it.
+const pairingInterval = 2 * time.Second
+
+// Pairing states of a bridge palette has found but has no credentials for.
+const (
+	PairingUnpaired = "unpaired"
+	PairingWaiting  = "waiting"
+	PairingPaired   = "paired"
+	PairingFailed   = "failed"
+)
+
+var (
+	ErrLinkButtonNotPressed = errors.New("The bridge's link button has not been pressed")
+	ErrUnknownBridge        = errors.New("Unknown bridge")
+)
+
+// PairingStatus is the progress of pairing with a bridge.
+type PairingStatus struct {
+	Bridge   DiscoveredBridge `json:"bridge"`
+	State    string           `json:"state"`
+	Deadline *time.Time       `json:"deadline,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+type pairings struct {
+	mu       sync.Mutex
+	statuses map[string]PairingStatus
+}
+
+func isLinkButtonError(err error) bool {
+	apiErr, ok := err.(*hue.APIError)
+	if !ok {
+		return false
+	}
+	for _, detail := range apiErr.Errors {
+		if detail.Type == hue.LinkButtonNotPressedErrorType {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Palette) setPairing(status PairingStatus) {
+	p.pairings.mu.Lock()
+	defer p.pairings.mu.Unlock()
+	if p.pairings.statuses == nil {
+		p.pairings.statuses = make(map[string]PairingStatus)
+	}
+	p.pairings.statuses[status.Bridge.Id] = status
+}
+
+// notePairing records the outcome of connecting to a bridge, unless it's being
+// paired, in which case Pair records the outcome.
+func (p *Palette) notePairing(bridge DiscoveredBridge, err error) {
+	p.pairings.mu.Lock()
+	current, ok := p.pairings.statuses[bridge.Id]
+	p.pairings.mu.Unlock()
+	switch {
+	case ok && current.State == PairingWaiting:
+	case err == ErrLinkButtonNotPressed:
+		p.setPairing(PairingStatus{Bridge: bridge, State: PairingUnpaired})
+	case err == nil && ok:
+		p.setPairing(PairingStatus{Bridge: bridge, State: PairingPaired})
+	}
+}
+
+// Pairing lists the bridges palette has needed to pair with, and how pairing
+// is going.
+func (p *Palette) Pairing() []PairingStatus {
+	p.pairings.mu.Lock()
+	defer p.pairings.mu.Unlock()
+	statuses := make([]PairingStatus, 0, len(p.pairings.statuses))
+	for _, status := range p.pairings.statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Sort(byPairingBridge(statuses))
+	return statuses
+}
+
+// Pair connects to a bridge, retrying for up to window while waiting for
+// someone to press its link button.
+func (p *Palette) Pair(bridge DiscoveredBridge, window time.Duration) error {
+	if bridge.Id == "" {
+		b := bridge.Bridge()
+		if err := IdentifyBridge(b); err != nil {
+			return err
+		}
+		bridge.Id = b.UniqueId
+	}
+	deadline := time.Now().Add(window)
+	p.setPairing(PairingStatus{Bridge: bridge, State: PairingWaiting, Deadline: &deadline})
+	for {
+		err := p.Connect(bridge)
+		switch {
+		case err == nil:
+			p.setPairing(PairingStatus{Bridge: bridge, State: PairingPaired})
+			return nil
+		case err != ErrLinkButtonNotPressed:
+			p.setPairing(PairingStatus{Bridge: bridge, State: PairingFailed, Error: err.Error()})
+			return err
+		case time.Now().Add(pairingInterval).After(deadline):
+			p.setPairing(PairingStatus{Bridge: bridge, State: PairingUnpaired, Error: err.Error()})
+			return err
+		}
+		time.Sleep(pairingInterval)
+	}
+}
+
+// StartPairing pairs in the background with a bridge palette found but
+// couldn't pair with.
+func (p *Palette) StartPairing(id string, window time.Duration) error {
+	p.pairings.mu.Lock()
+	status, ok := p.pairings.statuses[id]
+	if !ok {
+		p.pairings.mu.Unlock()
+		return ErrUnknownBridge
+	}
+	if status.State == PairingWaiting || status.State == PairingPaired {
+		p.pairings.mu.Unlock()
+		return nil
+	}
+	// Mark the bridge as waiting now, so pairing only starts once.
+	deadline := time.Now().Add(window)
+	p.pairings.statuses[id] = PairingStatus{Bridge: status.Bridge, State: PairingWaiting, Deadline: &deadline}
+	p.pairings.mu.Unlock()
+	go func() {
+		if err := p.Pair(status.Bridge, window); err != nil {
+			log.WithFields(log.Fields{
+				"bridge": id,
+				"error":  err,
+			}).Warn("Failed to pair with bridge")
+		}
+	}()
+	return nil
+}
+
+type byPairingBridge []PairingStatus
+
+func (s byPairingBridge) Len() int {
+	return len(s)
+}
+
+func (s byPairingBridge) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s byPairingBridge) Less(i, j int) bool {
+	return s[i].Bridge.Id < s[j].Bridge.Id
+}
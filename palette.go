@@ -1,70 +1,141 @@
 package palette
 
 import (
-	"encoding/json"
 	"errors"
-	"io/ioutil"
-	"sort"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
 )
 
 const (
 	CONFIGFILE = "palette.json"
+	AUDITFILE  = "audit.jsonl"
 	DEVICETYPE = "palette#Lark"
 )
 
+var ErrInvalidUser = errors.New("Invalid user")
+
+// Palette controls the lights of one or more bridges. With more than one
+// bridge, light IDs are qualified by the bridge's unique ID, as
+// "<bridge>:<light>".
 type Palette struct {
-	*hue.User
+	// dir holds the config and audit log.
+	dir string
+
+	mu         sync.Mutex
+	users      []*hue.User
+	store      *store
+	state      state
+	audit      *AuditLog
+	webhooks   *WebhookSender
+	transition *uint16
+	limiter    *RateLimiter
+
+	pairings pairings
 }
 
-type config struct {
+// bridgeConfig holds the credentials for a bridge and the address it last
+// had.
+type bridgeConfig struct {
 	Username string `json:"username"`
+	Address  string `json:"address,omitempty"`
 }
 
-func New(bridge *hue.Bridge) (*Palette, error) {
-	user, err := bridge.CreateUser(DEVICETYPE, "")
-	if err != nil {
+// LoadFromConfig loads the config saved in dir, if there is one, migrating it
+// from older versions. Bridges are then added with Connect.
+func LoadFromConfig(dir string) (*Palette, error) {
+	p := &Palette{
+		dir:   dir,
+		store: newStore(dir),
+		audit: NewAuditLog(filepath.Join(dir, AUDITFILE)),
+	}
+	if err := p.store.tighten(); err != nil {
 		return nil, err
 	}
-	return &Palette{User: user}, nil
-}
-
-func LoadFromConfig(bridge *hue.Bridge) (*Palette, error) {
-	var c config
-	if configBytes, err := ioutil.ReadFile(CONFIGFILE); err != nil {
+	st, migrated, err := p.store.read()
+	if err != nil {
 		return nil, err
-	} else {
-		err = json.Unmarshal(configBytes, &c)
-		if err != nil {
+	}
+	p.state = st
+	if migrated {
+		if err := p.store.backup(); err != nil {
 			return nil, err
 		}
-		if isValid, err := bridge.IsValidUser(c.Username); err != nil {
+		log.WithFields(log.Fields{
+			"file":    p.store.path,
+			"version": SchemaVersion,
+		}).Info("Migrated config")
+		// update migrates it again, under the lock, before saving it.
+		if err := p.update(func(*state) error { return nil }); err != nil {
 			return nil, err
-		} else if !isValid {
-			return nil, errors.New("Invalid user")
 		}
 	}
-	p := Palette{User: hue.NewUserWithBridge(c.Username, bridge)}
-	return &p, nil
+	return p, nil
 }
 
-func (p *Palette) SaveToConfig() error {
-	config := config{Username: p.Username}
-	b, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return err
+// Dir returns the directory the config and audit log are kept in.
+func (p *Palette) Dir() string {
+	return p.dir
+}
+
+// SetDefaultTransition makes changes that don't give a transition time fade
+// over d instead of the bridge's default of 400ms.
+func (p *Palette) SetDefaultTransition(d time.Duration) {
+	transition := transitionTime(d)
+	p.mu.Lock()
+	p.transition = &transition
+	p.mu.Unlock()
+}
+
+// SetCommandRate limits the light commands sent to the bridge to rate a
+// second, in bursts of up to burst. The bridge drops commands sent faster
+// than about 10 a second. A rate of zero removes the limit.
+func (p *Palette) SetCommandRate(rate float64, burst int) {
+	var limiter *RateLimiter
+	if rate > 0 {
+		limiter = NewRateLimiter(rate, burst)
+	}
+	p.mu.Lock()
+	p.limiter = limiter
+	p.mu.Unlock()
+}
+
+// transitionTime converts d to the bridge's multiples of 100ms.
+func transitionTime(d time.Duration) uint16 {
+	if d > 100*time.Millisecond*(1<<16-1) {
+		return 1<<16 - 1
 	}
-	return ioutil.WriteFile(CONFIGFILE, b, 0666)
+	return uint16(d / (100 * time.Millisecond))
 }
 
-func (p *Palette) GetLights() ([]hue.Light, error) {
-	lights, err := p.User.GetLights()
+// SelectLights returns the lights whose ID or name matches any of the given
+// glob patterns. An empty pattern list selects every light.
+func SelectLights(lights []hue.Light, patterns []string) []hue.Light {
+	if len(patterns) == 0 {
+		return lights
+	}
+	selected := make([]hue.Light, 0, len(lights))
+	for _, light := range lights {
+		for _, pattern := range patterns {
+			if matchPattern(pattern, light.Id) || matchPattern(pattern, light.Name) {
+				selected = append(selected, light)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+func matchPattern(pattern, s string) bool {
+	matched, err := path.Match(pattern, s)
 	if err != nil {
-		return nil, err
+		return pattern == s
 	}
-	sort.Sort(byID(lights))
-	return lights, nil
+	return matched
 }
 
 type byID []hue.Light
@@ -0,0 +1,61 @@
+package palette
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket allowing Rate events a second, in bursts of
+// up to Burst.
+type RateLimiter struct {
+	Rate  float64
+	Burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{Rate: rate, Burst: burst, tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token, returning how long to wait until it's available.
+func (l *RateLimiter) reserve(wait bool) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.Rate
+	if l.tokens > float64(l.Burst) {
+		l.tokens = float64(l.Burst)
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+	delay := time.Duration((1 - l.tokens) / l.Rate * float64(time.Second))
+	if !wait {
+		return delay, false
+	}
+	l.tokens--
+	return delay, true
+}
+
+// Allow takes a token if one is available. Otherwise it returns how long
+// until one will be.
+func (l *RateLimiter) Allow() (bool, time.Duration) {
+	delay, ok := l.reserve(false)
+	return ok, delay
+}
+
+// Wait blocks until a token is available and takes it. Waiting callers are
+// let through in the order they called.
+func (l *RateLimiter) Wait() {
+	if delay, _ := l.reserve(true); delay > 0 {
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,221 @@
+package palette
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/BrianBland/palette/audio"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	DefaultReactiveMinBrightness = 30
+	DefaultReactiveMaxBrightness = 254
+	// DefaultReactiveRotation is how many degrees a second hues turn while
+	// the mid band is at its loudest.
+	DefaultReactiveRotation = 90
+	// DefaultReactiveRate caps the light commands sent a second even without
+	// a bridge rate limit, since the bridge drops commands sent faster than
+	// about 10 a second.
+	DefaultReactiveRate = 10
+
+	// reactiveTrebleShift is how many degrees the treble band pushes hues
+	// ahead of their rotation at its loudest.
+	reactiveTrebleShift = 30
+)
+
+var ErrNoLights = errors.New("No lights to follow the audio")
+
+// AudioReactive drives lights from audio levels. The bass band sets their
+// brightness and beats flash them to full; the mid band turns their hues
+// steadily around the scheme they showed when it started, and the treble band
+// nudges them further. Lights get their original states back when it stops.
+//
+// Updates aren't audited, since there are many a second.
+type AudioReactive struct {
+	Lights        []string
+	MinBrightness uint8
+	MaxBrightness uint8
+	Rotation      float64
+	Rate          float64
+
+	palette *Palette
+	mu      sync.Mutex
+	levels  audio.Levels
+	beat    bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func (p *Palette) NewAudioReactive(lights []string) *AudioReactive {
+	return &AudioReactive{
+		Lights:        lights,
+		MinBrightness: DefaultReactiveMinBrightness,
+		MaxBrightness: DefaultReactiveMaxBrightness,
+		Rotation:      DefaultReactiveRotation,
+		Rate:          DefaultReactiveRate,
+		palette:       p,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Update sets the latest levels. Levels arriving faster than the lights can
+// be updated replace each other, except that a beat is kept until it's shown.
+func (a *AudioReactive) Update(levels audio.Levels) {
+	a.mu.Lock()
+	a.levels = levels
+	a.beat = a.beat || levels.Beat
+	a.mu.Unlock()
+}
+
+func (a *AudioReactive) take() (audio.Levels, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	beat := a.beat
+	a.beat = false
+	return a.levels, beat
+}
+
+// Stop stops updating the lights and waits for their original states to be
+// restored.
+func (a *AudioReactive) Stop() {
+	select {
+	case <-a.stop:
+	default:
+		close(a.stop)
+	}
+	<-a.done
+}
+
+// reactiveBase is a light's state before the music started.
+type reactiveBase struct {
+	light    hue.Light
+	original hue.LightState
+	// color is the light's hue and saturation, or nil for lights showing a
+	// color temperature, which only follow the brightness.
+	color *hue.LightState
+}
+
+// Run updates the lights until Stop is called, as often as the rate limits
+// allow.
+func (a *AudioReactive) Run() error {
+	defer close(a.done)
+	lights, err := a.palette.GetLights()
+	if err != nil {
+		return err
+	}
+	lights = SelectLights(lights, a.Lights)
+	statuses, err := a.palette.GetStatus(lights)
+	if err != nil && len(statuses) == 0 {
+		return err
+	}
+	if err != nil {
+		log.WithField("error", err).Warn("Leaving out lights that couldn't be read")
+	}
+	bases := make([]reactiveBase, len(statuses))
+	for i, status := range statuses {
+		bases[i] = reactiveBase{
+			light:    hue.Light{Id: status.Id, Name: status.Name},
+			original: writableState(status.LightState),
+		}
+		switch {
+		case status.ColorMode == "xy":
+			state := StateFromColor(ColorFromState(status.LightState))
+			bases[i].color = &state
+		case status.ColorMode != "ct" && status.Hue != nil && status.Saturation != nil:
+			bases[i].color = &hue.LightState{Hue: status.Hue, Saturation: status.Saturation}
+		}
+	}
+	if len(bases) == 0 {
+		return ErrNoLights
+	}
+
+	// Each round sets every light once, so rounds are spaced to keep within
+	// the rate.
+	rate := a.Rate
+	if rate <= 0 {
+		rate = DefaultReactiveRate
+	}
+	if limit := a.palette.commandRate(); limit > 0 && limit < rate {
+		rate = limit
+	}
+	interval := time.Duration(float64(len(bases)) / rate * float64(time.Second))
+	log.WithFields(log.Fields{
+		"lights":   len(bases),
+		"interval": interval,
+	}).Info("Following audio")
+
+	var rotation float64
+	last := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			a.restore(bases)
+			return nil
+		case now := <-ticker.C:
+			levels, beat := a.take()
+			rotation = math.Mod(rotation+a.Rotation*levels.Mid*now.Sub(last).Seconds(), 360)
+			last = now
+			a.apply(bases, levels, beat, rotation+reactiveTrebleShift*levels.Treble)
+		}
+	}
+}
+
+func (a *AudioReactive) apply(bases []reactiveBase, levels audio.Levels, beat bool, rotation float64) {
+	bri := uint8(float64(a.MinBrightness) + levels.Bass*float64(a.MaxBrightness-a.MinBrightness) + 0.5)
+	// Beats cut straight to full brightness; otherwise fade between updates.
+	transition := uint16(1)
+	if beat {
+		bri = a.MaxBrightness
+		transition = 0
+	}
+	lights := make([]hue.Light, len(bases))
+	states := make([]hue.LightState, len(bases))
+	for i, base := range bases {
+		lights[i] = base.light
+		state := hue.LightState{}
+		if base.color != nil {
+			state = RotateDegrees(*base.color, rotation)
+		}
+		state.On = boolPtr(true)
+		state.Brightness = &bri
+		state.TransitionTime = &transition
+		states[i] = state
+	}
+	for err := range a.palette.SetGroup(lights, states) {
+		if err != nil {
+			log.WithField("error", err).Debug("Audio update failed")
+		}
+	}
+}
+
+func (a *AudioReactive) restore(bases []reactiveBase) {
+	lights := make([]hue.Light, len(bases))
+	states := make([]hue.LightState, len(bases))
+	for i, base := range bases {
+		lights[i] = base.light
+		states[i] = base.original
+	}
+	for err := range a.palette.SetGroup(lights, states) {
+		if err != nil {
+			log.WithField("error", err).Warn("Failed to restore light")
+		}
+	}
+}
+
+// commandRate returns the bridge command rate limit, or 0 if there's none.
+func (p *Palette) commandRate() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.limiter == nil {
+		return 0
+	}
+	return p.limiter.Rate
+}
@@ -0,0 +1,110 @@
+package palette
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/BrianBland/go-hue"
+)
+
+var ErrUnknownScene = errors.New("Unknown scene")
+
+// Scene is a saved state for each of a set of lights, by light ID.
+type Scene struct {
+	Name   string                    `json:"name"`
+	Lights map[string]hue.LightState `json:"lights"`
+}
+
+func (p *Palette) Scenes() []Scene {
+	p.refresh()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	scenes := make([]Scene, 0, len(p.state.Scenes))
+	for _, scene := range p.state.Scenes {
+		scenes = append(scenes, scene)
+	}
+	sort.Sort(byName(scenes))
+	return scenes
+}
+
+// SaveScene captures the current state of lights as the named scene,
+// replacing any scene of the same name, and saves it to the config.
+func (p *Palette) SaveScene(name string, lights []hue.Light) (Scene, error) {
+	statuses, err := p.GetStatus(lights)
+	if err != nil {
+		return Scene{}, err
+	}
+	scene := Scene{Name: name, Lights: make(map[string]hue.LightState, len(statuses))}
+	for _, status := range statuses {
+		scene.Lights[status.Id] = writableState(status.LightState)
+	}
+
+	return scene, p.update(func(st *state) error {
+		st.Scenes[name] = scene
+		return nil
+	})
+}
+
+func (p *Palette) DeleteScene(name string) error {
+	return p.update(func(st *state) error {
+		if _, ok := st.Scenes[name]; !ok {
+			return ErrUnknownScene
+		}
+		delete(st.Scenes, name)
+		return nil
+	})
+}
+
+// RecallScene sets every one of lights that is in the scene back to its saved
+// state, returning the lights being set. The change is audited as change.
+func (p *Palette) RecallScene(change Change, name string, lights []hue.Light) ([]hue.Light, <-chan error, error) {
+	p.refresh()
+	p.mu.Lock()
+	scene, ok := p.state.Scenes[name]
+	p.mu.Unlock()
+	if !ok {
+		return nil, nil, ErrUnknownScene
+	}
+	targets := make([]hue.Light, 0, len(scene.Lights))
+	states := make([]hue.LightState, 0, len(scene.Lights))
+	for _, light := range lights {
+		if state, ok := scene.Lights[light.Id]; ok {
+			targets = append(targets, light)
+			states = append(states, state)
+		}
+	}
+	return targets, p.SetGroupAs(change, targets, states), nil
+}
+
+// writableState strips the read-only fields from a light state, keeping only
+// the color settings for the light's current color mode.
+func writableState(state hue.LightState) hue.LightState {
+	writable := hue.LightState{
+		On:         state.On,
+		Brightness: state.Brightness,
+	}
+	switch state.ColorMode {
+	case "ct":
+		writable.ColorTemp = state.ColorTemp
+	case "xy":
+		writable.XY = state.XY
+	default:
+		writable.Hue = state.Hue
+		writable.Saturation = state.Saturation
+	}
+	return writable
+}
+
+type byName []Scene
+
+func (s byName) Len() int {
+	return len(s)
+}
+
+func (s byName) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s byName) Less(i, j int) bool {
+	return s[i].Name < s[j].Name
+}
@@ -0,0 +1,101 @@
+package palette
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Schedules are the circadian mode and sunrise that were running, kept in the
+// config so they carry on after a restart.
+type Schedules struct {
+	Circadian *CircadianSchedule `json:"circadian,omitempty"`
+	Sunrise   *SunriseSchedule   `json:"sunrise,omitempty"`
+}
+
+type CircadianSchedule struct {
+	Curve        CircadianCurve `json:"curve"`
+	Lights       []string       `json:"lights,omitempty"`
+	Interval     string         `json:"interval"`
+	PauseTimeout string         `json:"pauseTimeout"`
+}
+
+type SunriseSchedule struct {
+	Lights   []string  `json:"lights,omitempty"`
+	Start    time.Time `json:"start"`
+	Duration string    `json:"duration"`
+}
+
+func (p *Palette) Schedules() Schedules {
+	p.refresh()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state.Schedules
+}
+
+// SaveCircadian records the circadian mode to restore after a restart, or
+// with nil, that there's none.
+func (p *Palette) SaveCircadian(c *Circadian) error {
+	var schedule *CircadianSchedule
+	if c != nil {
+		schedule = &CircadianSchedule{
+			Curve:        c.Curve,
+			Lights:       c.Lights,
+			Interval:     c.Interval.String(),
+			PauseTimeout: c.PauseTimeout.String(),
+		}
+	}
+	return p.update(func(st *state) error {
+		st.Schedules.Circadian = schedule
+		return nil
+	})
+}
+
+// SaveSunrise records the sunrise to restore after a restart, or with nil,
+// that there's none.
+func (p *Palette) SaveSunrise(s *Sunrise) error {
+	var schedule *SunriseSchedule
+	if s != nil {
+		schedule = &SunriseSchedule{
+			Lights:   s.Lights,
+			Start:    s.Start,
+			Duration: s.Duration.String(),
+		}
+	}
+	return p.update(func(st *state) error {
+		st.Schedules.Sunrise = schedule
+		return nil
+	})
+}
+
+// RestoreCircadian returns the saved circadian mode, not yet started, or nil
+// if there's none.
+func (p *Palette) RestoreCircadian() *Circadian {
+	schedule := p.Schedules().Circadian
+	if schedule == nil {
+		return nil
+	}
+	c := p.NewCircadian(schedule.Curve, schedule.Lights)
+	if err := c.Curve.Validate(); err != nil {
+		log.WithField("error", err).Warn("Not restoring circadian mode with an invalid curve")
+		return nil
+	}
+	if d, err := time.ParseDuration(schedule.Interval); err == nil && d > 0 {
+		c.Interval = d
+	}
+	if d, err := time.ParseDuration(schedule.PauseTimeout); err == nil && d >= 0 {
+		c.PauseTimeout = d
+	}
+	return c
+}
+
+// RestoreSunrise returns the saved sunrise, not yet run, or nil if there's
+// none still to come. A sunrise that had already started isn't restarted.
+func (p *Palette) RestoreSunrise() *Sunrise {
+	schedule := p.Schedules().Sunrise
+	if schedule == nil || schedule.Start.Before(time.Now()) {
+		return nil
+	}
+	duration, _ := time.ParseDuration(schedule.Duration)
+	return p.NewSunrise(schedule.Lights, schedule.Start, duration)
+}
@@ -0,0 +1,27 @@
+package palette
+
+import (
+	"crypto/sha256"
+)
+
+// SeededPalette is a palette chosen deterministically from a seed string, so
+// that a team or channel can be given a reproducible room color.
+type SeededPalette struct {
+	Scheme     string
+	Hue        uint16
+	Saturation uint8
+	Brightness uint8
+}
+
+// Seed derives a palette from the SHA-256 of seed. Saturation and brightness
+// are kept in the upper part of their ranges so every seed gives a vivid
+// result.
+func Seed(seed string) SeededPalette {
+	sum := sha256.Sum256([]byte(seed))
+	return SeededPalette{
+		Scheme:     SchemeNames[int(sum[0])%len(SchemeNames)],
+		Hue:        uint16(sum[1])<<8 | uint16(sum[2]),
+		Saturation: 180 + sum[3]%(maxLevel-180+1),
+		Brightness: 150 + sum[4]%(maxLevel-150+1),
+	}
+}
@@ -0,0 +1,11 @@
+// Code generated by assets_generate.go from the static directory; DO NOT EDIT.
+
+package server
+
+var assets = map[string]string{
+	"/app.css":    "* {\n  box-sizing: border-box;\n}\n\nbody {\n  margin: 0;\n  font-family: -apple-system, \"Segoe UI\", Roboto, Helvetica, Arial, sans-serif;\n  background: #1b1b1f;\n  color: #eee;\n}\n\nheader {\n  display: flex;\n  flex-wrap: wrap;\n  align-items: center;\n  gap: 0.5em 1em;\n  padding: 0.75em 1em;\n  background: #26262c;\n}\n\nh1 {\n  margin: 0;\n  font-size: 1.4em;\n}\n\nh2 {\n  font-size: 1.1em;\n  margin: 1em 0 0.5em;\n}\n\nmain {\n  padding: 0 1em 2em;\n  max-width: 60em;\n  margin: 0 auto;\n}\n\nbutton, select, input {\n  font: inherit;\n}\n\nbutton {\n  padding: 0.5em 1em;\n  border: 0;\n  border-radius: 4px;\n  background: #4a4a55;\n  color: #fff;\n  cursor: pointer;\n}\n\nbutton:active {\n  background: #5e5e6b;\n}\n\n.status {\n  flex: 1;\n  font-size: 0.85em;\n  color: #aaa;\n}\n\n.status.error {\n  color: #ff7b7b;\n}\n\n.power {\n  display: flex;\n  gap: 0.5em;\n}\n\n.lights {\n  display: grid;\n  grid-template-columns: repeat(auto-fill, minmax(14em, 1fr));\n  gap: 0.75em;\n}\n\n.light {\n  padding: 0.75em;\n  border-radius: 6px;\n  background: #26262c;\n  border-top: 0.5em solid #000;\n}\n\n.light.off {\n  opacity: 0.6;\n}\n\n.light .name {\n  width: 100%;\n  padding: 0.25em;\n  border: 1px solid transparent;\n  background: transparent;\n  color: inherit;\n  font-weight: bold;\n}\n\n.light .name:focus {\n  border-color: #666;\n}\n\n.light .controls {\n  display: flex;\n  align-items: center;\n  gap: 0.5em;\n  margin-top: 0.5em;\n}\n\n.light input[type=range], .scheme input[type=range] {\n  flex: 1;\n  width: 100%;\n}\n\n.light input[type=color] {\n  width: 3em;\n  height: 2em;\n  padding: 0;\n  border: 0;\n  background: none;\n}\n\n.scheme {\n  display: grid;\n  gap: 0.75em;\n  max-width: 30em;\n}\n\n.scheme label {\n  display: flex;\n  align-items: center;\n  gap: 0.5em;\n}\n\n.preview img {\n  max-width: 100%;\n}\n\n.scene-form {\n  display: flex;\n  gap: 0.5em;\n}\n\n.scene-form input {\n  flex: 1;\n  max-width: 20em;\n  padding: 0.5em;\n}\n\n.scenes {\n  list-style: none;\n  padding: 0;\n}\n\n.scenes li {\n  display: flex;\n  align-items: center;\n  gap: 0.5em;\n  padding: 0.4em 0;\n}\n\n.scenes .scene-name {\n  flex: 1;\n}\n\nheader .back {\n  color: #aaa;\n}\n\n.bridges {\n  list-style: none;\n  padding: 0;\n}\n\n.bridges li {\n  display: flex;\n  align-items: center;\n  gap: 1em;\n  padding: 0.4em 0;\n}\n\n.bridges .bridge-name {\n  flex: 1;\n  font-family: monospace;\n}\n\n.bridge-state.paired {\n  color: #7bd88f;\n}\n\n.bridge-state.waiting {\n  color: #ffd866;\n}\n\n.bridge-state.failed {\n  color: #ff7b7b;\n}\n",
+	"/app.js":     "(function() {\n  \"use strict\";\n\n  var lights = [];\n\n  function $(id) {\n    return document.getElementById(id);\n  }\n\n  function setStatus(message, isError) {\n    var status = $(\"status\");\n    status.textContent = message || \"\";\n    status.className = isError ? \"status error\" : \"status\";\n  }\n\n  // The API token, once the server requires one, is kept in local storage.\n  function token() {\n    return window.localStorage.getItem(\"paletteToken\") || \"\";\n  }\n\n  // withToken adds the token to URLs that can't carry an Authorization header,\n  // like images and event streams.\n  function withToken(url) {\n    if (!token()) {\n      return url;\n    }\n    return url + (url.indexOf(\"?\") < 0 ? \"?\" : \"&\") + \"access_token=\" + encodeURIComponent(token());\n  }\n\n  function api(method, url, body, retried) {\n    var options = {method: method, headers: {}};\n    if (body !== undefined) {\n      options.headers[\"Content-Type\"] = \"application/json\";\n      options.body = JSON.stringify(body);\n    }\n    if (token()) {\n      options.headers[\"Authorization\"] = \"Bearer \" + token();\n    }\n    return fetch(url, options).then(function(response) {\n      if (response.status === 401 && !retried) {\n        var entered = window.prompt(\"This server requires an API token:\");\n        if (entered) {\n          window.localStorage.setItem(\"paletteToken\", entered.trim());\n          listen();\n          return api(method, url, body, true);\n        }\n      }\n      return response.text().then(function(text) {\n        var data = null;\n        try {\n          data = text ? JSON.parse(text) : null;\n        } catch (e) {\n          data = null;\n        }\n        if (!response.ok) {\n          var message = data && data.error ? data.error.message : text;\n          var err = new Error(message || response.statusText);\n          err.code = data && data.error ? data.error.code : \"\";\n          throw err;\n        }\n        return data;\n      });\n    }).then(function(data) {\n      setStatus(\"\");\n      return data;\n    }, function(err) {\n      setStatus(err.message, true);\n      throw err;\n    });\n  }\n\n  // Hex color to the bridge's hue (0-65535) and saturation (0-254).\n  function hexToHueSat(hex) {\n    var r = parseInt(hex.substr(1, 2), 16) / 255;\n    var g = parseInt(hex.substr(3, 2), 16) / 255;\n    var b = parseInt(hex.substr(5, 2), 16) / 255;\n    var max = Math.max(r, g, b);\n    var min = Math.min(r, g, b);\n    var d = max - min;\n    var h = 0;\n    if (d !== 0) {\n      if (max === r) {\n        h = ((g - b) / d) % 6;\n      } else if (max === g) {\n        h = (b - r) / d + 2;\n      } else {\n        h = (r - g) / d + 4;\n      }\n    }\n    h = (h * 60 + 360) % 360;\n    return {\n      hue: Math.round(h / 360 * 65535),\n      saturation: max === 0 ? 0 : Math.round(d / max * 254)\n    };\n  }\n\n  function setLight(id, body) {\n    return api(\"PUT\", \"/lights/\" + encodeURIComponent(id), body);\n  }\n\n  function renderLight(light) {\n    var card = document.createElement(\"div\");\n    card.className = \"light\";\n    card.id = \"light-\" + light.id;\n\n    var name = document.createElement(\"input\");\n    name.className = \"name\";\n    name.setAttribute(\"aria-label\", \"Light name\");\n    name.addEventListener(\"change\", function() {\n      api(\"PUT\", \"/lights/\" + encodeURIComponent(light.id) + \"/name\", {name: name.value});\n    });\n\n    var controls = document.createElement(\"div\");\n    controls.className = \"controls\";\n\n    var power = document.createElement(\"input\");\n    power.type = \"checkbox\";\n    power.className = \"power-toggle\";\n    power.setAttribute(\"aria-label\", \"Power\");\n    power.addEventListener(\"change\", function() {\n      setLight(light.id, {on: power.checked});\n    });\n\n    var color = document.createElement(\"input\");\n    color.type = \"color\";\n    color.setAttribute(\"aria-label\", \"Color\");\n    color.addEventListener(\"change\", function() {\n      setLight(light.id, {on: true, color: color.value});\n    });\n\n    var brightness = document.createElement(\"input\");\n    brightness.type = \"range\";\n    brightness.min = 1;\n    brightness.max = 254;\n    brightness.setAttribute(\"aria-label\", \"Brightness\");\n    brightness.addEventListener(\"change\", function() {\n      setLight(light.id, {on: true, brightness: parseInt(brightness.value, 10)});\n    });\n\n    controls.appendChild(power);\n    controls.appendChild(color);\n    controls.appendChild(brightness);\n    card.appendChild(name);\n    card.appendChild(controls);\n    return card;\n  }\n\n  // updateLight refreshes a card from the latest state, leaving alone any\n  // control the user is currently using.\n  function updateLight(card, light) {\n    var on = light.on === true;\n    var inputs = card.getElementsByTagName(\"input\");\n    var name = inputs[0], power = inputs[1], color = inputs[2], brightness = inputs[3];\n    card.className = on ? \"light\" : \"light off\";\n    card.style.borderTopColor = light.color;\n    if (document.activeElement !== name) {\n      name.value = light.name;\n    }\n    power.checked = on;\n    if (document.activeElement !== color && on) {\n      color.value = light.color;\n    }\n    if (document.activeElement !== brightness && light.bri !== undefined) {\n      brightness.value = light.bri;\n    }\n  }\n\n  function renderLights(data) {\n    lights = (data && data.lights) || [];\n    var container = $(\"lights\");\n    var seen = {};\n    lights.forEach(function(light) {\n      var card = $(\"light-\" + light.id);\n      if (!card) {\n        card = renderLight(light);\n        container.appendChild(card);\n      }\n      updateLight(card, light);\n      seen[card.id] = true;\n    });\n    Array.prototype.slice.call(container.children).forEach(function(card) {\n      if (!seen[card.id]) {\n        container.removeChild(card);\n      }\n    });\n    updatePreview();\n  }\n\n  function schemeRequest() {\n    var fields = $(\"scheme-form\").elements;\n    var hueSat = hexToHueSat(fields[\"color\"].value);\n    return {\n      palette: fields[\"palette\"].value,\n      hue: hueSat.hue,\n      saturation: hueSat.saturation,\n      brightness: parseInt(fields[\"brightness\"].value, 10)\n    };\n  }\n\n  function updatePreview() {\n    var req = schemeRequest();\n    var query = [\n      \"palette=\" + encodeURIComponent(req.palette),\n      \"hue=\" + req.hue,\n      \"saturation=\" + req.saturation,\n      \"brightness=\" + req.brightness,\n      \"names=true\"\n    ];\n    if (lights.length > 0) {\n      query.push(\"lights=\" + lights.length);\n    }\n    var src = withToken(\"/palette/preview?\" + query.join(\"&\"));\n    var img = $(\"scheme-preview\");\n    if (img.getAttribute(\"src\") !== src) {\n      img.setAttribute(\"src\", src);\n    }\n  }\n\n  function renderScenes(data) {\n    var list = $(\"scenes\");\n    list.innerHTML = \"\";\n    ((data && data.scenes) || []).forEach(function(scene) {\n      var item = document.createElement(\"li\");\n      var name = document.createElement(\"span\");\n      name.className = \"scene-name\";\n      name.textContent = scene.name;\n      var recall = document.createElement(\"button\");\n      recall.type = \"button\";\n      recall.textContent = \"Recall\";\n      recall.addEventListener(\"click\", function() {\n        api(\"POST\", \"/scenes/\" + encodeURIComponent(scene.name) + \"/recall\").then(renderLights);\n      });\n      var remove = document.createElement(\"button\");\n      remove.type = \"button\";\n      remove.textContent = \"Delete\";\n      remove.addEventListener(\"click\", function() {\n        if (window.confirm(\"Delete scene \\\"\" + scene.name + \"\\\"?\")) {\n          api(\"DELETE\", \"/scenes/\" + encodeURIComponent(scene.name)).then(renderScenes);\n        }\n      });\n      item.appendChild(name);\n      item.appendChild(recall);\n      item.appendChild(remove);\n      list.appendChild(item);\n    });\n  }\n\n  function loadScenes() {\n    return api(\"GET\", \"/scenes\").then(renderScenes);\n  }\n\n  var source = null;\n  var polling = null;\n\n  function listen() {\n    if (!window.EventSource) {\n      if (!polling) {\n        polling = window.setInterval(function() {\n          api(\"GET\", \"/lights\").then(renderLights);\n        }, 5000);\n      }\n      return;\n    }\n    if (source) {\n      source.close();\n    }\n    source = new EventSource(withToken(\"/events\"));\n    source.addEventListener(\"lights\", function(e) {\n      renderLights(JSON.parse(e.data));\n    });\n    source.onerror = function() {\n      setStatus(\"Reconnecting…\", true);\n    };\n    source.onopen = function() {\n      setStatus(\"\");\n    };\n  }\n\n  $(\"all-on\").addEventListener(\"click\", function() {\n    api(\"POST\", \"/on\").then(renderLights);\n  });\n  $(\"all-off\").addEventListener(\"click\", function() {\n    api(\"POST\", \"/off\").then(renderLights);\n  });\n\n  var schemeForm = $(\"scheme-form\");\n  schemeForm.addEventListener(\"change\", updatePreview);\n  schemeForm.addEventListener(\"submit\", function(e) {\n    e.preventDefault();\n    api(\"POST\", \"/palette\", schemeRequest()).then(renderLights);\n  });\n\n  $(\"scene-form\").addEventListener(\"submit\", function(e) {\n    e.preventDefault();\n    var input = this.elements[\"name\"];\n    var name = input.value.trim();\n    if (!name) {\n      return;\n    }\n    api(\"PUT\", \"/scenes/\" + encodeURIComponent(name)).then(loadScenes);\n    input.value = \"\";\n  });\n\n  api(\"GET\", \"/lights\").then(renderLights, function(err) {\n    // Until palette is paired with a bridge there's nothing to show here.\n    if (err.code === \"bridge_unauthorized\") {\n      window.location.href = \"/setup\";\n    }\n  });\n  loadScenes();\n  listen();\n})();\n",
+	"/index.html": "<!DOCTYPE html>\n<html lang=\"en\">\n  <head>\n    <meta charset=\"utf-8\">\n    <meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n    <title>Palette</title>\n    <link rel=\"stylesheet\" href=\"/app.css\">\n  </head>\n  <body>\n    <header>\n      <h1>Palette</h1>\n      <span id=\"status\" class=\"status\"></span>\n      <div class=\"power\">\n        <button id=\"all-on\" type=\"button\">All on</button>\n        <button id=\"all-off\" type=\"button\">All off</button>\n      </div>\n    </header>\n    <main>\n      <section>\n        <h2>Lights</h2>\n        <div id=\"lights\" class=\"lights\"></div>\n      </section>\n      <section>\n        <h2>Scheme</h2>\n        <form id=\"scheme-form\" class=\"scheme\">\n          <label>Palette\n            <select name=\"palette\">\n              <option value=\"complementary\">Complementary</option>\n              <option value=\"triad\" selected>Triad</option>\n              <option value=\"analogous\">Analogous</option>\n              <option value=\"split\">Split complementary</option>\n              <option value=\"rectangle\">Rectangle</option>\n              <option value=\"square\">Square</option>\n            </select>\n          </label>\n          <label>Color <input type=\"color\" name=\"color\" value=\"#0040ff\"></label>\n          <label>Brightness <input type=\"range\" name=\"brightness\" min=\"1\" max=\"254\" value=\"254\"></label>\n          <div class=\"preview\"><img id=\"scheme-preview\" alt=\"Scheme preview\"></div>\n          <button type=\"submit\">Apply scheme</button>\n        </form>\n      </section>\n      <section>\n        <h2>Scenes</h2>\n        <form id=\"scene-form\" class=\"scene-form\">\n          <input name=\"name\" placeholder=\"Scene name\" required>\n          <button type=\"submit\">Save current</button>\n        </form>\n        <ul id=\"scenes\" class=\"scenes\"></ul>\n      </section>\n    </main>\n    <script src=\"/app.js\"></script>\n  </body>\n</html>\n",
+	"/setup.html": "<!DOCTYPE html>\n<html lang=\"en\">\n  <head>\n    <meta charset=\"utf-8\">\n    <meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n    <title>Palette setup</title>\n    <link rel=\"stylesheet\" href=\"/app.css\">\n  </head>\n  <body>\n    <header>\n      <h1>Palette setup</h1>\n      <span id=\"status\" class=\"status\"></span>\n      <a class=\"back\" href=\"/\">Lights</a>\n    </header>\n    <main>\n      <section>\n        <h2>Bridges</h2>\n        <p>\n          To pair with a bridge, choose Pair, then press the round link button\n          on top of the bridge.\n        </p>\n        <p id=\"no-bridges\" hidden>Every bridge palette found is paired.</p>\n        <ul id=\"bridges\" class=\"bridges\"></ul>\n      </section>\n    </main>\n    <script src=\"/setup.js\"></script>\n  </body>\n</html>\n",
+	"/setup.js":   "(function() {\n  \"use strict\";\n\n  var polling = null;\n\n  function $(id) {\n    return document.getElementById(id);\n  }\n\n  function setStatus(message, isError) {\n    var status = $(\"status\");\n    status.textContent = message || \"\";\n    status.className = isError ? \"status error\" : \"status\";\n  }\n\n  function api(method, url, retried) {\n    var headers = {};\n    var token = window.localStorage.getItem(\"paletteToken\");\n    if (token) {\n      headers[\"Authorization\"] = \"Bearer \" + token;\n    }\n    return fetch(url, {method: method, headers: headers}).then(function(response) {\n      if (response.status === 401 && !retried) {\n        var entered = window.prompt(\"This server requires an API token:\");\n        if (entered) {\n          window.localStorage.setItem(\"paletteToken\", entered.trim());\n          return api(method, url, true);\n        }\n      }\n      return response.json().then(function(data) {\n        if (!response.ok) {\n          throw new Error(data && data.error ? data.error.message : response.statusText);\n        }\n        return data;\n      });\n    }).then(function(data) {\n      setStatus(\"\");\n      return data;\n    }, function(err) {\n      setStatus(err.message, true);\n      throw err;\n    });\n  }\n\n  var descriptions = {\n    unpaired: \"Not paired\",\n    waiting: \"Press the link button on the bridge now…\",\n    paired: \"Paired\",\n    failed: \"Pairing failed\"\n  };\n\n  function render(data) {\n    var bridges = (data && data.bridges) || [];\n    var list = $(\"bridges\");\n    list.innerHTML = \"\";\n    $(\"no-bridges\").hidden = bridges.some(function(status) {\n      return status.state !== \"paired\";\n    });\n    bridges.forEach(function(status) {\n      var item = document.createElement(\"li\");\n      var name = document.createElement(\"span\");\n      name.className = \"bridge-name\";\n      name.textContent = status.bridge.id + \" (\" + status.bridge.address + \")\";\n      var state = document.createElement(\"span\");\n      state.className = \"bridge-state \" + status.state;\n      state.textContent = descriptions[status.state] || status.state;\n      if (status.error && status.state !== \"unpaired\") {\n        state.textContent += \": \" + status.error;\n      }\n      item.appendChild(name);\n      item.appendChild(state);\n      if (status.state === \"unpaired\" || status.state === \"failed\") {\n        var pair = document.createElement(\"button\");\n        pair.type = \"button\";\n        pair.textContent = \"Pair\";\n        pair.addEventListener(\"click\", function() {\n          api(\"POST\", \"/pairing/\" + encodeURIComponent(status.bridge.id)).then(render);\n        });\n        item.appendChild(pair);\n      }\n      list.appendChild(item);\n    });\n    var waiting = bridges.some(function(status) {\n      return status.state === \"waiting\";\n    });\n    if (waiting && !polling) {\n      polling = window.setInterval(refresh, 2000);\n    } else if (!waiting && polling) {\n      window.clearInterval(polling);\n      polling = null;\n    }\n  }\n\n  function refresh() {\n    return api(\"GET\", \"/pairing\").then(render);\n  }\n\n  refresh();\n})();\n",
+}
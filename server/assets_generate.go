@@ -0,0 +1,46 @@
+//go:build ignore
+// +build ignore
+
+// This program embeds the files in the static directory into assets.go, so
+// that the web UI is served from the binary. Run it with go generate.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+func main() {
+	files, err := filepath.Glob(filepath.Join("..", "static", "*"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	sort.Strings(files)
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by assets_generate.go from the static directory; DO NOT EDIT.\n\n")
+	buf.WriteString("package server\n\n")
+	buf.WriteString("var assets = map[string]string{\n")
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(&buf, "\t%q: %s,\n", "/"+filepath.Base(file), strconv.Quote(string(b)))
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("assets.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BrianBland/palette"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// change describes the change a request makes, for the audit log.
+func change(r *http.Request, request interface{}) palette.Change {
+	return palette.Change{
+		Caller:   callerName(r),
+		Endpoint: r.Method + " " + r.URL.Path,
+		Request:  request,
+	}
+}
+
+// parseAuditTime accepts either an RFC 3339 time or a duration before now,
+// like 12h.
+func parseAuditTime(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// getAudit returns audit records, newest first, filtered by the since, until
+// and lights query parameters.
+func (s *Server) getAudit(rw http.ResponseWriter, r *http.Request) {
+	if err := validateQuery(r.URL.Query(), auditParameters); err != nil {
+		writeError(rw, err)
+		return
+	}
+	r.ParseForm()
+	q := palette.AuditQuery{Lights: formLights(r.Form), Limit: defaultAuditLimit}
+	var err error
+	if v := r.Form.Get("since"); v != "" {
+		if q.Since, err = parseAuditTime(v); err != nil {
+			writeError(rw, errInvalidParameter("since"))
+			return
+		}
+	}
+	if v := r.Form.Get("until"); v != "" {
+		if q.Until, err = parseAuditTime(v); err != nil {
+			writeError(rw, errInvalidParameter("until"))
+			return
+		}
+	}
+	if v := r.Form.Get("limit"); v != "" {
+		q.Limit, _ = strconv.Atoi(v)
+	}
+
+	audit := s.palette.AuditLog()
+	if audit == nil {
+		writeErrorStatus(rw, http.StatusNotFound, codeNotFound, "Auditing is disabled")
+		return
+	}
+	records, err := audit.Query(q)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if records == nil {
+		records = []palette.AuditRecord{}
+	}
+	writeJSON(rw, struct {
+		Records []palette.AuditRecord `json:"records"`
+	}{
+		Records: records,
+	})
+}
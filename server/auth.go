@@ -0,0 +1,182 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/BrianBland/palette"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/context"
+)
+
+type contextKey int
+
+const (
+	tokenKey contextKey = iota
+	localKey
+)
+
+var (
+	errNoPermittedLights      = errors.New("This token may not use any of the requested lights")
+	errSomeLightsNotPermitted = errors.New("This token may not use every light this affects")
+)
+
+// authorize requires requests to carry a token allowing scope, once any
+// tokens have been created. Tokens are sent as "Authorization: Bearer
+// <token>", or as the access_token query parameter where headers can't be
+// set, as for EventSource.
+func (s *Server) authorize(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if name, ok := context.Get(r, localKey).(string); ok {
+			context.Set(r, tokenKey, palette.Token{Name: name, Scope: palette.ScopeAdmin})
+		} else if s.palette.AuthRequired() {
+			secret := requestToken(r)
+			if secret == "" {
+				rw.Header().Set("WWW-Authenticate", `Bearer realm="palette"`)
+				writeErrorStatus(rw, http.StatusUnauthorized, "unauthorized", "An API token is required")
+				return
+			}
+			token, ok := s.palette.Authenticate(secret)
+			if !ok {
+				rw.Header().Set("WWW-Authenticate", `Bearer realm="palette", error="invalid_token"`)
+				writeErrorStatus(rw, http.StatusUnauthorized, "unauthorized", "Invalid API token")
+				return
+			}
+			if !token.Allows(scope) {
+				writeErrorStatus(rw, http.StatusForbidden, "forbidden", "This token's scope doesn't allow "+scope+" requests")
+				return
+			}
+			context.Set(r, tokenKey, token)
+		}
+		if scope != palette.ScopeRead {
+			log.WithFields(log.Fields{
+				"token":  callerName(r),
+				"method": r.Method,
+				"path":   r.URL.Path,
+			}).Info("Change requested")
+		}
+		handler(rw, r)
+	}
+}
+
+// LocalHandler serves the API to callers in the same process, such as the
+// command line, which already have the config and so act as an admin.
+func (s *Server) LocalHandler() http.Handler {
+	return s.localHandler("local")
+}
+
+// LocalTransport hands requests straight to LocalHandler, for clients in the
+// same process.
+func (s *Server) LocalTransport() http.RoundTripper {
+	return handlerTransport{s.LocalHandler()}
+}
+
+// localHandler serves the API as an admin, with changes logged as made by
+// name.
+func (s *Server) localHandler(name string) http.Handler {
+	handler := s.Handler()
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		context.Set(r, localKey, name)
+		handler.ServeHTTP(rw, r)
+	})
+}
+
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// caller returns the token the request was authorized with, if any.
+func caller(r *http.Request) (palette.Token, bool) {
+	token, ok := context.Get(r, tokenKey).(palette.Token)
+	return token, ok
+}
+
+// callerName identifies who made a request, for logs and auditing.
+func callerName(r *http.Request) string {
+	if token, ok := caller(r); ok {
+		return token.Name
+	}
+	return "anonymous"
+}
+
+// lights returns the bridge's lights that the request's token may use.
+func (s *Server) lights(r *http.Request) ([]hue.Light, error) {
+	lights, err := s.palette.GetLights()
+	if err != nil {
+		return nil, err
+	}
+	if token, ok := caller(r); ok {
+		return token.AllowedLights(lights), nil
+	}
+	return lights, nil
+}
+
+// selectLights returns the lights matching patterns, which may name light
+// sets.
+func (s *Server) selectLights(lights []hue.Light, patterns []string) []hue.Light {
+	return palette.SelectLights(lights, s.palette.ExpandLightSets(patterns))
+}
+
+// lightPatterns expands light sets and restricts light patterns for
+// background tasks, like circadian mode, to the lights the request's token
+// may use. Tokens limited to a set of lights get the IDs of the matching
+// lights they're allowed.
+func (s *Server) lightPatterns(r *http.Request, patterns []string) ([]string, error) {
+	patterns = s.palette.ExpandLightSets(patterns)
+	if token, ok := caller(r); !ok || len(token.Lights) == 0 {
+		return patterns, nil
+	}
+	lights, err := s.lights(r)
+	if err != nil {
+		return nil, err
+	}
+	lights = palette.SelectLights(lights, patterns)
+	if len(lights) == 0 {
+		return nil, errNoPermittedLights
+	}
+	ids := make([]string, len(lights))
+	for i, light := range lights {
+		ids[i] = light.Id
+	}
+	return ids, nil
+}
+
+// mayUseAll checks that the request's token may use every light matching
+// patterns, or every light when there are none, as it must to replace or
+// remove something shared that affects them, like a scene or a schedule.
+func (s *Server) mayUseAll(r *http.Request, patterns []string) error {
+	token, ok := caller(r)
+	if !ok || len(token.Lights) == 0 {
+		return nil
+	}
+	lights, err := s.palette.GetLights()
+	if err != nil {
+		return err
+	}
+	lights = palette.SelectLights(lights, patterns)
+	if len(token.AllowedLights(lights)) < len(lights) {
+		return errSomeLightsNotPermitted
+	}
+	return nil
+}
+
+// visibleStatuses drops the lights the request's token may not use.
+func visibleStatuses(r *http.Request, statuses []palette.LightStatus) []palette.LightStatus {
+	token, ok := caller(r)
+	if !ok || len(token.Lights) == 0 {
+		return statuses
+	}
+	visible := make([]palette.LightStatus, 0, len(statuses))
+	for _, status := range statuses {
+		if len(token.AllowedLights([]hue.Light{{Id: status.Id, Name: status.Name}})) > 0 {
+			visible = append(visible, status)
+		}
+	}
+	return visible
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/BrianBland/palette"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+type circadianRequest struct {
+	Curve        palette.CircadianCurve `json:"curve"`
+	Lights       []string               `json:"lights"`
+	Interval     string                 `json:"interval"`
+	PauseTimeout string                 `json:"pauseTimeout"`
+}
+
+func (s *Server) getCircadian(rw http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	circadian := s.circadian
+	s.mu.Unlock()
+	if circadian == nil {
+		writeJSON(rw, palette.CircadianStatus{Curve: palette.DefaultCircadianCurve})
+		return
+	}
+	writeJSON(rw, circadian.Status())
+}
+
+func (s *Server) startCircadian(rw http.ResponseWriter, r *http.Request) {
+	var req circadianRequest
+	err := decodeBody(r, "CircadianRequest", &req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	patterns, err := s.lightPatterns(r, req.Lights)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := s.mayStopCircadian(r); err != nil {
+		writeError(rw, err)
+		return
+	}
+	circadian := s.palette.NewCircadian(req.Curve, patterns)
+	if err := circadian.Curve.Validate(); err != nil {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	if req.Interval != "" {
+		circadian.Interval, err = time.ParseDuration(req.Interval)
+		if err != nil || circadian.Interval <= 0 {
+			writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Invalid interval")
+			return
+		}
+	}
+	if req.PauseTimeout != "" {
+		circadian.PauseTimeout, err = time.ParseDuration(req.PauseTimeout)
+		if err != nil || circadian.PauseTimeout < 0 {
+			writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Invalid pauseTimeout")
+			return
+		}
+	}
+
+	s.mu.Lock()
+	previous := s.circadian
+	s.circadian = circadian
+	s.mu.Unlock()
+	if previous != nil {
+		previous.Stop()
+	}
+	log.WithField("lights", req.Lights).Debug("Starting circadian mode")
+	circadian.Start()
+	if err := s.palette.SaveCircadian(circadian); err != nil {
+		log.WithField("error", err).Warn("Failed to save circadian mode")
+	}
+	writeJSON(rw, circadian.Status())
+}
+
+func (s *Server) stopCircadian(rw http.ResponseWriter, r *http.Request) {
+	if err := s.mayStopCircadian(r); err != nil {
+		writeError(rw, err)
+		return
+	}
+	s.mu.Lock()
+	circadian := s.circadian
+	s.circadian = nil
+	s.mu.Unlock()
+	if err := s.palette.SaveCircadian(nil); err != nil {
+		log.WithField("error", err).Warn("Failed to save circadian mode")
+	}
+	if circadian == nil {
+		writeJSON(rw, palette.CircadianStatus{Curve: palette.DefaultCircadianCurve})
+		return
+	}
+	log.Debug("Stopping circadian mode")
+	circadian.Stop()
+	writeJSON(rw, circadian.Status())
+}
+
+// mayStopCircadian checks that the request's token may use every light the
+// running circadian mode sets, if any, since starting another stops it.
+func (s *Server) mayStopCircadian(r *http.Request) error {
+	s.mu.Lock()
+	circadian := s.circadian
+	s.mu.Unlock()
+	if circadian == nil {
+		return nil
+	}
+	return s.mayUseAll(r, circadian.Lights)
+}
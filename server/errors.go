@@ -0,0 +1,238 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/BrianBland/palette"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	codeBadRequest  = "bad_request"
+	codeNotFound    = "not_found"
+	codeUnsatisfied = "unsatisfiable_constraints"
+	codeInternal    = "internal_error"
+
+	statusUnprocessableEntity = 422
+)
+
+// errorResponse is the body of every error response:
+//
+//	{"error": {"code": "device_off", "message": "...", "details": [...]}}
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code    string        `json:"code"`
+	Message string        `json:"message"`
+	Details []errorDetail `json:"details,omitempty"`
+}
+
+// errorDetail describes one underlying failure, with the light it concerns and
+// the bridge's error type and address when the bridge reported it.
+type errorDetail struct {
+	Light       string `json:"light,omitempty"`
+	Bridge      string `json:"bridge,omitempty"`
+	Field       string `json:"field,omitempty"`
+	Type        int    `json:"type,omitempty"`
+	Address     string `json:"address,omitempty"`
+	Description string `json:"description"`
+
+	class errorClass
+}
+
+// errorClass maps a kind of failure to its HTTP status. When a request fails
+// in several ways the class listed first wins.
+type errorClass struct {
+	status  int
+	code    string
+	message string
+}
+
+var errorClasses = []errorClass{
+	classBadRequest,
+	classInvalidRequest,
+	classForbidden,
+	classUnpaired,
+	classUnreachable,
+	classBridge,
+	classInternal,
+	classNotAvailable,
+	classDeviceOff,
+	classInvalidParameter,
+}
+
+var (
+	classBadRequest = errorClass{
+		status:  http.StatusBadRequest,
+		code:    codeBadRequest,
+		message: "Invalid request body",
+	}
+	classInvalidRequest = errorClass{
+		status:  statusUnprocessableEntity,
+		code:    "invalid_request",
+		message: "The request has invalid fields",
+	}
+	classForbidden = errorClass{
+		status:  http.StatusForbidden,
+		code:    "forbidden",
+		message: http.StatusText(http.StatusForbidden),
+	}
+	classUnpaired = errorClass{
+		status:  http.StatusServiceUnavailable,
+		code:    "bridge_unauthorized",
+		message: "Palette isn't paired with the bridge; pair with it at /setup",
+	}
+	classUnreachable = errorClass{
+		status:  http.StatusBadGateway,
+		code:    "bridge_unreachable",
+		message: "The bridge could not be reached",
+	}
+	classBridge = errorClass{
+		status:  http.StatusBadGateway,
+		code:    "bridge_error",
+		message: "The bridge failed to handle the request",
+	}
+	classInternal = errorClass{
+		status:  http.StatusInternalServerError,
+		code:    codeInternal,
+		message: http.StatusText(http.StatusInternalServerError),
+	}
+	classNotAvailable = errorClass{
+		status:  http.StatusNotFound,
+		code:    "resource_not_available",
+		message: "A light or resource is not available on the bridge",
+	}
+	classDeviceOff = errorClass{
+		status:  http.StatusConflict,
+		code:    "device_off",
+		message: "A light is off and can't change its color",
+	}
+	classInvalidParameter = errorClass{
+		status:  statusUnprocessableEntity,
+		code:    "invalid_parameter",
+		message: "The bridge rejected a parameter",
+	}
+)
+
+func classifyAPIError(errorType int) errorClass {
+	switch errorType {
+	case hue.UnauthorizedUserErrorType, hue.LinkButtonNotPressedErrorType:
+		return classUnpaired
+	case hue.ResourceNotAvailableErrorType:
+		return classNotAvailable
+	case hue.DeviceIsOffErrorType:
+		return classDeviceOff
+	case hue.MissingParameterErrorType, hue.ParameterNotAvailableErrorType,
+		hue.InvalidParameterValueErrorType, hue.ParameterNotModifiableErrorType:
+		return classInvalidParameter
+	case hue.InvalidJsonErrorType, hue.MethodNotAvailableErrorType:
+		return classInternal
+	}
+	return classBridge
+}
+
+func errorDetails(err error) []errorDetail {
+	switch err {
+	case errNoPermittedLights, errSomeLightsNotPermitted:
+		return []errorDetail{{Description: err.Error(), class: classForbidden}}
+	case palette.ErrNoBridges:
+		return []errorDetail{{Description: err.Error(), class: classUnpaired}}
+	case palette.ErrUnknownLight:
+		return []errorDetail{{Description: err.Error(), class: classNotAvailable}}
+	}
+	switch e := err.(type) {
+	case palette.LightErrors:
+		var details []errorDetail
+		for _, lightErr := range e {
+			details = append(details, errorDetails(lightErr)...)
+		}
+		return details
+	case *palette.LightError:
+		details := errorDetails(e.Err)
+		for i := range details {
+			details[i].Light = e.Light.Id
+		}
+		return details
+	case *palette.BridgeError:
+		details := errorDetails(e.Err)
+		for i := range details {
+			details[i].Bridge = e.Bridge
+		}
+		return details
+	case *hue.APIError:
+		details := make([]errorDetail, len(e.Errors))
+		for i, apiErr := range e.Errors {
+			details[i] = errorDetail{
+				Type:        apiErr.Type,
+				Address:     apiErr.Address,
+				Description: apiErr.Description,
+				class:       classifyAPIError(apiErr.Type),
+			}
+		}
+		return details
+	case validationErrors:
+		details := make([]errorDetail, len(e))
+		for i, fieldErr := range e {
+			details[i] = errorDetail{
+				Field:       fieldErr.Field,
+				Description: fieldErr.Message,
+				class:       classInvalidRequest,
+			}
+		}
+		return details
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return []errorDetail{{Description: e.Error(), class: classBadRequest}}
+	case *hue.APIParseError:
+		return []errorDetail{{Description: e.Error(), class: classBridge}}
+	case *url.Error, net.Error:
+		return []errorDetail{{Description: e.Error(), class: classUnreachable}}
+	}
+	return []errorDetail{{Description: err.Error(), class: classInternal}}
+}
+
+// writeError responds with the error envelope for err, choosing the status
+// from the most serious of its underlying failures.
+func writeError(rw http.ResponseWriter, err error) {
+	details := errorDetails(err)
+	class := classInternal
+	for _, candidate := range errorClasses {
+		found := false
+		for _, detail := range details {
+			if detail.class == candidate {
+				found = true
+				break
+			}
+		}
+		if found {
+			class = candidate
+			break
+		}
+	}
+	message := class.message
+	if len(details) == 1 && class != classInternal {
+		message = details[0].Description
+		if details[0].Field != "" {
+			message = details[0].Field + ": " + message
+		}
+	}
+	log.WithFields(log.Fields{
+		"code":  class.code,
+		"error": err,
+	}).Warn("Request failed")
+	writeJSONStatus(rw, errorResponse{
+		Error: errorBody{Code: class.code, Message: message, Details: details},
+	}, class.status)
+}
+
+func writeErrorStatus(rw http.ResponseWriter, status int, code, message string) {
+	writeJSONStatus(rw, errorResponse{
+		Error: errorBody{Code: code, Message: message},
+	}, status)
+}
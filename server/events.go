@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/BrianBland/palette"
+)
+
+// events streams light snapshots as server-sent events whenever they change.
+func (s *Server) events(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		writeErrorStatus(rw, http.StatusInternalServerError, codeInternal, "Streaming unsupported")
+		return
+	}
+	var closed <-chan bool
+	if notifier, ok := rw.(http.CloseNotifier); ok {
+		closed = notifier.CloseNotify()
+	}
+
+	updates, cancel := s.monitor.Subscribe()
+	defer cancel()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-closed:
+			return
+		case statuses := <-updates:
+			b, err := json.Marshal(struct {
+				Lights []palette.LightStatus `json:"lights"`
+			}{
+				Lights: visibleStatuses(r, statuses),
+			})
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(rw, "event: lights\ndata: %s\n\n", b); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/BrianBland/palette"
+)
+
+// DefaultHealthCacheTTL is how long a readiness check is reused, so frequent
+// probes don't load the bridge.
+const DefaultHealthCacheTTL = 10 * time.Second
+
+// healthz reports that the process is up, without checking the bridge.
+func (s *Server) healthz(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, struct {
+		Status string `json:"status"`
+	}{
+		Status: "ok",
+	})
+}
+
+// readyz reports whether palette can control the lights. It responds 503
+// while unavailable, and 200 when ready or degraded.
+func (s *Server) readyz(rw http.ResponseWriter, r *http.Request) {
+	health := s.health()
+	status := http.StatusOK
+	if health.Status == palette.HealthUnavailable {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSONStatus(rw, health, status)
+}
+
+func (s *Server) health() palette.Health {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	if s.lastHealth == nil || time.Since(s.lastHealth.CheckedAt) > DefaultHealthCacheTTL {
+		health := s.palette.CheckHealth()
+		s.lastHealth = &health
+	}
+	return *s.lastHealth
+}
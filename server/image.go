@@ -0,0 +1,95 @@
+package server
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/BrianBland/palette"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	maxUploadSize = 32 << 20
+	// maxImagePixels bounds the size of uploaded images, which can declare
+	// dimensions far larger than their compressed size.
+	maxImagePixels = 25 << 20
+)
+
+func (s *Server) setPaletteFromImage(rw http.ResponseWriter, r *http.Request) {
+	err := r.ParseMultipartForm(maxUploadSize)
+	if err != nil {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Invalid request body")
+		return
+	}
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Missing image")
+		return
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Unsupported image: "+err.Error())
+		return
+	}
+	if int64(config.Width)*int64(config.Height) > maxImagePixels {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Image is too large")
+		return
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		writeError(rw, err)
+		return
+	}
+	img, format, err := image.Decode(file)
+	if err != nil {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Unsupported image: "+err.Error())
+		return
+	}
+
+	lights, err := s.lights(r)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	lights = s.selectLights(lights, formLights(r.Form))
+	if len(lights) == 0 {
+		writeErrorStatus(rw, http.StatusNotFound, codeNotFound, "No lights match")
+		return
+	}
+	states := palette.StatesFromImage(img, len(lights))
+	if len(states) == 0 {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "No colors found in image")
+		return
+	}
+	log.WithFields(log.Fields{
+		"format": format,
+		"colors": len(states),
+	}).Debug("Setting palette from image")
+
+	s.manualChange(lights)
+	errChan := s.palette.SetGroupAs(change(r, nil), lights, states)
+	err = s.handleErrChan(rw, errChan)
+	if err == nil {
+		s.getLights(rw, r)
+	}
+}
+
+// formLights reads light patterns from repeated or comma-separated "lights"
+// form values.
+func formLights(values url.Values) []string {
+	var patterns []string
+	for _, value := range values["lights"] {
+		for _, pattern := range strings.Split(value, ",") {
+			if pattern = strings.TrimSpace(pattern); pattern != "" {
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/BrianBland/palette"
+	"github.com/BrianBland/palette/swatch"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+type lightRequest struct {
+	On         *bool   `json:"on"`
+	Brightness *uint8  `json:"brightness"`
+	Color      string  `json:"color"`
+	Hue        *uint16 `json:"hue"`
+	Saturation *uint8  `json:"saturation"`
+	ColorTemp  *uint16 `json:"ct"`
+	Alert      string  `json:"alert"`
+	Effect     string  `json:"effect"`
+}
+
+// state converts the request to a light state. Colors may be given as hex,
+// "#rrggbb", or by name as for palettes.
+func (r lightRequest) state() (hue.LightState, error) {
+	state := hue.LightState{
+		On:         r.On,
+		Brightness: r.Brightness,
+		Hue:        r.Hue,
+		Saturation: r.Saturation,
+		ColorTemp:  r.ColorTemp,
+		Alert:      r.Alert,
+		Effect:     r.Effect,
+	}
+	if r.Color == "" {
+		return state, nil
+	}
+	if strings.HasPrefix(r.Color, "#") {
+		c, err := swatch.ParseHex(r.Color)
+		if err != nil {
+			return state, err
+		}
+		fromColor := palette.StateFromColor(c)
+		state.Hue, state.Saturation = fromColor.Hue, fromColor.Saturation
+		if state.Brightness == nil {
+			state.Brightness = fromColor.Brightness
+		}
+		return state, nil
+	}
+	named := request{Color: r.Color}.hue()
+	if named == nil {
+		return state, errInvalidParameter("color")
+	}
+	state.Hue = named
+	if state.Saturation == nil {
+		state.Saturation = uint8Ptr(2<<7 - 1)
+	}
+	return state, nil
+}
+
+func (s *Server) findLight(rw http.ResponseWriter, r *http.Request) (hue.Light, bool) {
+	id := mux.Vars(r)["id"]
+	lights, err := s.lights(r)
+	if err != nil {
+		writeError(rw, err)
+		return hue.Light{}, false
+	}
+	for _, light := range lights {
+		if light.Id == id {
+			return light, true
+		}
+	}
+	writeErrorStatus(rw, http.StatusNotFound, codeNotFound, "Unknown light")
+	return hue.Light{}, false
+}
+
+func (s *Server) setLight(rw http.ResponseWriter, r *http.Request) {
+	var req lightRequest
+	err := decodeBody(r, "LightRequest", &req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	state, err := req.state()
+	if err != nil {
+		writeError(rw, errInvalidParameter("color"))
+		return
+	}
+	light, ok := s.findLight(rw, r)
+	if !ok {
+		return
+	}
+	log.WithFields(log.Fields{
+		"light": light.Id,
+		"state": state,
+	}).Debug("Setting light")
+
+	lights := []hue.Light{light}
+	s.manualChange(lights)
+	errChan := s.palette.SetGroupAs(change(r, req), lights, []hue.LightState{state})
+	err = s.handleErrChan(rw, errChan)
+	if err == nil {
+		s.getLights(rw, r)
+	}
+}
+
+func (s *Server) renameLight(rw http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	err := decodeBody(r, "NameRequest", &req)
+	if err == nil && strings.TrimSpace(req.Name) == "" {
+		err = errInvalidParameter("name")
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	light, ok := s.findLight(rw, r)
+	if !ok {
+		return
+	}
+	log.WithFields(log.Fields{
+		"light": light.Id,
+		"name":  req.Name,
+	}).Debug("Renaming light")
+	err = s.palette.SetLightName(light.Id, strings.TrimSpace(req.Name))
+	result := palette.AuditResult{Id: light.Id, Name: light.Name}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if auditErr := s.palette.RecordChange(change(r, req), []palette.AuditResult{result}); auditErr != nil {
+		log.WithField("error", auditErr).Error("Failed to write audit record")
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	s.monitor.Refresh()
+	s.getLights(rw, r)
+}
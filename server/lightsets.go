@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/BrianBland/palette"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) getLightSets(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, struct {
+		LightSets []palette.LightSet `json:"lightSets"`
+	}{
+		LightSets: s.palette.LightSets(),
+	})
+}
+
+// saveLightSet names a list of light patterns, so requests can use it as
+// "@name".
+func (s *Server) saveLightSet(rw http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Lights []string `json:"lights"`
+	}
+	if err := decodeBody(r, "LightSetRequest", &req); err != nil {
+		writeError(rw, err)
+		return
+	}
+	set := palette.LightSet{Name: mux.Vars(r)["name"], Lights: req.Lights}
+	log.WithFields(log.Fields{
+		"set":    set.Name,
+		"lights": set.Lights,
+	}).Debug("Saving light set")
+	if err := s.palette.SaveLightSet(set); err != nil {
+		writeError(rw, err)
+		return
+	}
+	writeJSON(rw, set)
+}
+
+func (s *Server) deleteLightSet(rw http.ResponseWriter, r *http.Request) {
+	err := s.palette.DeleteLightSet(mux.Vars(r)["name"])
+	if err == palette.ErrUnknownLightSet {
+		writeErrorStatus(rw, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	s.getLightSets(rw, r)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BrianBland/palette/metrics"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultSampleInterval is how often light states are sampled for metrics,
+// once /metrics has been scraped.
+const DefaultSampleInterval = 15 * time.Second
+
+var (
+	httpRequests = metrics.Default.NewCounter("palette_http_requests_total",
+		"HTTP requests handled, by route.", "route", "method", "code")
+	httpDuration = metrics.Default.NewHistogram("palette_http_request_duration_seconds",
+		"Time taken to handle HTTP requests, by route.", metrics.DefaultBuckets, "route", "method")
+	lightReachable = metrics.Default.NewGauge("palette_light_reachable",
+		"Whether the bridge can reach the light, as of the last sample.", "id", "name")
+	lightOn = metrics.Default.NewGauge("palette_light_on",
+		"Whether the light is on, as of the last sample.", "id", "name")
+	lightBrightness = metrics.Default.NewGauge("palette_light_brightness",
+		"The light's brightness from 0 to 254, as of the last sample.", "id", "name")
+	lightSampleTime = metrics.Default.NewGauge("palette_light_sample_timestamp_seconds",
+		"When light states were last sampled successfully.")
+)
+
+// statusRecorder remembers the status written through it, while still
+// supporting the streaming the events endpoint needs.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) CloseNotify() <-chan bool {
+	if notifier, ok := r.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return nil
+}
+
+// instrument records the count and latency of requests to route.
+func instrument(route string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: rw}
+		handler.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		httpRequests.Inc(route, r.Method, strconv.Itoa(recorder.status))
+		httpDuration.Observe(time.Since(start).Seconds(), route, r.Method)
+	})
+}
+
+func (s *Server) getMetrics(rw http.ResponseWriter, r *http.Request) {
+	s.sampling.Do(func() {
+		go s.sampleLights(DefaultSampleInterval)
+	})
+	rw.Header().Set("Content-Type", metrics.ContentType)
+	metrics.Default.WriteTo(rw)
+}
+
+// sampleLights keeps the per-light gauges up to date.
+func (s *Server) sampleLights(interval time.Duration) {
+	for {
+		s.sampleLightsOnce()
+		time.Sleep(interval)
+	}
+}
+
+func (s *Server) sampleLightsOnce() {
+	lights, err := s.palette.GetLights()
+	if err != nil {
+		log.WithField("error", err).Warn("Failed to sample lights for metrics")
+		return
+	}
+	statuses, err := s.palette.GetStatus(lights)
+	if err != nil {
+		log.WithField("error", err).Warn("Failed to sample some lights for metrics")
+	}
+	lightReachable.Reset()
+	lightOn.Reset()
+	lightBrightness.Reset()
+	for _, status := range statuses {
+		lightReachable.Set(boolValue(status.Reachable), status.Id, status.Name)
+		lightOn.Set(boolValue(status.On != nil && *status.On), status.Id, status.Name)
+		if status.Brightness != nil {
+			lightBrightness.Set(float64(*status.Brightness), status.Id, status.Name)
+		}
+	}
+	lightSampleTime.Set(float64(time.Now().Unix()))
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
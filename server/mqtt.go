@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/BrianBland/palette/mqtt"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	mqttOnline     = "online"
+	mqttOffline    = "offline"
+	mqttMinBackoff = time.Second
+	mqttMaxBackoff = time.Minute
+	// mqttQueueSize is how many commands may wait to be handled before more
+	// are dropped.
+	mqttQueueSize = 64
+)
+
+// ServeMQTT connects to an MQTT broker, publishing each light's state,
+// retained, to {prefix}/lights/{id}/state and accepting the same JSON as the
+// HTTP API on {prefix}/lights/{id}/set, {prefix}/scheme/set and
+// {prefix}/scene/recall. {prefix}/status is "online" while connected and, by
+// way of the last will, "offline" otherwise. It reconnects whenever the
+// connection is lost, and never returns.
+func (s *Server) ServeMQTT(opts mqtt.Options, prefix string) {
+	status := prefix + "/status"
+	opts.Will = &mqtt.Will{Topic: status, Payload: []byte(mqttOffline), Retain: true}
+	handler := s.localHandler("mqtt")
+	// Commands are handled apart from the connection, so slow or rate
+	// limited bridge calls never hold up publishing or reading from the
+	// broker, which would miss its keepalive.
+	commands := make(chan mqtt.Message, mqttQueueSize)
+	go func() {
+		for msg := range commands {
+			s.mqttCommand(handler, prefix, msg)
+		}
+	}()
+	backoff := mqttMinBackoff
+	for {
+		logger := log.WithField("broker", opts.Broker)
+		client, err := mqtt.Dial(opts)
+		if err == nil {
+			err = client.Subscribe(prefix+"/lights/+/set", prefix+"/scheme/set", prefix+"/scene/recall")
+			if err == nil {
+				err = client.Publish(status, []byte(mqttOnline), true)
+			}
+			if err != nil {
+				client.Close()
+			}
+		}
+		if err != nil {
+			logger.WithField("error", err).Warn("Failed to connect to MQTT broker")
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > mqttMaxBackoff {
+				backoff = mqttMaxBackoff
+			}
+			continue
+		}
+		logger.Info("Connected to MQTT broker")
+		backoff = mqttMinBackoff
+		s.serveMQTT(client, commands, prefix)
+		logger.WithField("error", client.Err()).Warn("Lost connection to MQTT broker")
+	}
+}
+
+// serveMQTT publishes light changes and queues commands until the connection
+// ends.
+func (s *Server) serveMQTT(client *mqtt.Client, commands chan<- mqtt.Message, prefix string) {
+	updates, cancel := s.monitor.Subscribe()
+	defer cancel()
+
+	published := make(map[string]string)
+	for {
+		select {
+		case <-client.Done():
+			return
+		case statuses := <-updates:
+			for _, status := range statuses {
+				b, err := json.Marshal(status)
+				if err != nil || published[status.Id] == string(b) {
+					continue
+				}
+				if err := client.Publish(prefix+"/lights/"+status.Id+"/state", b, true); err != nil {
+					return
+				}
+				published[status.Id] = string(b)
+			}
+		case msg, ok := <-client.Messages():
+			if !ok {
+				return
+			}
+			// Commands left retained on the broker would be replayed on
+			// every reconnect.
+			if msg.Retain {
+				log.WithField("topic", msg.Topic).Warn("Ignoring retained MQTT command")
+				continue
+			}
+			select {
+			case commands <- msg:
+			default:
+				log.WithField("topic", msg.Topic).Warn("Dropping MQTT command, too many are waiting")
+			}
+		}
+	}
+}
+
+// mqttCommand makes the API request a command message stands for.
+func (s *Server) mqttCommand(handler http.Handler, prefix string, msg mqtt.Message) {
+	logger := log.WithField("topic", msg.Topic)
+	topic := strings.TrimPrefix(msg.Topic, prefix+"/")
+	body := msg.Payload
+	var path string
+	switch {
+	case mqtt.Match("lights/+/set", topic):
+		path = "/lights/" + mqttPathEscape(strings.Split(topic, "/")[1])
+	case topic == "scheme/set":
+		path = "/palette"
+	case topic == "scene/recall":
+		// The scene is named in the body, as {"name": "evening"} or just
+		// evening.
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			req.Name = strings.TrimSpace(string(body))
+		}
+		if req.Name == "" {
+			logger.Warn("MQTT scene recall without a scene name")
+			return
+		}
+		path = "/scenes/" + mqttPathEscape(req.Name) + "/recall"
+		body = nil
+	default:
+		return
+	}
+
+	r, err := http.NewRequest("PUT", "http://palette"+path, bytes.NewReader(body))
+	if err != nil {
+		logger.WithField("error", err).Warn("Bad MQTT command")
+		return
+	}
+	r.Header.Set("Content-Type", "application/json")
+	rec := newRecorder()
+	handler.ServeHTTP(rec, r)
+	if rec.code >= http.StatusBadRequest {
+		var resp errorResponse
+		json.Unmarshal(rec.body.Bytes(), &resp)
+		logger.WithFields(log.Fields{
+			"status": rec.code,
+			"error":  resp.Error.Message,
+		}).Warn("MQTT command failed")
+		return
+	}
+	s.monitor.Refresh()
+}
+
+func mqttPathEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
@@ -0,0 +1,723 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BrianBland/palette"
+	"github.com/BrianBland/palette/swatch"
+)
+
+type schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	Items       *schema            `json:"items,omitempty"`
+	Properties  map[string]*schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+
+	// pattern is Pattern compiled, once, at startup.
+	pattern *regexp.Regexp
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type operation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary"`
+	Description string                 `json:"description,omitempty"`
+	Security    *[]securityRequirement `json:"security,omitempty"`
+	Parameters  []parameter            `json:"parameters,omitempty"`
+	RequestBody *requestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]response    `json:"responses"`
+}
+
+type securityRequirement map[string][]string
+
+type securityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+type openAPIDocument struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Security   []securityRequirement           `json:"security"`
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components struct {
+		Schemas         map[string]*schema        `json:"schemas"`
+		SecuritySchemes map[string]securityScheme `json:"securitySchemes"`
+	} `json:"components"`
+}
+
+func ref(name string) *schema {
+	return &schema{Ref: "#/components/schemas/" + name}
+}
+
+func resolve(s *schema) *schema {
+	for s.Ref != "" {
+		s = schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+func between(min, max float64) (*float64, *float64) {
+	return &min, &max
+}
+
+func integer(description string, min, max float64) *schema {
+	s := &schema{Type: "integer", Description: description}
+	s.Minimum, s.Maximum = between(min, max)
+	return s
+}
+
+func atLeast(description string, min float64) *schema {
+	return &schema{Type: "number", Description: description, Minimum: &min}
+}
+
+func str(description string) *schema {
+	return &schema{Type: "string", Description: description}
+}
+
+func enum(description string, values ...string) *schema {
+	return &schema{Type: "string", Description: description, Enum: values}
+}
+
+func arrayOf(items *schema) *schema {
+	return &schema{Type: "array", Items: items}
+}
+
+func object(properties map[string]*schema, required ...string) *schema {
+	return &schema{Type: "object", Properties: properties, Required: required}
+}
+
+func schemeNames() []string {
+	names := append([]string{}, palette.SchemeNames...)
+	var aliases []string
+	for alias := range palette.SchemeAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return append(names, aliases...)
+}
+
+func colorNames() []string {
+	var names []string
+	for name := range namedHues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	timeOfDayPattern = `^[0-9]{1,2}:[0-9]{2}$`
+	durationPattern  = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+	lightIdDescription = "Light ID, qualified as <bridge>:<light> when palette controls several bridges"
+
+	brightness = integer("Brightness, from 0 (dimmest, but still on) to 254", 0, 254)
+	saturation = integer("Saturation, from 0 (white) to 254", 0, 254)
+	hueValue   = integer("Hue around the color wheel, from 0 (red) to 65535", 0, 65535)
+	colorTemp  = integer("Color temperature in mireds", palette.MinColorTemp, palette.MaxColorTemp)
+	alert      = enum("Alert effect", "none", "select", "lselect")
+	effect     = enum("Dynamic effect", "none", "colorloop")
+	lightsList = &schema{
+		Type:        "array",
+		Description: "Light IDs or names, which may be glob patterns, or light sets as @name. Omit for every light.",
+		Items:       str(""),
+	}
+	deficiency = enum("Color vision deficiency", palette.Deficiencies...)
+	cvdSafe    = enum("Adjust colors to stay distinguishable under a color vision deficiency",
+		append([]string{"all"}, palette.Deficiencies...)...)
+	minContrast = atLeast("Minimum CIE76 distance kept between colors when cvdSafe is set", 0)
+	duration    = &schema{Type: "string", Description: "Duration such as 90s or 1h30m", Pattern: durationPattern}
+	namedColor  = enum("Primary color by name, used when hue is not given", colorNames()...)
+)
+
+var schemas = map[string]*schema{
+	"Error": object(map[string]*schema{
+		"error": object(map[string]*schema{
+			"code":    str("Machine readable error code"),
+			"message": str(""),
+			"details": arrayOf(object(map[string]*schema{
+				"light":       str("The light the error concerns"),
+				"field":       str("The request field the error concerns"),
+				"type":        &schema{Type: "integer", Description: "Hue bridge error type"},
+				"address":     str("Hue bridge resource address"),
+				"bridge":      str("Unique ID of the bridge that failed"),
+				"description": str(""),
+			})),
+		}, "code", "message"),
+	}, "error"),
+	"LightStatus": object(map[string]*schema{
+		"id":        str(lightIdDescription),
+		"name":      str(""),
+		"on":        &schema{Type: "boolean"},
+		"bri":       brightness,
+		"hue":       hueValue,
+		"sat":       saturation,
+		"ct":        colorTemp,
+		"xy":        arrayOf(&schema{Type: "number"}),
+		"alert":     alert,
+		"effect":    effect,
+		"colormode": enum("", "hs", "xy", "ct"),
+		"reachable": &schema{Type: "boolean"},
+		"color":     str("Current color as #rrggbb"),
+	}),
+	"Health": object(map[string]*schema{
+		"status":    enum("", palette.HealthReady, palette.HealthDegraded, palette.HealthUnavailable),
+		"checkedAt": str("RFC 3339 time of the check"),
+		"bridges": arrayOf(object(map[string]*schema{
+			"id":              str("Bridge unique ID"),
+			"status":          enum("", palette.HealthReady, palette.HealthUnavailable),
+			"softwareVersion": str("Bridge software version"),
+			"checks": arrayOf(object(map[string]*schema{
+				"name":  enum("", "bridge", "username", "configuration"),
+				"ok":    &schema{Type: "boolean"},
+				"error": str(""),
+			})),
+		})),
+		"lights":            &schema{Type: "integer"},
+		"unreachableLights": arrayOf(str("Light ID")),
+	}),
+	"Lights": object(map[string]*schema{
+		"lights": arrayOf(ref("LightStatus")),
+	}),
+	"LightRequest": object(map[string]*schema{
+		"on":         &schema{Type: "boolean"},
+		"brightness": brightness,
+		"color": &schema{
+			Type:        "string",
+			Description: "Color as #rrggbb or by name",
+			Pattern:     `(?i)^(#[0-9a-f]{6}|` + strings.Join(colorNames(), "|") + `)$`,
+		},
+		"hue":        hueValue,
+		"saturation": saturation,
+		"ct":         colorTemp,
+		"alert":      alert,
+		"effect":     effect,
+	}),
+	"NameRequest": object(map[string]*schema{
+		"name": &schema{Type: "string", Description: "A name that isn't blank", Pattern: `\S`},
+	}, "name"),
+	"PaletteRequest": object(map[string]*schema{
+		"palette":     enum("Color scheme; generated at random when omitted", schemeNames()...),
+		"brightness":  brightness,
+		"color":       namedColor,
+		"hue":         hueValue,
+		"saturation":  saturation,
+		"alert":       alert,
+		"effect":      effect,
+		"lights":      lightsList,
+		"seed":        str("Derive any unset palette, hue, saturation and brightness from this string"),
+		"cvdSafe":     cvdSafe,
+		"minContrast": minContrast,
+	}),
+	"ShuffleRequest": object(map[string]*schema{
+		"minSaturation": saturation,
+		"maxSaturation": saturation,
+		"minBrightness": brightness,
+		"maxBrightness": brightness,
+		"avoidHues": arrayOf(object(map[string]*schema{
+			"from": &schema{Type: "number", Description: "Degrees"},
+			"to":   &schema{Type: "number", Description: "Degrees"},
+		})),
+		"minDistance": atLeast("Minimum CIE76 distance between colors", 0),
+		"schemes":     arrayOf(enum("", schemeNames()...)),
+		"lights":      lightsList,
+		"count":       integer("Number of candidates", 0, maxCandidates),
+		"apply":       &schema{Type: "boolean", Description: "Set the lights to the candidate for seed"},
+		"seed":        str(""),
+	}),
+	"CircadianRequest": object(map[string]*schema{
+		"curve": arrayOf(object(map[string]*schema{
+			"time": &schema{Type: "string", Description: "Time of day as 15:04", Pattern: timeOfDayPattern},
+			"ct":   colorTemp,
+			"bri":  brightness,
+		}, "time", "ct", "bri")),
+		"lights":       lightsList,
+		"interval":     duration,
+		"pauseTimeout": duration,
+	}),
+	"SunriseRequest": object(map[string]*schema{
+		"at":       &schema{Type: "string", Description: "Time of day to start, as 15:04", Pattern: timeOfDayPattern},
+		"duration": duration,
+		"lights":   lightsList,
+	}),
+	"TokenRequest": object(map[string]*schema{
+		"name":   &schema{Type: "string", Description: "A name that isn't blank", Pattern: `\S`},
+		"scope":  enum("What the token may do", palette.Scopes...),
+		"lights": lightsList,
+	}, "name", "scope"),
+	"SceneRequest": object(map[string]*schema{
+		"lights": lightsList,
+	}),
+	"PowerRequest": object(map[string]*schema{
+		"lights": lightsList,
+	}),
+	"WebhookRequest": object(map[string]*schema{
+		"url":    &schema{Type: "string", Description: "http or https URL to POST events to", Pattern: `^https?://`},
+		"events": arrayOf(enum("Events to send; every event when omitted", palette.EventTypes...)),
+		"secret": str("Key for the HMAC-SHA256 signature in " + palette.SignatureHeader +
+			"; generated for new webhooks when omitted, and kept when a webhook is replaced"),
+	}, "url"),
+	"LightSetRequest": object(map[string]*schema{
+		"lights": &schema{
+			Type:        "array",
+			Description: "Light IDs or names, which may be glob patterns",
+			Items:       str(""),
+		},
+	}, "lights"),
+}
+
+func init() {
+	for _, s := range schemas {
+		compilePatterns(s)
+	}
+	for _, operations := range openAPI().Paths {
+		for _, op := range operations {
+			for _, param := range op.Parameters {
+				compilePatterns(param.Schema)
+			}
+		}
+	}
+}
+
+// compilePatterns compiles every pattern in s, so that a bad one panics at
+// startup rather than during validation.
+func compilePatterns(s *schema) {
+	if s.Pattern != "" && s.pattern == nil {
+		s.pattern = regexp.MustCompile(s.Pattern)
+	}
+	if s.Items != nil {
+		compilePatterns(s.Items)
+	}
+	for _, property := range s.Properties {
+		compilePatterns(property)
+	}
+}
+
+func queryParameter(name string, s *schema) parameter {
+	return parameter{Name: name, In: "query", Schema: s}
+}
+
+func pathParameter(name, description string) parameter {
+	return parameter{Name: name, In: "path", Description: description, Required: true, Schema: str("")}
+}
+
+// paletteParameters describe a palette in the query string, as for
+// requestFromQuery.
+func paletteParameters(extra ...parameter) []parameter {
+	return append([]parameter{
+		queryParameter("palette", enum("", schemeNames()...)),
+		queryParameter("color", namedColor),
+		queryParameter("hue", hueValue),
+		queryParameter("saturation", saturation),
+		queryParameter("brightness", brightness),
+		queryParameter("seed", str("")),
+		queryParameter("cvdSafe", cvdSafe),
+		queryParameter("minContrast", minContrast),
+		queryParameter("lights", integer("Number of lights to describe", 1, maxPreviewLights)),
+	}, extra...)
+}
+
+var (
+	setPaletteParameters = []parameter{
+		queryParameter("dryRun", &schema{Type: "boolean", Description: "Return the planned states without changing any light"}),
+	}
+	simulateParameters = paletteParameters(
+		queryParameter("deficiency", deficiency),
+	)
+	previewParameters = paletteParameters(
+		queryParameter("names", &schema{Type: "boolean", Description: "Use and label the bridge's lights"}),
+		queryParameter("format", enum("", "svg", "png")),
+	)
+	auditTime       = str("RFC 3339 time, or a duration before now such as 12h")
+	auditParameters = []parameter{
+		queryParameter("since", auditTime),
+		queryParameter("until", auditTime),
+		queryParameter("lights", str("Comma separated light IDs or names; records touching any of them match")),
+		queryParameter("limit", integer("Most records to return", 1, maxAuditLimit)),
+	}
+	deliveriesParameters = []parameter{
+		queryParameter("limit", integer("Most deliveries to return", 1, maxDeliveryLimit)),
+	}
+	formatParameter = queryParameter("format", enum("Palette file format", swatch.Formats...))
+	lightsParameter = queryParameter("lights", str("Comma separated light IDs or names"))
+)
+
+func jsonContent(s *schema) map[string]mediaType {
+	return map[string]mediaType{"application/json": {Schema: s}}
+}
+
+func jsonBody(name string, required bool) *requestBody {
+	return &requestBody{Required: required, Content: jsonContent(ref(name))}
+}
+
+func responses(description string, s *schema) map[string]response {
+	ok := response{Description: description}
+	if s != nil {
+		ok.Content = jsonContent(s)
+	}
+	return map[string]response{
+		"200":     ok,
+		"default": {Description: "Error", Content: jsonContent(ref("Error"))},
+	}
+}
+
+var lightsResponse = responses("The state of every light", ref("Lights"))
+
+// add documents op at pathName for each of methods. Operations requiring a
+// token name the scope they need; an empty scope marks a public route.
+func (d *openAPIDocument) add(pathName, scope string, op operation, methods ...string) {
+	if scope == "" {
+		op.Security = &[]securityRequirement{}
+	} else {
+		op.Description = "Requires a token with the " + scope + " scope, once any token exists. " +
+			"Clients over the server's rate limit get a 429 rate_limited error."
+	}
+	if d.Paths[pathName] == nil {
+		d.Paths[pathName] = make(map[string]operation)
+	}
+	id := op.OperationID
+	for i, method := range methods {
+		if i > 0 {
+			op.OperationID = id + strings.Title(method)
+		}
+		d.Paths[pathName][method] = op
+	}
+}
+
+// openAPI describes every route of Server.Handler.
+func openAPI() *openAPIDocument {
+	d := &openAPIDocument{OpenAPI: "3.0.3", Paths: make(map[string]map[string]operation)}
+	d.Info.Title = "palette"
+	d.Info.Version = "1.0.0"
+	d.Components.Schemas = schemas
+	d.Components.SecuritySchemes = map[string]securityScheme{
+		"token": {Type: "http", Scheme: "bearer"},
+	}
+	d.Security = []securityRequirement{{"token": {}}}
+
+	id := pathParameter("id", lightIdDescription)
+	name := pathParameter("name", "Scene name")
+	setName := pathParameter("name", "Light set name")
+	webhookName := pathParameter("name", "Webhook name")
+	anyObject := &schema{Type: "object"}
+
+	d.add("/lights", palette.ScopeRead, operation{
+		OperationID: "getLights",
+		Summary:     "List lights",
+		Responses:   lightsResponse,
+	}, "get")
+	d.add("/lights/{id}", palette.ScopeControl, operation{
+		OperationID: "setLight",
+		Summary:     "Set one light",
+		Parameters:  []parameter{id},
+		RequestBody: jsonBody("LightRequest", true),
+		Responses:   lightsResponse,
+	}, "put", "post")
+	d.add("/lights/{id}/name", palette.ScopeControl, operation{
+		OperationID: "renameLight",
+		Summary:     "Rename a light",
+		Parameters:  []parameter{id},
+		RequestBody: jsonBody("NameRequest", true),
+		Responses:   lightsResponse,
+	}, "put", "post")
+	d.add("/palette", palette.ScopeControl, operation{
+		OperationID: "setPalette",
+		Summary:     "Set lights to a color scheme",
+		Parameters:  setPaletteParameters,
+		RequestBody: jsonBody("PaletteRequest", true),
+		Responses:   lightsResponse,
+	}, "put", "post")
+	d.add("/palette/from-image", palette.ScopeControl, operation{
+		OperationID: "setPaletteFromImage",
+		Summary:     "Set lights to colors extracted from an image",
+		RequestBody: &requestBody{Required: true, Content: map[string]mediaType{
+			"multipart/form-data": {Schema: object(map[string]*schema{
+				"image":  &schema{Type: "string", Description: "GIF, JPEG or PNG image"},
+				"lights": str("Comma separated light IDs or names"),
+			}, "image")},
+		}},
+		Responses: responses("The extracted swatches", anyObject),
+	}, "post")
+	d.add("/palette/import", palette.ScopeControl, operation{
+		OperationID: "importPalette",
+		Summary:     "Set lights from a palette file",
+		Parameters:  []parameter{formatParameter, lightsParameter},
+		RequestBody: &requestBody{Required: true, Content: map[string]mediaType{
+			"application/octet-stream": {Schema: &schema{Type: "string"}},
+			"multipart/form-data": {Schema: object(map[string]*schema{
+				"file":   &schema{Type: "string"},
+				"lights": str("Comma separated light IDs or names"),
+			}, "file")},
+		}},
+		Responses: lightsResponse,
+	}, "post")
+	d.add("/palette/shuffle", palette.ScopeControl, operation{
+		OperationID: "shufflePalette",
+		Summary:     "Generate candidate palettes, or apply one",
+		RequestBody: jsonBody("ShuffleRequest", true),
+		Responses:   responses("Candidate palettes", anyObject),
+	}, "post")
+	d.add("/palette/simulate", palette.ScopeRead, operation{
+		OperationID: "simulatePalette",
+		Summary:     "Show a palette under color vision deficiencies",
+		Parameters:  simulateParameters,
+		Responses:   responses("Simulated colors and contrast", anyObject),
+	}, "get")
+	d.add("/palette/preview", palette.ScopeRead, operation{
+		OperationID: "previewPalette",
+		Summary:     "Render a palette's swatches",
+		Parameters:  previewParameters,
+		Responses: map[string]response{
+			"200": {Description: "SVG or PNG image", Content: map[string]mediaType{
+				"image/svg+xml": {Schema: &schema{Type: "string"}},
+				"image/png":     {Schema: &schema{Type: "string"}},
+			}},
+			"default": {Description: "Error", Content: jsonContent(ref("Error"))},
+		},
+	}, "get")
+	d.add("/palette/export", palette.ScopeRead, operation{
+		OperationID: "exportPalette",
+		Summary:     "Download the lights' colors as a palette file",
+		Parameters:  []parameter{formatParameter, lightsParameter},
+		Responses:   responses("Palette file", nil),
+	}, "get")
+	d.add("/on", palette.ScopeControl, operation{
+		OperationID: "lightsOn",
+		Summary:     "Turn the lights on",
+		RequestBody: jsonBody("PowerRequest", false),
+		Responses:   lightsResponse,
+	}, "put", "post")
+	d.add("/off", palette.ScopeControl, operation{
+		OperationID: "lightsOut",
+		Summary:     "Turn the lights off",
+		RequestBody: jsonBody("PowerRequest", false),
+		Responses:   lightsResponse,
+	}, "put", "post")
+	d.add("/circadian", palette.ScopeRead, operation{
+		OperationID: "getCircadian",
+		Summary:     "Circadian mode status",
+		Responses:   responses("Circadian status", anyObject),
+	}, "get")
+	d.add("/circadian", palette.ScopeControl, operation{
+		OperationID: "startCircadian",
+		Summary:     "Start circadian mode",
+		RequestBody: jsonBody("CircadianRequest", true),
+		Responses:   responses("Circadian status", anyObject),
+	}, "put", "post")
+	d.add("/circadian", palette.ScopeControl, operation{
+		OperationID: "stopCircadian",
+		Summary:     "Stop circadian mode",
+		Responses:   responses("Circadian status", anyObject),
+	}, "delete")
+	d.add("/sunrise", palette.ScopeRead, operation{
+		OperationID: "getSunrise",
+		Summary:     "Sunrise status",
+		Responses:   responses("Sunrise status", anyObject),
+	}, "get")
+	d.add("/sunrise", palette.ScopeControl, operation{
+		OperationID: "startSunrise",
+		Summary:     "Schedule a sunrise",
+		RequestBody: jsonBody("SunriseRequest", true),
+		Responses:   responses("Sunrise status", anyObject),
+	}, "put", "post")
+	d.add("/sunrise", palette.ScopeControl, operation{
+		OperationID: "cancelSunrise",
+		Summary:     "Cancel the sunrise",
+		Responses:   responses("Sunrise status", anyObject),
+	}, "delete")
+	d.add("/scenes", palette.ScopeRead, operation{
+		OperationID: "getScenes",
+		Summary:     "List scenes",
+		Responses:   responses("Saved scenes", anyObject),
+	}, "get")
+	d.add("/scenes/{name}", palette.ScopeControl, operation{
+		OperationID: "saveScene",
+		Summary:     "Save the current state of lights as a scene",
+		Parameters:  []parameter{name},
+		RequestBody: jsonBody("SceneRequest", false),
+		Responses:   responses("The saved scene", anyObject),
+	}, "put", "post")
+	d.add("/scenes/{name}", palette.ScopeControl, operation{
+		OperationID: "deleteScene",
+		Summary:     "Delete a scene",
+		Parameters:  []parameter{name},
+		Responses:   responses("Remaining scenes", anyObject),
+	}, "delete")
+	d.add("/scenes/{name}/recall", palette.ScopeControl, operation{
+		OperationID: "recallScene",
+		Summary:     "Recall a scene",
+		Parameters:  []parameter{name},
+		Responses:   lightsResponse,
+	}, "put", "post")
+	d.add("/lightsets", palette.ScopeRead, operation{
+		OperationID: "getLightSets",
+		Summary:     "List light sets",
+		Responses:   responses("Saved light sets", anyObject),
+	}, "get")
+	d.add("/lightsets/{name}", palette.ScopeControl, operation{
+		OperationID: "saveLightSet",
+		Summary:     "Name a list of light patterns, for use as @name",
+		Parameters:  []parameter{setName},
+		RequestBody: jsonBody("LightSetRequest", true),
+		Responses:   responses("The saved light set", anyObject),
+	}, "put", "post")
+	d.add("/lightsets/{name}", palette.ScopeControl, operation{
+		OperationID: "deleteLightSet",
+		Summary:     "Delete a light set",
+		Parameters:  []parameter{setName},
+		Responses:   responses("Remaining light sets", anyObject),
+	}, "delete")
+	d.add("/events", palette.ScopeRead, operation{
+		OperationID: "events",
+		Summary:     "Stream light changes as server-sent events",
+		Responses: map[string]response{
+			"200": {Description: "lights events carrying the Lights schema", Content: map[string]mediaType{
+				"text/event-stream": {Schema: &schema{Type: "string"}},
+			}},
+		},
+	}, "get")
+	d.add("/audit", palette.ScopeAdmin, operation{
+		OperationID: "getAudit",
+		Summary:     "Changes made to lights, newest first",
+		Parameters:  auditParameters,
+		Responses:   responses("Audit records", anyObject),
+	}, "get")
+	d.add("/tokens", palette.ScopeAdmin, operation{
+		OperationID: "getTokens",
+		Summary:     "List API tokens",
+		Responses:   responses("Tokens, without their secrets", anyObject),
+	}, "get")
+	d.add("/tokens", palette.ScopeAdmin, operation{
+		OperationID: "createToken",
+		Summary:     "Create an API token",
+		RequestBody: jsonBody("TokenRequest", true),
+		Responses:   responses("The token and its secret, which is only shown once", anyObject),
+	}, "post")
+	d.add("/tokens/{name}", palette.ScopeAdmin, operation{
+		OperationID: "revokeToken",
+		Summary:     "Revoke an API token",
+		Parameters:  []parameter{pathParameter("name", "Token name")},
+		Responses:   responses("Remaining tokens", anyObject),
+	}, "delete")
+	d.add("/webhooks", palette.ScopeAdmin, operation{
+		OperationID: "getWebhooks",
+		Summary:     "List webhooks",
+		Responses:   responses("Webhooks, without their secrets", anyObject),
+	}, "get")
+	d.add("/webhooks/{name}", palette.ScopeAdmin, operation{
+		OperationID: "saveWebhook",
+		Summary:     "Add or replace a webhook that events are POSTed to",
+		Parameters:  []parameter{webhookName},
+		RequestBody: jsonBody("WebhookRequest", true),
+		Responses:   responses("The webhook and its secret", anyObject),
+	}, "put", "post")
+	d.add("/webhooks/{name}", palette.ScopeAdmin, operation{
+		OperationID: "deleteWebhook",
+		Summary:     "Delete a webhook",
+		Parameters:  []parameter{webhookName},
+		Responses:   responses("Remaining webhooks", anyObject),
+	}, "delete")
+	d.add("/webhooks/{name}/deliveries", palette.ScopeAdmin, operation{
+		OperationID: "getDeliveries",
+		Summary:     "Recent attempts to deliver events to a webhook, newest first",
+		Parameters:  append([]parameter{webhookName}, deliveriesParameters...),
+		Responses:   responses("Deliveries", anyObject),
+	}, "get")
+	d.add("/webhooks/{name}/test", palette.ScopeAdmin, operation{
+		OperationID: "testWebhook",
+		Summary:     "Send a ping event to a webhook once",
+		Parameters:  []parameter{webhookName},
+		Responses:   responses("How the delivery went", anyObject),
+	}, "post")
+	d.add("/pairing", palette.ScopeAdmin, operation{
+		OperationID: "getPairing",
+		Summary:     "Bridges found that palette hasn't paired with, and how pairing is going",
+		Responses:   responses("Pairing status of each bridge", anyObject),
+	}, "get")
+	d.add("/pairing/{id}", palette.ScopeAdmin, operation{
+		OperationID: "startPairing",
+		Summary:     "Wait for a bridge's link button to be pressed, then pair with it",
+		Parameters:  []parameter{pathParameter("id", "Bridge unique ID")},
+		Responses: map[string]response{
+			"202":     {Description: "Pairing started", Content: jsonContent(anyObject)},
+			"default": {Description: "Error", Content: jsonContent(ref("Error"))},
+		},
+	}, "post")
+	d.add("/setup", "", operation{
+		OperationID: "setupPage",
+		Summary:     "The page for pairing with bridges",
+		Responses:   map[string]response{"200": {Description: "HTML page"}},
+	}, "get")
+	d.add("/healthz", "", operation{
+		OperationID: "healthz",
+		Summary:     "Liveness",
+		Responses:   map[string]response{"200": {Description: "The process is running", Content: jsonContent(anyObject)}},
+	}, "get")
+	d.add("/readyz", "", operation{
+		OperationID: "readyz",
+		Summary:     "Readiness, checking the bridge",
+		Description: "Results are cached for a few seconds. Degraded means some lights are unreachable.",
+		Responses: map[string]response{
+			"200": {Description: "Ready or degraded", Content: jsonContent(ref("Health"))},
+			"503": {Description: "Unavailable", Content: jsonContent(ref("Health"))},
+		},
+	}, "get")
+	d.add("/metrics", palette.ScopeRead, operation{
+		OperationID: "getMetrics",
+		Summary:     "Prometheus metrics",
+		Responses: map[string]response{
+			"200": {Description: "Metrics in the Prometheus text exposition format", Content: map[string]mediaType{
+				"text/plain": {Schema: &schema{Type: "string"}},
+			}},
+		},
+	}, "get")
+	d.add("/openapi.json", "", operation{
+		OperationID: "openAPI",
+		Summary:     "This document",
+		Responses:   responses("OpenAPI 3 document", anyObject),
+	}, "get")
+	d.add("/", "", operation{
+		OperationID: "webUI",
+		Summary:     "The web control panel",
+		Responses:   map[string]response{"200": {Description: "HTML page"}},
+	}, "get")
+	return d
+}
+
+func (s *Server) getOpenAPI(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, openAPI())
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/BrianBland/palette"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) getPairing(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, struct {
+		Bridges []palette.PairingStatus `json:"bridges"`
+	}{
+		Bridges: s.palette.Pairing(),
+	})
+}
+
+// startPairing waits in the background for the bridge's link button to be
+// pressed. Poll the pairing status to see when it has been.
+func (s *Server) startPairing(rw http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	err := s.palette.StartPairing(id, s.PairingWindow)
+	if err == palette.ErrUnknownBridge {
+		writeErrorStatus(rw, http.StatusNotFound, codeNotFound, "No bridge waiting to be paired with that ID")
+		return
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	log.WithFields(log.Fields{
+		"bridge": id,
+		"by":     callerName(r),
+	}).Info("Pairing started")
+	writeJSONStatus(rw, struct {
+		Bridges []palette.PairingStatus `json:"bridges"`
+	}{
+		Bridges: s.palette.Pairing(),
+	}, http.StatusAccepted)
+}
+
+// setup serves the page for pairing with bridges.
+func (s *Server) setup(rw http.ResponseWriter, r *http.Request) {
+	r.URL.Path = "/setup.html"
+	s.static().ServeHTTP(rw, r)
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/BrianBland/palette"
+
+	"github.com/BrianBland/go-hue"
+)
+
+// maxPreviewLights caps how many lights a preview describes, since each one
+// is a swatch in the rendered image.
+const maxPreviewLights = 64
+
+// requestFromQuery builds a palette request from query parameters, for
+// endpoints that only describe a palette without setting it. The query is
+// first validated against params.
+func requestFromQuery(r *http.Request, params []parameter) (request, int, error) {
+	q := r.URL.Query()
+	if err := validateQuery(q, params); err != nil {
+		return request{}, 0, err
+	}
+	req := request{
+		Palette: q.Get("palette"),
+		Color:   q.Get("color"),
+		Seed:    q.Get("seed"),
+		CVDSafe: q.Get("cvdSafe"),
+	}
+	if v := q.Get("hue"); v != "" {
+		h, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return req, 0, errInvalidParameter("hue")
+		}
+		req.Hue = uint16Ptr(uint16(h))
+	}
+	if v := q.Get("saturation"); v != "" {
+		sat, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return req, 0, errInvalidParameter("saturation")
+		}
+		req.Saturation = uint8Ptr(uint8(sat))
+	}
+	if v := q.Get("brightness"); v != "" {
+		bri, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return req, 0, errInvalidParameter("brightness")
+		}
+		req.Brightness = uint8Ptr(uint8(bri))
+	}
+	if v := q.Get("minContrast"); v != "" {
+		contrast, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return req, 0, errInvalidParameter("minContrast")
+		}
+		req.MinContrast = contrast
+	}
+	n := 0
+	if v := q.Get("lights"); v != "" {
+		count, err := strconv.Atoi(v)
+		if err != nil || count < 1 || count > maxPreviewLights {
+			return req, 0, errInvalidParameter("lights")
+		}
+		n = count
+	}
+	return req.withSeed(), n, nil
+}
+
+type simulatedColor struct {
+	Color     string            `json:"color"`
+	Simulated map[string]string `json:"simulated"`
+}
+
+// simulatePalette shows how a palette appears under each color vision
+// deficiency, after any cvdSafe adjustment.
+func (s *Server) simulatePalette(rw http.ResponseWriter, r *http.Request) {
+	req, n, err := requestFromQuery(r, simulateParameters)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	deficiencies := palette.Deficiencies
+	if d := strings.ToLower(r.URL.Query().Get("deficiency")); d != "" {
+		if err := palette.ValidDeficiency(d); err != nil {
+			writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		deficiencies = []string{d}
+	}
+	states, err := req.states(n)
+	if err != nil {
+		writeStatesError(rw, err)
+		return
+	}
+
+	colors := make([]simulatedColor, len(states))
+	for i, state := range states {
+		c := palette.ColorFromState(state)
+		colors[i] = simulatedColor{
+			Color:     palette.HexFromColor(c),
+			Simulated: make(map[string]string, len(deficiencies)),
+		}
+		for _, deficiency := range deficiencies {
+			colors[i].Simulated[deficiency] = palette.HexFromColor(palette.SimulateCVD(c, deficiency))
+		}
+	}
+	contrast := make(map[string]float64, len(deficiencies))
+	for _, deficiency := range deficiencies {
+		contrast[deficiency] = palette.MinSimulatedDistance(states, []string{deficiency})
+	}
+	writeJSON(rw, struct {
+		Colors   []simulatedColor   `json:"colors"`
+		Contrast map[string]float64 `json:"contrast"`
+	}{
+		Colors:   colors,
+		Contrast: contrast,
+	})
+}
+
+// previewPalette renders the swatches a palette would set, in the order they
+// would be assigned to lights, as SVG (the default) or PNG. With names=true
+// the bridge's lights are used and labelled.
+func (s *Server) previewPalette(rw http.ResponseWriter, r *http.Request) {
+	req, n, err := requestFromQuery(r, previewParameters)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	q := r.URL.Query()
+	names, _ := strconv.ParseBool(q.Get("names"))
+	format := q.Get("format")
+	if format == "" {
+		format = "svg"
+	}
+	if format != "svg" && format != "png" {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Invalid format, expected svg or png")
+		return
+	}
+
+	var lights []hue.Light
+	if names {
+		lights, err = s.lights(r)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+		if len(lights) == 0 {
+			writeErrorStatus(rw, http.StatusNotFound, codeNotFound, "No lights match")
+			return
+		}
+		if n > 0 && n < len(lights) {
+			lights = lights[:n]
+		}
+		n = len(lights)
+	}
+	states, err := req.states(n)
+	if err != nil {
+		writeStatesError(rw, err)
+		return
+	}
+	if n == 0 {
+		n = len(states)
+	}
+
+	// Swatches for the bridge's lights are shown as those lights would
+	// show them.
+	var gamuts map[string]palette.Gamut
+	if names {
+		gamuts = s.palette.Gamuts(lights)
+	}
+	swatches := make([]swatchView, n)
+	for i := range swatches {
+		state := states[i%len(states)]
+		if names {
+			swatches[i].Color = palette.ColorFromStateInGamut(state, gamuts[lights[i].Id])
+			swatches[i].Label = lights[i].Name
+		} else {
+			swatches[i].Color = palette.ColorFromState(state)
+		}
+	}
+
+	var buf bytes.Buffer
+	if format == "png" {
+		err = renderPNG(&buf, swatches)
+		rw.Header().Set("Content-Type", "image/png")
+	} else {
+		err = renderSVG(&buf, swatches, names)
+		rw.Header().Set("Content-Type", "image/svg+xml")
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	buf.WriteTo(rw)
+}
+
+type plannedState struct {
+	Id    string         `json:"id"`
+	Name  string         `json:"name"`
+	Color string         `json:"color"`
+	State hue.LightState `json:"state"`
+}
+
+// writeDryRun reports the states a palette request would set without setting
+// them, with the colors the lights would show.
+func (s *Server) writeDryRun(rw http.ResponseWriter, lights []hue.Light, states []hue.LightState) {
+	gamuts := s.palette.Gamuts(lights)
+	planned := make([]plannedState, len(lights))
+	for i, light := range lights {
+		state := states[i%len(states)]
+		planned[i] = plannedState{
+			Id:    light.Id,
+			Name:  light.Name,
+			Color: palette.HexFromColor(palette.ColorFromStateInGamut(state, gamuts[light.Id])),
+			State: state,
+		}
+	}
+	writeJSON(rw, struct {
+		DryRun bool           `json:"dryRun"`
+		Lights []plannedState `json:"lights"`
+	}{
+		DryRun: true,
+		Lights: planned,
+	})
+}
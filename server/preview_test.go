@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/BrianBland/palette"
+
+	"github.com/gorilla/context"
+)
+
+// fakeBridge answers as a paired bridge with the given lights, by ID.
+func fakeBridge(lights map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var body interface{}
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/api":
+			body = []interface{}{map[string]interface{}{"success": map[string]string{"username": "palette"}}}
+		case r.URL.Path == "/api/palette/lights":
+			all := make(map[string]interface{}, len(lights))
+			for id, name := range lights {
+				all[id] = map[string]string{"name": name}
+			}
+			body = all
+		case strings.HasPrefix(r.URL.Path, "/api/palette/lights/"):
+			name := lights[strings.TrimPrefix(r.URL.Path, "/api/palette/lights/")]
+			body = map[string]interface{}{
+				"name":    name,
+				"type":    "Extended color light",
+				"modelid": "LCT001",
+				"state":   map[string]interface{}{"on": true, "bri": 254, "xy": []float64{0.3, 0.3}, "colormode": "xy", "reachable": true},
+			}
+		default:
+			http.NotFound(rw, r)
+			return
+		}
+		json.NewEncoder(rw).Encode(body)
+	}))
+}
+
+func TestPreviewPaletteNames(t *testing.T) {
+	tests := []struct {
+		name   string
+		lights map[string]string
+		token  []string
+		status int
+	}{
+		{"named lights", map[string]string{"1": "Desk", "2": "Shelf"}, nil, http.StatusOK},
+		{"no lights", map[string]string{}, nil, http.StatusNotFound},
+		{"no lights for the token", map[string]string{"1": "Desk"}, []string{"Porch"}, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "palette")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		bridge := fakeBridge(test.lights)
+		defer bridge.Close()
+		p, err := palette.LoadFromConfig(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Connect(palette.DiscoveredBridge{Id: "001788fffe0a0b0c", Address: bridge.URL}); err != nil {
+			t.Fatal(err)
+		}
+		s := New(p)
+
+		r, err := http.NewRequest("GET", "/palette/preview?names=true", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.token != nil {
+			context.Set(r, tokenKey, palette.Token{Name: "kiosk", Scope: palette.ScopeRead, Lights: test.token})
+		}
+		rec := newRecorder()
+		s.previewPalette(rec, r)
+		context.Clear(r)
+		if rec.code != test.status {
+			t.Errorf("%s: status %d, want %d: %s", test.name, rec.code, test.status, rec.body.String())
+			continue
+		}
+		if test.status == http.StatusOK {
+			svg := rec.body.String()
+			for _, name := range test.lights {
+				if !strings.Contains(svg, name) {
+					t.Errorf("%s: preview doesn't label %s", test.name, name)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/BrianBland/palette"
+)
+
+// statusTooManyRequests isn't defined by net/http in Go 1.4.
+const statusTooManyRequests = 429
+
+// clientIdleTimeout is how long a client's rate limiter is kept after its
+// last request.
+const clientIdleTimeout = 10 * time.Minute
+
+type clientLimiter struct {
+	limiter  *palette.RateLimiter
+	lastSeen time.Time
+}
+
+// limit applies RateLimit to each client, identified by its token or, without
+// one, by its IP address.
+func (s *Server) limit(handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if s.RateLimit <= 0 {
+			handler(rw, r)
+			return
+		}
+		ok, delay := s.clientLimiter(clientKey(r)).Allow()
+		if !ok {
+			rw.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(delay.Seconds()))))
+			writeErrorStatus(rw, statusTooManyRequests, "rate_limited", "Too many requests; try again later")
+			return
+		}
+		handler(rw, r)
+	}
+}
+
+func (s *Server) clientLimiter(key string) *palette.RateLimiter {
+	s.limitersMu.Lock()
+	defer s.limitersMu.Unlock()
+	now := time.Now()
+	if s.limiters == nil {
+		s.limiters = make(map[string]*clientLimiter)
+	}
+	client, ok := s.limiters[key]
+	if !ok {
+		for k, c := range s.limiters {
+			if now.Sub(c.lastSeen) > clientIdleTimeout {
+				delete(s.limiters, k)
+			}
+		}
+		burst := s.RateBurst
+		if burst < 1 {
+			burst = int(math.Ceil(s.RateLimit))
+		}
+		client = &clientLimiter{limiter: palette.NewRateLimiter(s.RateLimit, burst)}
+		s.limiters[key] = client
+	}
+	client.lastSeen = now
+	return client.limiter
+}
+
+func clientKey(r *http.Request) string {
+	if token, ok := caller(r); ok {
+		return "token:" + token.Name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "addr:" + r.RemoteAddr
+	}
+	return "addr:" + host
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// recorder is an http.ResponseWriter that keeps the response in memory, for
+// handing API requests straight to the server in the same process.
+type recorder struct {
+	code        int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func newRecorder() *recorder {
+	return &recorder{code: http.StatusOK, header: make(http.Header)}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.code = code
+		r.wroteHeader = true
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+// Flush does nothing, since the whole response is read once it's done.
+func (r *recorder) Flush() {}
+
+// handlerTransport hands requests straight to an http.Handler.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body == nil {
+		r.Body = ioutil.NopCloser(bytes.NewReader(nil))
+	}
+	rec := newRecorder()
+	t.handler.ServeHTTP(rec, r)
+	return &http.Response{
+		StatusCode:    rec.code,
+		Status:        fmt.Sprintf("%d %s", rec.code, http.StatusText(rec.code)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.header,
+		Body:          ioutil.NopCloser(&rec.body),
+		ContentLength: int64(rec.body.Len()),
+		Request:       r,
+	}, nil
+}
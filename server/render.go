@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+const (
+	swatchSize  = 100
+	swatchGap   = 10
+	labelHeight = 24
+)
+
+type swatchView struct {
+	Color color.RGBA
+	Label string
+}
+
+func renderSVG(w io.Writer, swatches []swatchView, labels bool) error {
+	height := swatchSize
+	if labels {
+		height += labelHeight
+	}
+	width := len(swatches)*(swatchSize+swatchGap) - swatchGap
+	if width < 0 {
+		width = 0
+	}
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		width, height, width, height)
+	if err != nil {
+		return err
+	}
+	for i, s := range swatches {
+		x := i * (swatchSize + swatchGap)
+		fmt.Fprintf(w, `  <rect x="%d" y="0" width="%d" height="%d" rx="6" fill="#%02x%02x%02x" stroke="#000" stroke-width="1"/>`+"\n",
+			x, swatchSize, swatchSize, s.Color.R, s.Color.G, s.Color.B)
+		if labels {
+			fmt.Fprintf(w, `  <text x="%d" y="%d" font-family="sans-serif" font-size="13" text-anchor="middle">%s</text>`+"\n",
+				x+swatchSize/2, swatchSize+labelHeight-7, html.EscapeString(s.Label))
+		}
+	}
+	_, err = fmt.Fprintln(w, "</svg>")
+	return err
+}
+
+// renderPNG draws the swatches side by side. The standard library has no font
+// rendering, so labels are only drawn in SVG previews.
+func renderPNG(w io.Writer, swatches []swatchView) error {
+	width := len(swatches)*(swatchSize+swatchGap) - swatchGap
+	if width <= 0 {
+		width = 1
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, swatchSize))
+	for i, s := range swatches {
+		x := i * (swatchSize + swatchGap)
+		draw.Draw(img, image.Rect(x, 0, x+swatchSize, swatchSize), &image.Uniform{C: s.Color}, image.ZP, draw.Src)
+	}
+	return png.Encode(w, img)
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/BrianBland/palette"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) getScenes(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, struct {
+		Scenes []palette.Scene `json:"scenes"`
+	}{
+		Scenes: s.palette.Scenes(),
+	})
+}
+
+// saveScene captures the current state of the requested lights, or of every
+// light when the body is empty.
+func (s *Server) saveScene(rw http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	var req struct {
+		Lights []string `json:"lights"`
+	}
+	if r.ContentLength != 0 {
+		err := decodeBody(r, "SceneRequest", &req)
+		if err != nil {
+			writeError(rw, err)
+			return
+		}
+	}
+	if scene, ok := s.scene(name); ok && len(scene.Lights) > 0 {
+		if err := s.mayUseAll(r, sceneLights(scene)); err != nil {
+			writeError(rw, err)
+			return
+		}
+	}
+	lights, err := s.lights(r)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	lights = s.selectLights(lights, req.Lights)
+	log.WithFields(log.Fields{
+		"scene":  name,
+		"lights": len(lights),
+	}).Debug("Saving scene")
+	scene, err := s.palette.SaveScene(name, lights)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	writeJSON(rw, scene)
+}
+
+func (s *Server) deleteScene(rw http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if scene, ok := s.scene(name); ok && len(scene.Lights) > 0 {
+		if err := s.mayUseAll(r, sceneLights(scene)); err != nil {
+			writeError(rw, err)
+			return
+		}
+	}
+	err := s.palette.DeleteScene(name)
+	if err == palette.ErrUnknownScene {
+		writeErrorStatus(rw, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	s.getScenes(rw, r)
+}
+
+func (s *Server) recallScene(rw http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	log.WithField("scene", name).Debug("Recalling scene")
+	lights, err := s.lights(r)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	lights, errChan, err := s.palette.RecallScene(change(r, nil), name, lights)
+	if err == palette.ErrUnknownScene {
+		writeErrorStatus(rw, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	s.manualChange(lights)
+	err = s.handleErrChan(rw, errChan)
+	if err == nil {
+		s.emitChange(r, palette.EventSceneRecalled, lights, changeEvent{Scene: name})
+		s.getLights(rw, r)
+	}
+}
+
+func (s *Server) scene(name string) (palette.Scene, bool) {
+	for _, scene := range s.palette.Scenes() {
+		if scene.Name == name {
+			return scene, true
+		}
+	}
+	return palette.Scene{}, false
+}
+
+// sceneLights returns the IDs of the lights in a scene, which may be on
+// lights that no longer exist.
+func sceneLights(scene palette.Scene) []string {
+	ids := make([]string, 0, len(scene.Lights))
+	for id := range scene.Lights {
+		ids = append(ids, id)
+	}
+	return ids
+}
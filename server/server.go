@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/BrianBland/palette"
 
@@ -14,16 +17,63 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func init() {
-	log.SetLevel(log.DebugLevel)
-}
-
 type Server struct {
+	// StaticDir, if set, serves the web UI from a directory instead of the
+	// copy embedded in the binary, for working on it without rebuilding.
+	StaticDir string
+	// RateLimit limits each client to this many requests a second, in bursts
+	// of up to RateBurst. Zero disables the limit.
+	RateLimit float64
+	RateBurst int
+	// PairingWindow is how long pairing started from the setup page waits
+	// for the bridge's link button.
+	PairingWindow time.Duration
+
 	palette *palette.Palette
+	monitor *palette.Monitor
+
+	mu        sync.Mutex
+	circadian *palette.Circadian
+	sunrise   *palette.Sunrise
+
+	sampling sync.Once
+
+	healthMu   sync.Mutex
+	lastHealth *palette.Health
+
+	limitersMu sync.Mutex
+	limiters   map[string]*clientLimiter
 }
 
 func New(p *palette.Palette) *Server {
-	return &Server{palette: p}
+	return &Server{palette: p, monitor: p.NewMonitor(), PairingWindow: palette.DefaultPairingWindow}
+}
+
+// RestoreSchedules restarts the circadian mode and sunrise that were running
+// when palette last stopped.
+func (s *Server) RestoreSchedules() {
+	circadian := s.palette.RestoreCircadian()
+	sunrise := s.palette.RestoreSunrise()
+	s.mu.Lock()
+	s.circadian = circadian
+	s.sunrise = sunrise
+	s.mu.Unlock()
+	if circadian != nil {
+		log.WithField("lights", circadian.Lights).Info("Restoring circadian mode")
+		circadian.Start()
+	}
+	if sunrise != nil {
+		log.WithField("start", sunrise.Start).Info("Restoring sunrise")
+		sunrise.Run()
+	}
+}
+
+// static serves the web UI.
+func (s *Server) static() http.Handler {
+	if s.StaticDir != "" {
+		return http.FileServer(http.Dir(s.StaticDir))
+	}
+	return assetHandler{}
 }
 
 func (s *Server) ListenAndServe(addr string) error {
@@ -35,13 +85,46 @@ func (s *Server) ListenAndServe(addr string) error {
 }
 
 type request struct {
-	Palette    string  `json:"palette"`
-	Brightness *uint8  `json:"brightness"`
-	Color      string  `json:"color"`
-	Hue        *uint16 `json:"hue"`
-	Saturation *uint8  `json:"saturation"`
-	Alert      string  `json:"alert"`
-	Effect     string  `json:"effect"`
+	Palette     string   `json:"palette"`
+	Brightness  *uint8   `json:"brightness"`
+	Color       string   `json:"color"`
+	Hue         *uint16  `json:"hue"`
+	Saturation  *uint8   `json:"saturation"`
+	Alert       string   `json:"alert"`
+	Effect      string   `json:"effect"`
+	Lights      []string `json:"lights"`
+	Seed        string   `json:"seed"`
+	CVDSafe     string   `json:"cvdSafe"`
+	MinContrast float64  `json:"minContrast"`
+}
+
+// withSeed fills in any of the palette, hue, saturation and brightness not
+// given explicitly from the request's seed.
+func (r request) withSeed() request {
+	if r.Seed == "" {
+		return r
+	}
+	seeded := palette.Seed(r.Seed)
+	log.WithFields(log.Fields{
+		"seed":    r.Seed,
+		"palette": seeded.Scheme,
+		"hue":     seeded.Hue,
+	}).Debug("Seeded palette")
+	if r.Palette == "" {
+		r.Palette = seeded.Scheme
+	}
+	// A color that isn't recognized leaves the hue to the seed too, rather
+	// than to a palette generated at random.
+	if r.hue() == nil {
+		r.Hue = &seeded.Hue
+	}
+	if r.Saturation == nil {
+		r.Saturation = &seeded.Saturation
+	}
+	if r.Brightness == nil {
+		r.Brightness = &seeded.Brightness
+	}
+	return r
 }
 
 func (r request) brightness() *uint8 {
@@ -51,31 +134,29 @@ func (r request) brightness() *uint8 {
 	return r.Brightness
 }
 
+// namedHues are the colors, in degrees, that may be given by name instead of
+// hue.
+var namedHues = map[string]float64{
+	"r": 0, "red": 0,
+	"o": 30, "orange": 30,
+	"y": 60, "yellow": 60,
+	"g": 120, "green": 120,
+	"c": 180, "cyan": 180,
+	"b": 240, "blue": 240,
+	"i": 260, "indigo": 260,
+	"v": 270, "violet": 270,
+	"m": 300, "magenta": 300,
+	"p": 300, "purple": 300,
+}
+
 func (r request) hue() *uint16 {
 	if r.Hue == nil {
 		log.WithField("color", r.Color).Debug("No hue provided, using color instead")
-		switch strings.ToLower(r.Color) {
-		case "r", "red":
-			return uint16Ptr(0)
-		case "o", "orange":
-			return uint16Ptr(hueFromDegrees(30))
-		case "y", "yellow":
-			return uint16Ptr(hueFromDegrees(60))
-		case "g", "green":
-			return uint16Ptr(hueFromDegrees(120))
-		case "c", "cyan":
-			return uint16Ptr(hueFromDegrees(180))
-		case "b", "blue":
-			return uint16Ptr(hueFromDegrees(240))
-		case "i", "indigo":
-			return uint16Ptr(hueFromDegrees(260))
-		case "v", "violet":
-			return uint16Ptr(hueFromDegrees(270))
-		case "m", "magenta", "p", "purple":
-			return uint16Ptr(hueFromDegrees(300))
-		default:
-			return uint16Ptr(randomHue())
+		degrees, ok := namedHues[strings.ToLower(r.Color)]
+		if !ok {
+			return nil
 		}
+		return uint16Ptr(hueFromDegrees(degrees))
 	}
 	return r.Hue
 }
@@ -89,10 +170,96 @@ func hueFromDegrees(d float64) uint16 {
 	return h
 }
 
-func randomHue() uint16 {
-	h := (uint16)(rand.Int31n(2 << 15))
-	log.WithField("hue", h).Debug("Random hue")
-	return h
+func newRand() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// states computes the light states for a palette request. When no hue or
+// recognized color is given, a harmonious palette is generated instead.
+func (r request) states(n int) ([]hue.LightState, error) {
+	primaryHue := r.hue()
+	if primaryHue == nil {
+		constraints := palette.DefaultConstraints
+		if r.Palette != "" {
+			constraints.Schemes = []string{r.Palette}
+		}
+		// A zero range means the default range to Generate, so a requested
+		// 0 is set on the generated states instead.
+		if r.Saturation != nil && *r.Saturation > 0 {
+			constraints.MinSaturation, constraints.MaxSaturation = *r.Saturation, *r.Saturation
+		}
+		if r.Brightness != nil && *r.Brightness > 0 {
+			constraints.MinBrightness, constraints.MaxBrightness = *r.Brightness, *r.Brightness
+		}
+		generated, err := palette.Generate(newRand(), n, constraints)
+		if err != nil {
+			return nil, err
+		}
+		log.WithField("palette", generated.Scheme).Debug("No color provided, generated palette")
+		for i := range generated.States {
+			if r.Saturation != nil {
+				generated.States[i].Saturation = uint8Ptr(*r.Saturation)
+			}
+			if r.Brightness != nil {
+				generated.States[i].Brightness = uint8Ptr(*r.Brightness)
+			}
+			generated.States[i].Alert = r.Alert
+			generated.States[i].Effect = r.effect()
+		}
+		return r.cvdSafe(generated.States)
+	}
+	state := hue.LightState{
+		On:         boolPtr(true),
+		Brightness: r.brightness(),
+		Hue:        primaryHue,
+		Saturation: r.saturation(),
+		Alert:      r.Alert,
+		Effect:     r.effect(),
+	}
+	log.WithFields(log.Fields{
+		"palette":      r.Palette,
+		"primaryState": state,
+	}).Debug("Setting light state")
+	states, err := palette.SchemeStates(r.Palette, state)
+	if err != nil {
+		return nil, err
+	}
+	return r.cvdSafe(states)
+}
+
+// writeStatesError responds to a request whose states couldn't be computed.
+func writeStatesError(rw http.ResponseWriter, err error) {
+	if err == palette.ErrUnsatisfiable {
+		writeErrorStatus(rw, statusUnprocessableEntity, codeUnsatisfied, err.Error())
+		return
+	}
+	writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, err.Error())
+}
+
+func (r request) deficiencies() ([]string, error) {
+	switch strings.ToLower(r.CVDSafe) {
+	case "":
+		return nil, nil
+	case "all":
+		return palette.Deficiencies, nil
+	}
+	deficiency := strings.ToLower(r.CVDSafe)
+	return []string{deficiency}, palette.ValidDeficiency(deficiency)
+}
+
+// cvdSafe adjusts states to stay distinguishable under the requested color
+// vision deficiency, if any.
+func (r request) cvdSafe(states []hue.LightState) ([]hue.LightState, error) {
+	deficiencies, err := r.deficiencies()
+	if err != nil || deficiencies == nil {
+		return states, err
+	}
+	safe, contrast := palette.CVDSafe(states, deficiencies, r.MinContrast)
+	log.WithFields(log.Fields{
+		"deficiencies": deficiencies,
+		"contrast":     contrast,
+	}).Debug("Adjusted palette for color vision deficiency")
+	return safe, nil
 }
 
 func (r request) saturation() *uint8 {
@@ -114,134 +281,186 @@ func (r request) effect() string {
 func (s *Server) Handler() http.Handler {
 	r := mux.NewRouter()
 	r.StrictSlash(true)
-	r.Handle("/", http.FileServer(http.Dir("static")))
-	r.HandleFunc("/lights", s.getLights).Methods("GET")
-	r.HandleFunc("/palette", s.setPalette).Methods("PUT", "POST")
-	r.HandleFunc("/on", s.lightsOn).Methods("PUT", "POST")
-	r.HandleFunc("/off", s.lightsOut).Methods("PUT", "POST")
+	// handle routes path to handler for requests with a token allowing scope,
+	// within the rate limit, or for everyone when scope is empty.
+	handle := func(path, scope string, handler http.HandlerFunc, methods ...string) {
+		if scope != "" {
+			handler = s.authorize(scope, s.limit(handler))
+		}
+		r.Handle(path, instrument(path, handler)).Methods(methods...)
+	}
+	handle("/lights", palette.ScopeRead, s.getLights, "GET")
+	handle("/lights/{id}", palette.ScopeControl, s.setLight, "PUT", "POST")
+	handle("/lights/{id}/name", palette.ScopeControl, s.renameLight, "PUT", "POST")
+	handle("/palette", palette.ScopeControl, s.setPalette, "PUT", "POST")
+	handle("/palette/from-image", palette.ScopeControl, s.setPaletteFromImage, "POST")
+	handle("/palette/import", palette.ScopeControl, s.importPalette, "POST")
+	handle("/palette/shuffle", palette.ScopeControl, s.shufflePalette, "POST")
+	handle("/palette/simulate", palette.ScopeRead, s.simulatePalette, "GET")
+	handle("/palette/preview", palette.ScopeRead, s.previewPalette, "GET")
+	handle("/palette/export", palette.ScopeRead, s.exportPalette, "GET")
+	handle("/on", palette.ScopeControl, s.lightsOn, "PUT", "POST")
+	handle("/off", palette.ScopeControl, s.lightsOut, "PUT", "POST")
+	handle("/circadian", palette.ScopeRead, s.getCircadian, "GET")
+	handle("/circadian", palette.ScopeControl, s.startCircadian, "PUT", "POST")
+	handle("/circadian", palette.ScopeControl, s.stopCircadian, "DELETE")
+	handle("/sunrise", palette.ScopeRead, s.getSunrise, "GET")
+	handle("/sunrise", palette.ScopeControl, s.startSunrise, "PUT", "POST")
+	handle("/sunrise", palette.ScopeControl, s.cancelSunrise, "DELETE")
+	handle("/scenes", palette.ScopeRead, s.getScenes, "GET")
+	handle("/scenes/{name}", palette.ScopeControl, s.saveScene, "PUT", "POST")
+	handle("/scenes/{name}", palette.ScopeControl, s.deleteScene, "DELETE")
+	handle("/scenes/{name}/recall", palette.ScopeControl, s.recallScene, "PUT", "POST")
+	handle("/lightsets", palette.ScopeRead, s.getLightSets, "GET")
+	handle("/lightsets/{name}", palette.ScopeControl, s.saveLightSet, "PUT", "POST")
+	handle("/lightsets/{name}", palette.ScopeControl, s.deleteLightSet, "DELETE")
+	handle("/events", palette.ScopeRead, s.events, "GET")
+	handle("/audit", palette.ScopeAdmin, s.getAudit, "GET")
+	handle("/tokens", palette.ScopeAdmin, s.getTokens, "GET")
+	handle("/tokens", palette.ScopeAdmin, s.createToken, "POST")
+	handle("/tokens/{name}", palette.ScopeAdmin, s.revokeToken, "DELETE")
+	handle("/webhooks", palette.ScopeAdmin, s.getWebhooks, "GET")
+	handle("/webhooks/{name}", palette.ScopeAdmin, s.saveWebhook, "PUT", "POST")
+	handle("/webhooks/{name}", palette.ScopeAdmin, s.deleteWebhook, "DELETE")
+	handle("/webhooks/{name}/deliveries", palette.ScopeAdmin, s.getDeliveries, "GET")
+	handle("/webhooks/{name}/test", palette.ScopeAdmin, s.testWebhook, "POST")
+	handle("/healthz", "", s.healthz, "GET")
+	handle("/readyz", "", s.readyz, "GET")
+	handle("/openapi.json", "", s.getOpenAPI, "GET")
+	handle("/metrics", palette.ScopeRead, s.getMetrics, "GET")
+	handle("/pairing", palette.ScopeAdmin, s.getPairing, "GET")
+	handle("/pairing/{id}", palette.ScopeAdmin, s.startPairing, "POST")
+	handle("/setup", "", s.setup, "GET")
+	r.PathPrefix("/").Handler(instrument("/", s.static())).Methods("GET", "HEAD")
 	return r
 }
 
 func (s *Server) getLights(rw http.ResponseWriter, r *http.Request) {
-	lights, err := s.palette.GetLights()
+	lights, err := s.lights(r)
 	if err != nil {
-		http.Error(rw, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		writeError(rw, err)
 		return
 	}
 
-	lightStates := make([]hue.LightState, 0)
-	ch := s.palette.GetGroup(lights)
-	for attrsOrErr := range ch {
-		if attrsOrErr.Error != nil {
-			err = attrsOrErr.Error
-		} else {
-			state := attrsOrErr.State
-			if state != nil {
-				lightStates = append(lightStates, *state)
-			}
-		}
-	}
-
+	statuses, err := s.palette.GetStatus(lights)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		writeError(rw, err)
 		return
 	}
 	writeJSON(rw, struct {
-		Lights []hue.LightState `json:"lights"`
+		Lights []palette.LightStatus `json:"lights"`
 	}{
-		Lights: lightStates,
+		Lights: statuses,
 	})
 }
 
 func (s *Server) setPalette(rw http.ResponseWriter, r *http.Request) {
+	if err := validateQuery(r.URL.Query(), setPaletteParameters); err != nil {
+		writeError(rw, err)
+		return
+	}
 	var req request
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err := decodeBody(r, "PaletteRequest", &req)
 	if err != nil {
-		http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		writeError(rw, err)
 		return
 	}
-	lights, err := s.palette.GetLights()
+	req = req.withSeed()
+	lights, err := s.lights(r)
 	if err != nil {
-		http.Error(rw, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		writeError(rw, err)
 		return
 	}
-	state := hue.LightState{
-		On:         boolPtr(true),
-		Brightness: req.brightness(),
-		Hue:        req.hue(),
-		Saturation: req.saturation(),
-		Alert:      req.Alert,
-		Effect:     req.effect(),
+	lights = s.selectLights(lights, req.Lights)
+	states, err := req.states(len(lights))
+	if err != nil {
+		writeStatesError(rw, err)
+		return
 	}
-	log.WithFields(log.Fields{
-		"palette":      req.Palette,
-		"primaryState": state,
-	}).Debug("Setting light state")
-	var errChan <-chan error
-	switch strings.ToLower(req.Palette) {
-	case "complementary":
-		errChan = s.palette.SetComplementary(lights, state)
-	case "triad":
-		errChan = s.palette.SetTriad(lights, state)
-	case "analogous", "adjacent":
-		errChan = s.palette.SetAnalogous(lights, state)
-	case "split", "splitcomplementary":
-		errChan = s.palette.SetSplitComplementary(lights, state)
-	case "rectangle":
-		errChan = s.palette.SetRectangle(lights, state)
-	case "square":
-		errChan = s.palette.SetSquare(lights, state)
-	default:
-		http.Error(rw, "Invalid palette", http.StatusBadRequest)
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun")); dryRun {
+		s.writeDryRun(rw, lights, states)
 		return
 	}
-	err = handleErrChan(rw, errChan)
+	s.manualChange(lights)
+	errChan := s.palette.SetGroupAs(change(r, req), lights, states)
+	err = s.handleErrChan(rw, errChan)
 	if err == nil {
+		s.emitChange(r, palette.EventSchemeApplied, lights, changeEvent{Scheme: req.Palette, Request: req})
 		s.getLights(rw, r)
 	}
 }
 
 func (s *Server) lightsOn(rw http.ResponseWriter, r *http.Request) {
 	log.Debug("Lights on!")
-	lights, err := s.palette.GetLights()
-	if err != nil {
-		http.Error(rw, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
-		return
-	}
-	state := hue.LightState{On: boolPtr(true)}
-	errChan := s.palette.SetGroup(lights, []hue.LightState{state})
-	handleErrChan(rw, errChan)
-	if err == nil {
-		s.getLights(rw, r)
-	}
+	s.setPower(rw, r, true)
 }
 
 func (s *Server) lightsOut(rw http.ResponseWriter, r *http.Request) {
 	log.Debug("Lights out!")
-	lights, err := s.palette.GetLights()
+	s.setPower(rw, r, false)
+}
+
+// setPower turns the requested lights, or every light when the body is
+// empty, on or off.
+func (s *Server) setPower(rw http.ResponseWriter, r *http.Request, on bool) {
+	var req struct {
+		Lights []string `json:"lights"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeBody(r, "PowerRequest", &req); err != nil {
+			writeError(rw, err)
+			return
+		}
+	}
+	lights, err := s.lights(r)
 	if err != nil {
-		http.Error(rw, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		writeError(rw, err)
 		return
 	}
-	state := hue.LightState{On: boolPtr(false)}
-	errChan := s.palette.SetGroup(lights, []hue.LightState{state})
-	handleErrChan(rw, errChan)
-	if err == nil {
+	lights = s.selectLights(lights, req.Lights)
+	state := hue.LightState{On: boolPtr(on)}
+	s.manualChange(lights)
+	errChan := s.palette.SetGroupAs(change(r, state), lights, []hue.LightState{state})
+	if err := s.handleErrChan(rw, errChan); err == nil {
 		s.getLights(rw, r)
 	}
 }
 
-func handleErrChan(rw http.ResponseWriter, errChan <-chan error) error {
-	var err error
-	for errResponse := range errChan {
-		if errResponse != nil {
-			err = errResponse
+// manualChange suspends any automatic control of lights a user has just
+// changed by hand.
+func (s *Server) manualChange(lights []hue.Light) {
+	s.mu.Lock()
+	if s.circadian != nil {
+		s.circadian.Pause(lights)
+	}
+	cancelled := s.sunrise != nil && s.sunrise.Affects(lights)
+	if cancelled {
+		log.Debug("Manual change, cancelling sunrise")
+		s.sunrise.Cancel()
+	}
+	s.mu.Unlock()
+	if cancelled {
+		if err := s.palette.SaveSunrise(nil); err != nil {
+			log.WithField("error", err).Warn("Failed to save sunrise")
 		}
 	}
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+}
+
+func (s *Server) handleErrChan(rw http.ResponseWriter, errChan <-chan error) error {
+	var errs palette.LightErrors
+	for err := range errChan {
+		if lightErr, ok := err.(*palette.LightError); ok {
+			errs = append(errs, lightErr)
+		} else if err != nil {
+			errs = append(errs, &palette.LightError{Err: err})
+		}
 	}
-	return err
+	s.monitor.Refresh()
+	if len(errs) > 0 {
+		writeError(rw, errs)
+		return errs
+	}
+	return nil
 }
 
 func writeJSON(rw http.ResponseWriter, payload interface{}) error {
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BrianBland/palette"
+
+	"github.com/BrianBland/go-hue"
+)
+
+func TestRequestWithSeed(t *testing.T) {
+	seeded := palette.Seed("design team")
+	tests := []struct {
+		name string
+		req  request
+		hue  uint16
+	}{
+		{"seed alone", request{Seed: "design team"}, seeded.Hue},
+		{"unknown color", request{Seed: "design team", Color: "mauve"}, seeded.Hue},
+		{"named color", request{Seed: "design team", Color: "Blue"}, hueFromDegrees(namedHues["blue"])},
+		{"hue", request{Seed: "design team", Hue: uint16Ptr(1000)}, 1000},
+	}
+	for _, test := range tests {
+		req := test.req.withSeed()
+		if req.Palette != seeded.Scheme || *req.Saturation != seeded.Saturation || *req.Brightness != seeded.Brightness {
+			t.Errorf("%s: got %s, saturation %d, brightness %d; want %+v", test.name, req.Palette, *req.Saturation, *req.Brightness, seeded)
+		}
+		if h := req.hue(); h == nil || *h != test.hue {
+			t.Errorf("%s: hue %v, want %d", test.name, h, test.hue)
+		}
+		first, err := req.states(4)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if again, _ := test.req.withSeed().states(4); !reflect.DeepEqual(first, again) {
+			t.Errorf("%s: got %v, then %v", test.name, hexes(first), hexes(again))
+		}
+	}
+}
+
+func hexes(states []hue.LightState) []string {
+	colors := make([]string, len(states))
+	for i, state := range states {
+		colors[i] = palette.HexFromColor(palette.ColorFromState(state))
+	}
+	return colors
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+
+	"github.com/BrianBland/palette"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	defaultCandidates = 5
+	maxCandidates     = 20
+)
+
+type shuffleRequest struct {
+	palette.Constraints
+	Lights []string `json:"lights"`
+	Count  int      `json:"count"`
+	Apply  bool     `json:"apply"`
+	Seed   string   `json:"seed"`
+}
+
+type candidate struct {
+	Seed string `json:"seed"`
+	palette.GeneratedPalette
+	Preview []lightPreview `json:"preview"`
+}
+
+type lightPreview struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// shufflePalette returns candidate palettes generated under the request's
+// constraints. Each candidate carries a seed; posting it back with "apply"
+// regenerates exactly that candidate and sets the lights to it.
+func (s *Server) shufflePalette(rw http.ResponseWriter, r *http.Request) {
+	var req shuffleRequest
+	err := decodeBody(r, "ShuffleRequest", &req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := req.Constraints.Validate(); err != nil {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	lights, err := s.lights(r)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	lights = s.selectLights(lights, req.Lights)
+
+	if req.Apply {
+		seed, err := strconv.ParseInt(req.Seed, 10, 64)
+		if err != nil {
+			writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Invalid seed")
+			return
+		}
+		c, err := generateCandidate(seed, lights, req.Constraints)
+		if err != nil {
+			writeErrorStatus(rw, statusUnprocessableEntity, codeUnsatisfied, err.Error())
+			return
+		}
+		log.WithFields(log.Fields{
+			"seed":    req.Seed,
+			"palette": c.Scheme,
+		}).Debug("Applying shuffled palette")
+		s.manualChange(lights)
+		errChan := s.palette.SetGroupAs(change(r, req), lights, c.States)
+		err = s.handleErrChan(rw, errChan)
+		if err == nil {
+			s.getLights(rw, r)
+		}
+		return
+	}
+
+	count := req.Count
+	if count <= 0 {
+		count = defaultCandidates
+	}
+	if count > maxCandidates {
+		count = maxCandidates
+	}
+	seeds := newRand()
+	candidates := make([]candidate, 0, count)
+	for i := 0; i < count; i++ {
+		c, err := generateCandidate(seeds.Int63(), lights, req.Constraints)
+		if err != nil {
+			writeErrorStatus(rw, statusUnprocessableEntity, codeUnsatisfied, err.Error())
+			return
+		}
+		candidates = append(candidates, c)
+	}
+	writeJSON(rw, struct {
+		Candidates []candidate `json:"candidates"`
+	}{
+		Candidates: candidates,
+	})
+}
+
+func generateCandidate(seed int64, lights []hue.Light, constraints palette.Constraints) (candidate, error) {
+	generated, err := palette.Generate(rand.New(rand.NewSource(seed)), len(lights), constraints)
+	if err != nil {
+		return candidate{}, err
+	}
+	preview := make([]lightPreview, len(lights))
+	for i, light := range lights {
+		preview[i] = lightPreview{
+			Id:    light.Id,
+			Name:  light.Name,
+			Color: generated.Colors[i%len(generated.Colors)],
+		}
+	}
+	return candidate{
+		Seed:             strconv.FormatInt(seed, 10),
+		GeneratedPalette: generated,
+		Preview:          preview,
+	}, nil
+}
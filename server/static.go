@@ -0,0 +1,34 @@
+package server
+
+//go:generate go run assets_generate.go
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// The time the binary started stands in for the modification time of the
+// embedded assets.
+var assetsModTime = time.Now()
+
+// assetHandler serves the web UI embedded in the binary by go generate.
+type assetHandler struct{}
+
+func (assetHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	name := path.Clean(r.URL.Path)
+	if name == "/" {
+		name = "/index.html"
+	}
+	content, ok := assets[name]
+	if !ok {
+		http.NotFound(rw, r)
+		return
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		rw.Header().Set("Content-Type", contentType)
+	}
+	http.ServeContent(rw, r, name, assetsModTime, strings.NewReader(content))
+}
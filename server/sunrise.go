@@ -0,0 +1,117 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/BrianBland/palette"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+type sunriseRequest struct {
+	At       string   `json:"at"`
+	Duration string   `json:"duration"`
+	Lights   []string `json:"lights"`
+}
+
+func (s *Server) getSunrise(rw http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sunrise := s.sunrise
+	s.mu.Unlock()
+	if sunrise == nil {
+		writeErrorStatus(rw, http.StatusNotFound, codeNotFound, "No sunrise scheduled")
+		return
+	}
+	writeJSON(rw, sunrise.Status())
+}
+
+func (s *Server) startSunrise(rw http.ResponseWriter, r *http.Request) {
+	var req sunriseRequest
+	err := decodeBody(r, "SunriseRequest", &req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	start := time.Now()
+	if req.At != "" {
+		at, err := palette.ParseTimeOfDay(req.At)
+		if err != nil {
+			writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+		start = at.Next(start)
+	}
+	var duration time.Duration
+	if req.Duration != "" {
+		duration, err = time.ParseDuration(req.Duration)
+		if err != nil || duration <= 0 {
+			writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Invalid duration")
+			return
+		}
+	}
+
+	patterns, err := s.lightPatterns(r, req.Lights)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	if err := s.mayCancelSunrise(r); err != nil {
+		writeError(rw, err)
+		return
+	}
+	sunrise := s.palette.NewSunrise(patterns, start, duration)
+	s.mu.Lock()
+	previous := s.sunrise
+	s.sunrise = sunrise
+	s.mu.Unlock()
+	if previous != nil {
+		previous.Cancel()
+	}
+	log.WithFields(log.Fields{
+		"start":    start,
+		"duration": sunrise.Duration,
+	}).Debug("Scheduling sunrise")
+	sunrise.Run()
+	if err := s.palette.SaveSunrise(sunrise); err != nil {
+		log.WithField("error", err).Warn("Failed to save sunrise")
+	}
+	writeJSON(rw, sunrise.Status())
+}
+
+func (s *Server) cancelSunrise(rw http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	sunrise := s.sunrise
+	s.mu.Unlock()
+	if sunrise == nil {
+		writeErrorStatus(rw, http.StatusNotFound, codeNotFound, "No sunrise scheduled")
+		return
+	}
+	if err := s.mayCancelSunrise(r); err != nil {
+		writeError(rw, err)
+		return
+	}
+	sunrise.Cancel()
+	if err := s.palette.SaveSunrise(nil); err != nil {
+		log.WithField("error", err).Warn("Failed to save sunrise")
+	}
+	writeJSON(rw, sunrise.Status())
+}
+
+// mayCancelSunrise checks that the request's token may use every light the
+// scheduled sunrise sets, if it hasn't finished, since scheduling another
+// cancels it.
+func (s *Server) mayCancelSunrise(r *http.Request) error {
+	s.mu.Lock()
+	sunrise := s.sunrise
+	s.mu.Unlock()
+	if sunrise == nil {
+		return nil
+	}
+	select {
+	case <-sunrise.Done():
+		return nil
+	default:
+	}
+	return s.mayUseAll(r, sunrise.Lights)
+}
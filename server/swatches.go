@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/BrianBland/palette"
+	"github.com/BrianBland/palette/swatch"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+)
+
+func (s *Server) importPalette(rw http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	var body io.Reader = r.Body
+	// The body is only parsed as a form when it's a multipart upload; raw
+	// uploads are often sent as application/x-www-form-urlencoded, and
+	// parsing them would consume the palette.
+	query := r.URL.Query()
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err := r.ParseMultipartForm(maxUploadSize)
+		if err != nil {
+			writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Invalid request body")
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Missing file")
+			return
+		}
+		defer file.Close()
+		if format == "" {
+			format = swatch.FormatFromFilename(header.Filename)
+		}
+		body = file
+		query = r.Form
+	}
+	if format == "" {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Unknown palette format, expected one of: "+strings.Join(swatch.Formats, ", "))
+		return
+	}
+
+	colors, err := swatch.Decode(io.LimitReader(body, maxUploadSize), format)
+	if err == swatch.ErrUnknownFormat {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Unknown palette format, expected one of: "+strings.Join(swatch.Formats, ", "))
+		return
+	}
+	if err != nil {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Invalid palette: "+err.Error())
+		return
+	}
+	if len(colors) == 0 {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "No colors found in palette")
+		return
+	}
+
+	lights, err := s.lights(r)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	lights = s.selectLights(lights, formLights(query))
+	states := make([]hue.LightState, len(colors))
+	for i, c := range colors {
+		states[i] = palette.StateFromColor(c)
+		states[i].On = boolPtr(true)
+	}
+	log.WithFields(log.Fields{
+		"format": format,
+		"colors": len(colors),
+	}).Debug("Importing palette")
+
+	s.manualChange(lights)
+	errChan := s.palette.SetGroupAs(change(r, nil), lights, states)
+	err = s.handleErrChan(rw, errChan)
+	if err == nil {
+		s.getLights(rw, r)
+	}
+}
+
+func (s *Server) exportPalette(rw http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := strings.ToLower(query.Get("format"))
+	if format == "" {
+		format = swatch.JSON
+	}
+	if swatch.Extension(format) == "" {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, "Unknown palette format, expected one of: "+strings.Join(swatch.Formats, ", "))
+		return
+	}
+
+	lights, err := s.lights(r)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	lights = s.selectLights(lights, formLights(query))
+	statuses, err := s.palette.GetStatus(lights)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	colors := make([]swatch.Color, len(statuses))
+	for i, status := range statuses {
+		colors[i] = swatch.FromColor(status.Name, palette.ColorFromState(status.LightState))
+	}
+
+	var buf bytes.Buffer
+	if err := swatch.Encode(&buf, format, colors); err != nil {
+		writeError(rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", swatch.ContentType(format))
+	rw.Header().Set("Content-Disposition", `attachment; filename="palette`+swatch.Extension(format)+`"`)
+	buf.WriteTo(rw)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/BrianBland/palette"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+type tokenRequest struct {
+	Name   string   `json:"name"`
+	Scope  string   `json:"scope"`
+	Lights []string `json:"lights"`
+}
+
+func (s *Server) getTokens(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, struct {
+		Tokens []palette.Token `json:"tokens"`
+	}{
+		Tokens: s.palette.Tokens(),
+	})
+}
+
+// createToken adds a token, responding with its secret. The secret can't be
+// retrieved again later.
+func (s *Server) createToken(rw http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	err := decodeBody(r, "TokenRequest", &req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	secret, token, err := s.palette.CreateToken(strings.TrimSpace(req.Name), strings.ToLower(req.Scope), req.Lights)
+	if err == palette.ErrTokenExists {
+		writeErrorStatus(rw, http.StatusConflict, "token_exists", err.Error())
+		return
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	log.WithFields(log.Fields{
+		"token": token.Name,
+		"scope": token.Scope,
+		"by":    callerName(r),
+	}).Info("Created token")
+	writeJSON(rw, struct {
+		Secret string `json:"secret"`
+		palette.Token
+	}{
+		Secret: secret,
+		Token:  token,
+	})
+}
+
+func (s *Server) revokeToken(rw http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	err := s.palette.RevokeToken(name)
+	if err == palette.ErrUnknownToken {
+		writeErrorStatus(rw, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	log.WithFields(log.Fields{
+		"token": name,
+		"by":    callerName(r),
+	}).Info("Revoked token")
+	s.getTokens(rw, r)
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxBodySize = 1 << 20
+
+type fieldError struct {
+	Field   string
+	Message string
+}
+
+// validationErrors lists every way a request failed to match its schema.
+type validationErrors []fieldError
+
+func (errs validationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Field + ": " + err.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func errInvalidParameter(name string) error {
+	return validationErrors{{Field: name, Message: "invalid value"}}
+}
+
+// decodeBody validates the JSON body of r against the named schema from the
+// OpenAPI document before decoding it into v.
+func decodeBody(r *http.Request, schemaName string, v interface{}) error {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		return err
+	}
+	var raw interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return err
+	}
+	if errs := validate(ref(schemaName), raw, ""); len(errs) > 0 {
+		return errs
+	}
+	return json.Unmarshal(body, v)
+}
+
+// validateQuery checks the query parameters present in q against params.
+func validateQuery(q url.Values, params []parameter) error {
+	var errs validationErrors
+	for _, param := range params {
+		if param.In != "query" {
+			continue
+		}
+		values, ok := q[param.Name]
+		if !ok {
+			if param.Required {
+				errs = append(errs, fieldError{param.Name, "required"})
+			}
+			continue
+		}
+		for _, value := range values {
+			// Handlers read an empty parameter as one left out.
+			if value == "" {
+				if param.Required {
+					errs = append(errs, fieldError{param.Name, "required"})
+				}
+				continue
+			}
+			typed, err := queryValue(param.Schema, value)
+			if err != nil {
+				errs = append(errs, fieldError{param.Name, err.Error()})
+				continue
+			}
+			errs = append(errs, validate(param.Schema, typed, param.Name)...)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// queryValue converts a query string value to the JSON type of s.
+func queryValue(s *schema, value string) (interface{}, error) {
+	switch resolve(s).Type {
+	case "integer", "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number")
+		}
+		return f, nil
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false")
+		}
+		return b, nil
+	}
+	return value, nil
+}
+
+func validate(s *schema, value interface{}, field string) validationErrors {
+	s = resolve(s)
+	if value == nil {
+		return nil
+	}
+	var errs validationErrors
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fieldError{field, fmt.Sprintf(format, args...)})
+	}
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("expected an object")
+			break
+		}
+		// A null is as good as leaving a required property out.
+		for _, name := range s.Required {
+			if v, ok := object[name]; !ok || v == nil {
+				errs = append(errs, fieldError{joinField(field, name), "required"})
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if v, ok := object[name]; ok {
+				errs = append(errs, validate(s.Properties[name], v, joinField(field, name))...)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			fail("expected an array")
+			break
+		}
+		for i, v := range array {
+			errs = append(errs, validate(s.Items, v, fmt.Sprintf("%s[%d]", field, i))...)
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			fail("expected a number")
+			break
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			fail("expected an integer")
+			break
+		}
+		switch {
+		case s.Minimum != nil && s.Maximum != nil && (n < *s.Minimum || n > *s.Maximum):
+			fail("must be between %g and %g", *s.Minimum, *s.Maximum)
+		case s.Minimum != nil && n < *s.Minimum:
+			fail("must be at least %g", *s.Minimum)
+		case s.Maximum != nil && n > *s.Maximum:
+			fail("must be at most %g", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("expected true or false")
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("expected a string")
+			break
+		}
+		if len(s.Enum) > 0 && !containsFold(s.Enum, str) {
+			fail("must be one of: %s", strings.Join(s.Enum, ", "))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(str) {
+			if s.Description != "" {
+				fail("expected %s", strings.ToLower(s.Description[:1])+s.Description[1:])
+			} else {
+				fail("does not match %s", s.Pattern)
+			}
+		}
+	}
+	return errs
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// containsFold reports whether s is in values, ignoring case as palette names
+// and colors do.
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
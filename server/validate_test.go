@@ -0,0 +1,166 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		schema string
+		body   string
+		errs   validationErrors
+	}{
+		{"TokenRequest", `{"name": "kitchen", "scope": "control", "lights": ["3", "living*"]}`, nil},
+		// Enums ignore case, and unknown fields are left alone.
+		{"TokenRequest", `{"name": "kitchen", "scope": "ADMIN", "extra": 1}`, nil},
+		{"TokenRequest", `{}`, validationErrors{{"name", "required"}, {"scope", "required"}}},
+		{"TokenRequest", `{"name": " ", "scope": "root", "lights": [3]}`, validationErrors{
+			{"lights[0]", "expected a string"},
+			{"name", "expected a name that isn't blank"},
+			{"scope", "must be one of: read, control, admin"},
+		}},
+		{"TokenRequest", `[]`, validationErrors{{"", "expected an object"}}},
+		{"LightRequest", `{"on": true, "brightness": 254, "color": "#FF8000"}`, nil},
+		{"LightRequest", `{"on": "yes", "brightness": 255, "hue": 1.5, "ct": 100, "color": "#ff80"}`, validationErrors{
+			{"brightness", "must be between 0 and 254"},
+			{"color", "expected color as #rrggbb or by name"},
+			{"ct", "must be between 153 and 500"},
+			{"hue", "expected an integer"},
+			{"on", "expected true or false"},
+		}},
+		{"ShuffleRequest", `{"minDistance": -1, "count": "3", "avoidHues": [{"from": "red"}]}`, validationErrors{
+			{"avoidHues[0].from", "expected a number"},
+			{"count", "expected a number"},
+			{"minDistance", "must be at least 0"},
+		}},
+		{"CircadianRequest", `{"curve": [{"time": "7:30", "ct": 300, "bri": 200}, {"time": "noon"}], "interval": "1m30s"}`, validationErrors{
+			{"curve[1].ct", "required"},
+			{"curve[1].bri", "required"},
+			{"curve[1].time", "expected time of day as 15:04"},
+		}},
+		{"SunriseRequest", `{"duration": "half an hour"}`, validationErrors{{"duration", "expected duration such as 90s or 1h30m"}}},
+		{"WebhookRequest", `{"url": "ftp://example.com", "events": ["light.changed"]}`, validationErrors{
+			{"url", "expected http or https URL to POST events to"},
+		}},
+		// Nulls count as not set, but empty strings are checked like any other.
+		{"LightRequest", `{"brightness": null}`, nil},
+		{"LightRequest", `{"color": ""}`, validationErrors{{"color", "expected color as #rrggbb or by name"}}},
+		{"TokenRequest", `{"name": "", "scope": "admin"}`, validationErrors{{"name", "expected a name that isn't blank"}}},
+		{"TokenRequest", `{"name": "x", "scope": ""}`, validationErrors{{"scope", "must be one of: read, control, admin"}}},
+		{"TokenRequest", `{"name": null, "scope": null}`, validationErrors{{"name", "required"}, {"scope", "required"}}},
+		{"WebhookRequest", `{"url": ""}`, validationErrors{{"url", "expected http or https URL to POST events to"}}},
+	}
+	for _, test := range tests {
+		r, err := http.NewRequest("POST", "/", strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v map[string]interface{}
+		err = decodeBody(r, test.schema, &v)
+		if test.errs == nil {
+			if err != nil {
+				t.Errorf("%s %s: %v", test.schema, test.body, err)
+			}
+			continue
+		}
+		if errs, ok := err.(validationErrors); !ok || !reflect.DeepEqual(errs, test.errs) {
+			t.Errorf("%s %s: got %v, want %v", test.schema, test.body, err, test.errs)
+		}
+	}
+}
+
+func TestDecodeBodyInvalidJSON(t *testing.T) {
+	for _, body := range []string{"", "{", `{"name": "kitchen",}`} {
+		r, err := http.NewRequest("POST", "/", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v map[string]interface{}
+		err = decodeBody(r, "TokenRequest", &v)
+		if _, ok := err.(validationErrors); err == nil || ok {
+			t.Errorf("%q: got %v, want a JSON error", body, err)
+		}
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		errs  validationErrors
+	}{
+		{"", nil},
+		{"palette=triad&brightness=100&lights=3&names=true&format=png", nil},
+		{"palette=TRIAD&color=Red&minContrast=12.5", nil},
+		{"lights=0&names=maybe", validationErrors{
+			{"lights", "must be between 1 and 64"},
+			{"names", "expected true or false"},
+		}},
+		{"lights=65&hue=red&format=gif", validationErrors{
+			{"hue", "expected a number"},
+			{"lights", "must be between 1 and 64"},
+			{"format", "must be one of: svg, png"},
+		}},
+		{"brightness=100&brightness=300", validationErrors{{"brightness", "must be between 0 and 254"}}},
+		// Empty parameters are left out, as the handlers read them.
+		{"palette=&hue=&lights=", nil},
+	}
+	for _, test := range tests {
+		q, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = validateQuery(q, previewParameters)
+		if test.errs == nil {
+			if err != nil {
+				t.Errorf("%q: %v", test.query, err)
+			}
+			continue
+		}
+		if errs, ok := err.(validationErrors); !ok || !reflect.DeepEqual(errs, test.errs) {
+			t.Errorf("%q: got %v, want %v", test.query, err, test.errs)
+		}
+	}
+
+	required := []parameter{{Name: "since", In: "query", Required: true, Schema: str("")}}
+	for _, query := range []url.Values{{}, {"since": {""}}} {
+		if err := validateQuery(query, required); err == nil || err.Error() != "since: required" {
+			t.Errorf("missing a required parameter in %v: %v", query, err)
+		}
+	}
+}
+
+func TestCompilePatterns(t *testing.T) {
+	var check func(name string, s *schema)
+	check = func(name string, s *schema) {
+		if s.Pattern != "" && s.pattern == nil {
+			t.Errorf("%s: %q wasn't compiled", name, s.Pattern)
+		}
+		if s.Items != nil {
+			check(name+"[]", s.Items)
+		}
+		for property, s := range s.Properties {
+			check(name+"."+property, s)
+		}
+	}
+	for name, s := range schemas {
+		check(name, s)
+	}
+	for path, operations := range openAPI().Paths {
+		for method, op := range operations {
+			for _, param := range op.Parameters {
+				check(method+" "+path+" "+param.Name, param.Schema)
+			}
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("an invalid pattern didn't panic")
+		}
+	}()
+	compilePatterns(arrayOf(&schema{Type: "string", Pattern: `(unclosed`}))
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BrianBland/palette"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// webhookCheckInterval is how often the watchers check whether any
+	// webhook wants their events.
+	webhookCheckInterval = 10 * time.Second
+	// bridgeCheckInterval is how often bridges are checked for
+	// bridge.unreachable events.
+	bridgeCheckInterval = time.Minute
+
+	defaultDeliveryLimit = 100
+	maxDeliveryLimit     = palette.DefaultDeliveryLogSize
+)
+
+// changeEvent is the data of scheme.applied and scene.recalled events.
+type changeEvent struct {
+	Caller  string      `json:"caller"`
+	Lights  []string    `json:"lights"`
+	Scheme  string      `json:"scheme,omitempty"`
+	Scene   string      `json:"scene,omitempty"`
+	Request interface{} `json:"request,omitempty"`
+}
+
+// lightChangedEvent is the data of light.changed events. Previous is missing
+// for lights palette hadn't seen before.
+type lightChangedEvent struct {
+	Light    palette.LightStatus  `json:"light"`
+	Previous *palette.LightStatus `json:"previous,omitempty"`
+}
+
+type bridgeUnreachableEvent struct {
+	Bridge palette.BridgeHealth `json:"bridge"`
+}
+
+// EnableWebhooks delivers events to the configured webhooks, and starts
+// watching the lights and bridges for the events that come from them.
+func (s *Server) EnableWebhooks() {
+	s.palette.SetWebhookSender(palette.NewWebhookSender())
+	go s.watchLights()
+	go s.watchBridges()
+}
+
+// watchLights emits light.changed events while any webhook wants them.
+func (s *Server) watchLights() {
+	for {
+		if s.palette.WantsEvent(palette.EventLightChanged) {
+			s.emitLightChanges()
+		}
+		time.Sleep(webhookCheckInterval)
+	}
+}
+
+func (s *Server) emitLightChanges() {
+	updates, cancel := s.monitor.Subscribe()
+	defer cancel()
+	check := time.NewTicker(webhookCheckInterval)
+	defer check.Stop()
+
+	var last map[string]palette.LightStatus
+	for {
+		select {
+		case <-check.C:
+			if !s.palette.WantsEvent(palette.EventLightChanged) {
+				return
+			}
+		case statuses := <-updates:
+			current := make(map[string]palette.LightStatus, len(statuses))
+			for _, status := range statuses {
+				current[status.Id] = status
+				// The first snapshot is what the lights were already like.
+				if last == nil {
+					continue
+				}
+				event := lightChangedEvent{Light: status}
+				if previous, ok := last[status.Id]; ok {
+					if sameStatus(previous, status) {
+						continue
+					}
+					event.Previous = &previous
+				}
+				s.palette.Emit(palette.EventLightChanged, event)
+			}
+			last = current
+		}
+	}
+}
+
+func sameStatus(a, b palette.LightStatus) bool {
+	aJSON, _ := json.Marshal(a)
+	bJSON, _ := json.Marshal(b)
+	return string(aJSON) == string(bJSON)
+}
+
+// watchBridges emits a bridge.unreachable event when a bridge stops
+// responding, while any webhook wants them.
+func (s *Server) watchBridges() {
+	unavailable := make(map[string]bool)
+	for {
+		if s.palette.WantsEvent(palette.EventBridgeUnreachable) {
+			for _, bridge := range s.health().Bridges {
+				down := bridge.Status == palette.HealthUnavailable
+				if down && !unavailable[bridge.Id] {
+					s.palette.Emit(palette.EventBridgeUnreachable, bridgeUnreachableEvent{Bridge: bridge})
+				}
+				unavailable[bridge.Id] = down
+			}
+		}
+		time.Sleep(bridgeCheckInterval)
+	}
+}
+
+func (s *Server) emitChange(r *http.Request, typ string, lights []hue.Light, event changeEvent) {
+	event.Caller = callerName(r)
+	event.Lights = palette.LightIds(lights)
+	s.palette.Emit(typ, event)
+}
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+func (s *Server) getWebhooks(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, struct {
+		Webhooks []palette.Webhook `json:"webhooks"`
+	}{
+		Webhooks: s.palette.Webhooks(),
+	})
+}
+
+// saveWebhook adds or replaces a webhook, responding with its secret for
+// checking signatures.
+func (s *Server) saveWebhook(rw http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := decodeBody(r, "WebhookRequest", &req); err != nil {
+		writeError(rw, err)
+		return
+	}
+	webhook := palette.Webhook{
+		Name:   mux.Vars(r)["name"],
+		URL:    strings.TrimSpace(req.URL),
+		Events: req.Events,
+		Secret: req.Secret,
+	}
+	if err := palette.ValidateWebhook(webhook); err != nil {
+		writeErrorStatus(rw, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	webhook, err := s.palette.SaveWebhook(webhook)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	log.WithFields(log.Fields{
+		"webhook": webhook.Name,
+		"events":  webhook.Events,
+		"by":      callerName(r),
+	}).Info("Saved webhook")
+	writeJSON(rw, webhook)
+}
+
+func (s *Server) deleteWebhook(rw http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	err := s.palette.DeleteWebhook(name)
+	if err == palette.ErrUnknownWebhook {
+		writeErrorStatus(rw, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	log.WithFields(log.Fields{
+		"webhook": name,
+		"by":      callerName(r),
+	}).Info("Deleted webhook")
+	s.getWebhooks(rw, r)
+}
+
+// getDeliveries returns the most recent attempts to deliver events to a
+// webhook, newest first.
+func (s *Server) getDeliveries(rw http.ResponseWriter, r *http.Request) {
+	if err := validateQuery(r.URL.Query(), deliveriesParameters); err != nil {
+		writeError(rw, err)
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if !s.webhookExists(name) {
+		writeErrorStatus(rw, http.StatusNotFound, codeNotFound, palette.ErrUnknownWebhook.Error())
+		return
+	}
+	limit := defaultDeliveryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, _ = strconv.Atoi(v)
+	}
+	deliveries := []palette.WebhookDelivery{}
+	if sender := s.palette.WebhookSender(); sender != nil {
+		deliveries = append(deliveries, sender.Deliveries(name, limit)...)
+	}
+	writeJSON(rw, struct {
+		Deliveries []palette.WebhookDelivery `json:"deliveries"`
+	}{
+		Deliveries: deliveries,
+	})
+}
+
+// testWebhook sends a ping event to a webhook and responds with how the
+// delivery went, whether or not the receiver accepted it.
+func (s *Server) testWebhook(rw http.ResponseWriter, r *http.Request) {
+	delivery, err := s.palette.TestWebhook(mux.Vars(r)["name"])
+	if err == palette.ErrUnknownWebhook {
+		writeErrorStatus(rw, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+	writeJSON(rw, struct {
+		OK bool `json:"ok"`
+		palette.WebhookDelivery
+	}{
+		OK:              delivery.OK(),
+		WebhookDelivery: delivery,
+	})
+}
+
+func (s *Server) webhookExists(name string) bool {
+	for _, webhook := range s.palette.Webhooks() {
+		if webhook.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+(function() {
+  "use strict";
+
+  var lights = [];
+
+  function $(id) {
+    return document.getElementById(id);
+  }
+
+  function setStatus(message, isError) {
+    var status = $("status");
+    status.textContent = message || "";
+    status.className = isError ? "status error" : "status";
+  }
+
+  // The API token, once the server requires one, is kept in local storage.
+  function token() {
+    return window.localStorage.getItem("paletteToken") || "";
+  }
+
+  // withToken adds the token to URLs that can't carry an Authorization header,
+  // like images and event streams.
+  function withToken(url) {
+    if (!token()) {
+      return url;
+    }
+    return url + (url.indexOf("?") < 0 ? "?" : "&") + "access_token=" + encodeURIComponent(token());
+  }
+
+  function api(method, url, body, retried) {
+    var options = {method: method, headers: {}};
+    if (body !== undefined) {
+      options.headers["Content-Type"] = "application/json";
+      options.body = JSON.stringify(body);
+    }
+    if (token()) {
+      options.headers["Authorization"] = "Bearer " + token();
+    }
+    return fetch(url, options).then(function(response) {
+      if (response.status === 401 && !retried) {
+        var entered = window.prompt("This server requires an API token:");
+        if (entered) {
+          window.localStorage.setItem("paletteToken", entered.trim());
+          listen();
+          return api(method, url, body, true);
+        }
+      }
+      return response.text().then(function(text) {
+        var data = null;
+        try {
+          data = text ? JSON.parse(text) : null;
+        } catch (e) {
+          data = null;
+        }
+        if (!response.ok) {
+          var message = data && data.error ? data.error.message : text;
+          var err = new Error(message || response.statusText);
+          err.code = data && data.error ? data.error.code : "";
+          throw err;
+        }
+        return data;
+      });
+    }).then(function(data) {
+      setStatus("");
+      return data;
+    }, function(err) {
+      setStatus(err.message, true);
+      throw err;
+    });
+  }
+
+  // Hex color to the bridge's hue (0-65535) and saturation (0-254).
+  function hexToHueSat(hex) {
+    var r = parseInt(hex.substr(1, 2), 16) / 255;
+    var g = parseInt(hex.substr(3, 2), 16) / 255;
+    var b = parseInt(hex.substr(5, 2), 16) / 255;
+    var max = Math.max(r, g, b);
+    var min = Math.min(r, g, b);
+    var d = max - min;
+    var h = 0;
+    if (d !== 0) {
+      if (max === r) {
+        h = ((g - b) / d) % 6;
+      } else if (max === g) {
+        h = (b - r) / d + 2;
+      } else {
+        h = (r - g) / d + 4;
+      }
+    }
+    h = (h * 60 + 360) % 360;
+    return {
+      hue: Math.round(h / 360 * 65535),
+      saturation: max === 0 ? 0 : Math.round(d / max * 254)
+    };
+  }
+
+  function setLight(id, body) {
+    return api("PUT", "/lights/" + encodeURIComponent(id), body);
+  }
+
+  function renderLight(light) {
+    var card = document.createElement("div");
+    card.className = "light";
+    card.id = "light-" + light.id;
+
+    var name = document.createElement("input");
+    name.className = "name";
+    name.setAttribute("aria-label", "Light name");
+    name.addEventListener("change", function() {
+      api("PUT", "/lights/" + encodeURIComponent(light.id) + "/name", {name: name.value});
+    });
+
+    var controls = document.createElement("div");
+    controls.className = "controls";
+
+    var power = document.createElement("input");
+    power.type = "checkbox";
+    power.className = "power-toggle";
+    power.setAttribute("aria-label", "Power");
+    power.addEventListener("change", function() {
+      setLight(light.id, {on: power.checked});
+    });
+
+    var color = document.createElement("input");
+    color.type = "color";
+    color.setAttribute("aria-label", "Color");
+    color.addEventListener("change", function() {
+      setLight(light.id, {on: true, color: color.value});
+    });
+
+    var brightness = document.createElement("input");
+    brightness.type = "range";
+    brightness.min = 1;
+    brightness.max = 254;
+    brightness.setAttribute("aria-label", "Brightness");
+    brightness.addEventListener("change", function() {
+      setLight(light.id, {on: true, brightness: parseInt(brightness.value, 10)});
+    });
+
+    controls.appendChild(power);
+    controls.appendChild(color);
+    controls.appendChild(brightness);
+    card.appendChild(name);
+    card.appendChild(controls);
+    return card;
+  }
+
+  // updateLight refreshes a card from the latest state, leaving alone any
+  // control the user is currently using.
+  function updateLight(card, light) {
+    var on = light.on === true;
+    var inputs = card.getElementsByTagName("input");
+    var name = inputs[0], power = inputs[1], color = inputs[2], brightness = inputs[3];
+    card.className = on ? "light" : "light off";
+    card.style.borderTopColor = light.color;
+    if (document.activeElement !== name) {
+      name.value = light.name;
+    }
+    power.checked = on;
+    if (document.activeElement !== color && on) {
+      color.value = light.color;
+    }
+    if (document.activeElement !== brightness && light.bri !== undefined) {
+      brightness.value = light.bri;
+    }
+  }
+
+  function renderLights(data) {
+    lights = (data && data.lights) || [];
+    var container = $("lights");
+    var seen = {};
+    lights.forEach(function(light) {
+      var card = $("light-" + light.id);
+      if (!card) {
+        card = renderLight(light);
+        container.appendChild(card);
+      }
+      updateLight(card, light);
+      seen[card.id] = true;
+    });
+    Array.prototype.slice.call(container.children).forEach(function(card) {
+      if (!seen[card.id]) {
+        container.removeChild(card);
+      }
+    });
+    updatePreview();
+  }
+
+  function schemeRequest() {
+    var fields = $("scheme-form").elements;
+    var hueSat = hexToHueSat(fields["color"].value);
+    return {
+      palette: fields["palette"].value,
+      hue: hueSat.hue,
+      saturation: hueSat.saturation,
+      brightness: parseInt(fields["brightness"].value, 10)
+    };
+  }
+
+  function updatePreview() {
+    var req = schemeRequest();
+    var query = [
+      "palette=" + encodeURIComponent(req.palette),
+      "hue=" + req.hue,
+      "saturation=" + req.saturation,
+      "brightness=" + req.brightness,
+      "names=true"
+    ];
+    if (lights.length > 0) {
+      query.push("lights=" + lights.length);
+    }
+    var src = withToken("/palette/preview?" + query.join("&"));
+    var img = $("scheme-preview");
+    if (img.getAttribute("src") !== src) {
+      img.setAttribute("src", src);
+    }
+  }
+
+  function renderScenes(data) {
+    var list = $("scenes");
+    list.innerHTML = "";
+    ((data && data.scenes) || []).forEach(function(scene) {
+      var item = document.createElement("li");
+      var name = document.createElement("span");
+      name.className = "scene-name";
+      name.textContent = scene.name;
+      var recall = document.createElement("button");
+      recall.type = "button";
+      recall.textContent = "Recall";
+      recall.addEventListener("click", function() {
+        api("POST", "/scenes/" + encodeURIComponent(scene.name) + "/recall").then(renderLights);
+      });
+      var remove = document.createElement("button");
+      remove.type = "button";
+      remove.textContent = "Delete";
+      remove.addEventListener("click", function() {
+        if (window.confirm("Delete scene \"" + scene.name + "\"?")) {
+          api("DELETE", "/scenes/" + encodeURIComponent(scene.name)).then(renderScenes);
+        }
+      });
+      item.appendChild(name);
+      item.appendChild(recall);
+      item.appendChild(remove);
+      list.appendChild(item);
+    });
+  }
+
+  function loadScenes() {
+    return api("GET", "/scenes").then(renderScenes);
+  }
+
+  var source = null;
+  var polling = null;
+
+  function listen() {
+    if (!window.EventSource) {
+      if (!polling) {
+        polling = window.setInterval(function() {
+          api("GET", "/lights").then(renderLights);
+        }, 5000);
+      }
+      return;
+    }
+    if (source) {
+      source.close();
+    }
+    source = new EventSource(withToken("/events"));
+    source.addEventListener("lights", function(e) {
+      renderLights(JSON.parse(e.data));
+    });
+    source.onerror = function() {
+      setStatus("Reconnecting…", true);
+    };
+    source.onopen = function() {
+      setStatus("");
+    };
+  }
+
+  $("all-on").addEventListener("click", function() {
+    api("POST", "/on").then(renderLights);
+  });
+  $("all-off").addEventListener("click", function() {
+    api("POST", "/off").then(renderLights);
+  });
+
+  var schemeForm = $("scheme-form");
+  schemeForm.addEventListener("change", updatePreview);
+  schemeForm.addEventListener("submit", function(e) {
+    e.preventDefault();
+    api("POST", "/palette", schemeRequest()).then(renderLights);
+  });
+
+  $("scene-form").addEventListener("submit", function(e) {
+    e.preventDefault();
+    var input = this.elements["name"];
+    var name = input.value.trim();
+    if (!name) {
+      return;
+    }
+    api("PUT", "/scenes/" + encodeURIComponent(name)).then(loadScenes);
+    input.value = "";
+  });
+
+  api("GET", "/lights").then(renderLights, function(err) {
+    // Until palette is paired with a bridge there's nothing to show here.
+    if (err.code === "bridge_unauthorized") {
+      window.location.href = "/setup";
+    }
+  });
+  loadScenes();
+  listen();
+})();
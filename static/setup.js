@@ -0,0 +1,99 @@
+(function() {
+  "use strict";
+
+  var polling = null;
+
+  function $(id) {
+    return document.getElementById(id);
+  }
+
+  function setStatus(message, isError) {
+    var status = $("status");
+    status.textContent = message || "";
+    status.className = isError ? "status error" : "status";
+  }
+
+  function api(method, url, retried) {
+    var headers = {};
+    var token = window.localStorage.getItem("paletteToken");
+    if (token) {
+      headers["Authorization"] = "Bearer " + token;
+    }
+    return fetch(url, {method: method, headers: headers}).then(function(response) {
+      if (response.status === 401 && !retried) {
+        var entered = window.prompt("This server requires an API token:");
+        if (entered) {
+          window.localStorage.setItem("paletteToken", entered.trim());
+          return api(method, url, true);
+        }
+      }
+      return response.json().then(function(data) {
+        if (!response.ok) {
+          throw new Error(data && data.error ? data.error.message : response.statusText);
+        }
+        return data;
+      });
+    }).then(function(data) {
+      setStatus("");
+      return data;
+    }, function(err) {
+      setStatus(err.message, true);
+      throw err;
+    });
+  }
+
+  var descriptions = {
+    unpaired: "Not paired",
+    waiting: "Press the link button on the bridge now…",
+    paired: "Paired",
+    failed: "Pairing failed"
+  };
+
+  function render(data) {
+    var bridges = (data && data.bridges) || [];
+    var list = $("bridges");
+    list.innerHTML = "";
+    $("no-bridges").hidden = bridges.some(function(status) {
+      return status.state !== "paired";
+    });
+    bridges.forEach(function(status) {
+      var item = document.createElement("li");
+      var name = document.createElement("span");
+      name.className = "bridge-name";
+      name.textContent = status.bridge.id + " (" + status.bridge.address + ")";
+      var state = document.createElement("span");
+      state.className = "bridge-state " + status.state;
+      state.textContent = descriptions[status.state] || status.state;
+      if (status.error && status.state !== "unpaired") {
+        state.textContent += ": " + status.error;
+      }
+      item.appendChild(name);
+      item.appendChild(state);
+      if (status.state === "unpaired" || status.state === "failed") {
+        var pair = document.createElement("button");
+        pair.type = "button";
+        pair.textContent = "Pair";
+        pair.addEventListener("click", function() {
+          api("POST", "/pairing/" + encodeURIComponent(status.bridge.id)).then(render);
+        });
+        item.appendChild(pair);
+      }
+      list.appendChild(item);
+    });
+    var waiting = bridges.some(function(status) {
+      return status.state === "waiting";
+    });
+    if (waiting && !polling) {
+      polling = window.setInterval(refresh, 2000);
+    } else if (!waiting && polling) {
+      window.clearInterval(polling);
+      polling = null;
+    }
+  }
+
+  function refresh() {
+    return api("GET", "/pairing").then(render);
+  }
+
+  refresh();
+})();
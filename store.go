@@ -0,0 +1,304 @@
+package palette
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// SchemaVersion is the version of the config file this palette writes. Older
+// files are migrated when loaded; newer ones are refused rather than have
+// fields they don't know about dropped.
+const SchemaVersion = 3
+
+// configMode keeps the config, which holds bridge credentials and token
+// hashes, readable by its owner alone.
+const configMode = 0600
+
+// refreshInterval limits how often readers check whether another palette has
+// changed the config.
+const refreshInterval = time.Second
+
+// state is everything palette keeps in its config file.
+type state struct {
+	Version   int                     `json:"version"`
+	Bridges   map[string]bridgeConfig `json:"bridges,omitempty"`
+	Scenes    map[string]Scene        `json:"scenes,omitempty"`
+	Schedules Schedules               `json:"schedules"`
+	LightSets map[string][]string     `json:"lightSets,omitempty"`
+	Tokens    []Token                 `json:"tokens,omitempty"`
+	Webhooks  []Webhook               `json:"webhooks,omitempty"`
+}
+
+func newState() state {
+	return state{
+		Version:   SchemaVersion,
+		Bridges:   make(map[string]bridgeConfig),
+		Scenes:    make(map[string]Scene),
+		LightSets: make(map[string][]string),
+	}
+}
+
+// migrations[i] upgrades a config from version i to version i+1. They work on
+// the raw JSON so they don't depend on the current types.
+var migrations = []func(map[string]json.RawMessage) error{
+	// Version 0 was unversioned, and held the user on the only bridge palette
+	// controlled. Keep it under an empty bridge ID until a bridge accepts it.
+	func(c map[string]json.RawMessage) error {
+		raw, ok := c["username"]
+		if !ok {
+			return nil
+		}
+		delete(c, "username")
+		var username string
+		if err := json.Unmarshal(raw, &username); err != nil || username == "" {
+			return err
+		}
+		bridges := make(map[string]bridgeConfig)
+		if b, ok := c["bridges"]; ok {
+			if err := json.Unmarshal(b, &bridges); err != nil {
+				return err
+			}
+		}
+		for _, saved := range bridges {
+			if saved.Username == username {
+				return nil
+			}
+		}
+		bridges[""] = bridgeConfig{Username: username}
+		b, err := json.Marshal(bridges)
+		c["bridges"] = b
+		return err
+	},
+	// Version 2 added schedules and light sets, which start out empty.
+	func(c map[string]json.RawMessage) error {
+		return nil
+	},
+	// Version 3 added webhooks, which also start out empty.
+	func(c map[string]json.RawMessage) error {
+		return nil
+	},
+}
+
+// store reads and writes the config file. Writes replace the file atomically,
+// so readers never see half of one, and hold a lock on it, so palettes
+// sharing a directory don't lose each other's changes.
+type store struct {
+	path string
+
+	// mu serializes this process's changes; the file lock serializes them
+	// with other processes.
+	mu sync.Mutex
+
+	statMu  sync.Mutex
+	modTime time.Time
+	size    int64
+	checked time.Time
+}
+
+func newStore(dir string) *store {
+	return &store{path: filepath.Join(dir, CONFIGFILE)}
+}
+
+// read loads the config, migrating it to the current version. It reports
+// whether it was migrated, so needs writing back.
+func (s *store) read() (state, bool, error) {
+	st := newState()
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return st, false, nil
+	}
+	if err != nil {
+		return st, false, err
+	}
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return st, false, err
+	}
+	s.noteStat(fi)
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return st, false, fmt.Errorf("%s: %v", s.path, err)
+	}
+	version := 0
+	if v, ok := raw["version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return st, false, fmt.Errorf("%s: invalid version: %v", s.path, err)
+		}
+	}
+	if version > SchemaVersion {
+		return st, false, fmt.Errorf("%s is version %d, newer than this palette supports (%d)", s.path, version, SchemaVersion)
+	}
+	if version < 0 {
+		return st, false, fmt.Errorf("%s: invalid version %d", s.path, version)
+	}
+	for v := version; v < SchemaVersion; v++ {
+		if err := migrations[v](raw); err != nil {
+			return st, false, fmt.Errorf("%s: migrating from version %d: %v", s.path, v, err)
+		}
+	}
+	delete(raw, "version")
+	if b, err = json.Marshal(raw); err != nil {
+		return st, false, err
+	}
+	if err := json.Unmarshal(b, &st); err != nil {
+		return st, false, fmt.Errorf("%s: %v", s.path, err)
+	}
+	st.Version = SchemaVersion
+	return st, version < SchemaVersion, nil
+}
+
+// write replaces the config with st, through a temporary file in the same
+// directory that's renamed over it.
+func (s *store) write(st state) error {
+	st.Version = SchemaVersion
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir, name := filepath.Split(s.path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, "."+name+".")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err := writeAndSync(f, b); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if fi, err := os.Stat(s.path); err == nil {
+		s.noteStat(fi)
+	}
+	return nil
+}
+
+func writeAndSync(f *os.File, b []byte) error {
+	err := f.Chmod(configMode)
+	if err == nil {
+		_, err = f.Write(b)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// backup keeps a copy of a config from before it was migrated, named for
+// its version.
+func (s *store) backup() error {
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	var versioned struct {
+		Version int `json:"version"`
+	}
+	json.Unmarshal(b, &versioned)
+	return ioutil.WriteFile(s.path+".v"+strconv.Itoa(versioned.Version), b, configMode)
+}
+
+// tighten removes any access to the config by anyone but its owner.
+func (s *store) tighten() error {
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if fi.Mode().Perm()&^configMode == 0 {
+		return nil
+	}
+	log.WithFields(log.Fields{
+		"file": s.path,
+		"mode": fi.Mode().Perm(),
+	}).Warn("Config was readable by others, restricting it to its owner")
+	return os.Chmod(s.path, configMode)
+}
+
+func (s *store) noteStat(fi os.FileInfo) {
+	s.statMu.Lock()
+	s.modTime, s.size = fi.ModTime(), fi.Size()
+	s.checked = time.Now()
+	s.statMu.Unlock()
+}
+
+// changed reports whether the file looks to have been replaced since it was
+// last read or written here, checking at most once every refreshInterval.
+func (s *store) changed() bool {
+	s.statMu.Lock()
+	defer s.statMu.Unlock()
+	if time.Since(s.checked) < refreshInterval {
+		return false
+	}
+	s.checked = time.Now()
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return false
+	}
+	return !fi.ModTime().Equal(s.modTime) || fi.Size() != s.size
+}
+
+// update applies change to the latest config on disk and saves it, holding
+// the lock so no other palette can change it in between. Palette's copy is
+// only replaced once the change is saved.
+func (p *Palette) update(change func(*state) error) error {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+	unlock, err := lockFile(p.store.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	st, _, err := p.store.read()
+	if err != nil {
+		return err
+	}
+	if err := change(&st); err != nil {
+		return err
+	}
+	if err := p.store.write(st); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.state = st
+	p.mu.Unlock()
+	return nil
+}
+
+// refresh picks up changes another palette has made to the config, such as
+// tokens created from the command line while the server runs. Writes are
+// atomic, so this doesn't need the lock.
+func (p *Palette) refresh() {
+	if !p.store.changed() {
+		return
+	}
+	st, _, err := p.store.read()
+	if err != nil {
+		log.WithField("error", err).Warn("Failed to reload config")
+		return
+	}
+	log.Debug("Reloaded config changed by another palette")
+	p.mu.Lock()
+	p.state = st
+	p.mu.Unlock()
+}
@@ -0,0 +1,231 @@
+package palette
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "palette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestStoreRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		bridges  map[string]bridgeConfig
+		scenes   []string
+		migrated bool
+	}{
+		{
+			"version 0",
+			`{"username": "abc", "scenes": {"night": {"name": "night", "lights": {}}}}`,
+			map[string]bridgeConfig{"": {Username: "abc"}},
+			[]string{"night"},
+			true,
+		},
+		{
+			"version 0 already paired",
+			`{"username": "abc", "bridges": {"001788fffe0a0b0c": {"username": "abc", "address": "10.0.0.2"}}}`,
+			map[string]bridgeConfig{"001788fffe0a0b0c": {Username: "abc", Address: "10.0.0.2"}},
+			nil,
+			true,
+		},
+		{
+			"version 0 without a user",
+			`{}`,
+			map[string]bridgeConfig{},
+			nil,
+			true,
+		},
+		{
+			"version 1",
+			`{"version": 1, "bridges": {"001788fffe0a0b0c": {"username": "abc"}}}`,
+			map[string]bridgeConfig{"001788fffe0a0b0c": {Username: "abc"}},
+			nil,
+			true,
+		},
+		{
+			"version 2",
+			`{"version": 2, "bridges": {"001788fffe0a0b0c": {"username": "abc"}}, "scenes": {"day": {"name": "day", "lights": {}}}}`,
+			map[string]bridgeConfig{"001788fffe0a0b0c": {Username: "abc"}},
+			[]string{"day"},
+			true,
+		},
+		{
+			"current",
+			`{"version": 3, "bridges": {"001788fffe0a0b0c": {"username": "abc"}}}`,
+			map[string]bridgeConfig{"001788fffe0a0b0c": {Username: "abc"}},
+			nil,
+			false,
+		},
+	}
+	for _, test := range tests {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		s := newStore(dir)
+		if err := ioutil.WriteFile(s.path, []byte(test.config), configMode); err != nil {
+			t.Fatal(err)
+		}
+		st, migrated, err := s.read()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if migrated != test.migrated {
+			t.Errorf("%s: migrated is %t, want %t", test.name, migrated, test.migrated)
+		}
+		if st.Version != SchemaVersion {
+			t.Errorf("%s: version %d, want %d", test.name, st.Version, SchemaVersion)
+		}
+		if !reflect.DeepEqual(st.Bridges, test.bridges) {
+			t.Errorf("%s: bridges %v, want %v", test.name, st.Bridges, test.bridges)
+		}
+		var scenes []string
+		for name := range st.Scenes {
+			scenes = append(scenes, name)
+		}
+		if !reflect.DeepEqual(scenes, test.scenes) {
+			t.Errorf("%s: scenes %v, want %v", test.name, scenes, test.scenes)
+		}
+	}
+}
+
+func TestStoreReadInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"newer version", `{"version": 4}`},
+		{"negative version", `{"version": -1}`},
+		{"invalid version", `{"version": "3"}`},
+		{"not JSON", `version: 3`},
+		{"invalid user", `{"username": 7}`},
+		{"invalid bridges", `{"version": 3, "bridges": []}`},
+	}
+	for _, test := range tests {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		s := newStore(dir)
+		if err := ioutil.WriteFile(s.path, []byte(test.config), configMode); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := s.read(); err == nil {
+			t.Errorf("%s: read %s, want an error", test.name, test.config)
+		}
+	}
+}
+
+func TestStoreReadMissing(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	st, migrated, err := newStore(dir).read()
+	if err != nil || migrated {
+		t.Fatalf("got migrated %t, %v; want false, nil", migrated, err)
+	}
+	if !reflect.DeepEqual(st, newState()) {
+		t.Errorf("got %+v, want an empty config", st)
+	}
+}
+
+func TestStoreWrite(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	s := newStore(dir)
+	// An older file, readable by others, is replaced whole.
+	if err := ioutil.WriteFile(s.path, []byte(`{"username": "old"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	st := newState()
+	st.Version = 1
+	st.Bridges["001788fffe0a0b0c"] = bridgeConfig{Username: "abc"}
+	st.LightSets["upstairs"] = []string{"1", "2"}
+	if err := s.write(st); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != configMode {
+		t.Errorf("mode %v, want %v", mode, os.FileMode(configMode))
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		t.Errorf("%s holds %v, want only %s", dir, names, CONFIGFILE)
+	}
+	if s.changed() {
+		t.Error("changed after its own write")
+	}
+
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versioned struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(b, &versioned); err != nil || versioned.Version != SchemaVersion {
+		t.Errorf("wrote version %d, %v; want %d", versioned.Version, err, SchemaVersion)
+	}
+	read, migrated, err := s.read()
+	if err != nil || migrated {
+		t.Fatalf("got migrated %t, %v; want false, nil", migrated, err)
+	}
+	st.Version = SchemaVersion
+	if !reflect.DeepEqual(read, st) {
+		t.Errorf("read %+v, want %+v", read, st)
+	}
+}
+
+func TestLoadFromConfigMigrates(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, CONFIGFILE)
+	old := []byte(`{"username": "abc"}`)
+	if err := ioutil.WriteFile(path, old, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFromConfig(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := ioutil.ReadFile(path + ".v0")
+	if err != nil || string(backup) != string(old) {
+		t.Errorf("backup is %q, %v; want %q", backup, err, old)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]interface{}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved["version"] != float64(SchemaVersion) || saved["username"] != nil {
+		t.Errorf("saved %s, want a version %d config", b, SchemaVersion)
+	}
+	want := map[string]interface{}{"": map[string]interface{}{"username": "abc"}}
+	if !reflect.DeepEqual(saved["bridges"], want) {
+		t.Errorf("saved bridges %v, want %v", saved["bridges"], want)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != configMode {
+		t.Errorf("config mode is %v, %v; want %v", fi.Mode().Perm(), err, os.FileMode(configMode))
+	}
+}
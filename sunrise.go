@@ -0,0 +1,212 @@
+package palette
+
+import (
+	"sync"
+	"time"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	DefaultSunriseDuration = 30 * time.Minute
+
+	// Each leg of a sunrise is a single bridge transition. Keeping legs short
+	// keeps the color path close to the keyframes and well within the range of
+	// TransitionTime.
+	sunriseMaxStep = time.Minute
+)
+
+type sunriseKeyframe struct {
+	at         float64
+	xy         [2]float64
+	brightness float64
+}
+
+// The sunrise follows the sky: deep red, through orange and warm white, to a
+// bright neutral white. Colors are given as CIE xy so that every leg can be
+// interpolated in the same color mode.
+var sunriseKeyframes = []sunriseKeyframe{
+	{at: 0, xy: [2]float64{0.675, 0.322}, brightness: 1},
+	{at: 0.3, xy: [2]float64{0.640, 0.330}, brightness: 50},
+	{at: 0.55, xy: [2]float64{0.570, 0.400}, brightness: 120},
+	{at: 0.8, xy: [2]float64{0.502, 0.415}, brightness: 200},
+	{at: 1, xy: [2]float64{0.4596, 0.4105}, brightness: 254},
+}
+
+func sunriseState(frac float64) hue.LightState {
+	prev, next := sunriseKeyframes[0], sunriseKeyframes[len(sunriseKeyframes)-1]
+	for i := 1; i < len(sunriseKeyframes); i++ {
+		if frac <= sunriseKeyframes[i].at {
+			prev, next = sunriseKeyframes[i-1], sunriseKeyframes[i]
+			break
+		}
+	}
+	t := 1.0
+	if next.at > prev.at {
+		t = (frac - prev.at) / (next.at - prev.at)
+	}
+	bri := uint8(prev.brightness + t*(next.brightness-prev.brightness) + 0.5)
+	return hue.LightState{
+		Brightness: &bri,
+		XY: []float64{
+			prev.xy[0] + t*(next.xy[0]-prev.xy[0]),
+			prev.xy[1] + t*(next.xy[1]-prev.xy[1]),
+		},
+	}
+}
+
+const (
+	SunriseScheduled = "scheduled"
+	SunriseRunning   = "running"
+	SunriseFinished  = "finished"
+	SunriseCancelled = "cancelled"
+)
+
+// Sunrise gradually wakes the selected lights from off to full brightness.
+type Sunrise struct {
+	Lights   []string
+	Start    time.Time
+	Duration time.Duration
+
+	palette  *Palette
+	mu       sync.Mutex
+	state    string
+	progress float64
+	cancel   chan struct{}
+	done     chan struct{}
+}
+
+type SunriseStatus struct {
+	State    string    `json:"state"`
+	Lights   []string  `json:"lights,omitempty"`
+	Start    time.Time `json:"start"`
+	Duration string    `json:"duration"`
+	Progress float64   `json:"progress"`
+}
+
+func (p *Palette) NewSunrise(lights []string, start time.Time, duration time.Duration) *Sunrise {
+	if duration <= 0 {
+		duration = DefaultSunriseDuration
+	}
+	return &Sunrise{
+		Lights:   lights,
+		Start:    start,
+		Duration: duration,
+		palette:  p,
+		state:    SunriseScheduled,
+		cancel:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (s *Sunrise) Run() {
+	go s.run()
+}
+
+// Cancel stops the sunrise, leaving the lights wherever they have reached.
+func (s *Sunrise) Cancel() {
+	s.mu.Lock()
+	if s.state == SunriseScheduled || s.state == SunriseRunning {
+		s.state = SunriseCancelled
+		close(s.cancel)
+	}
+	s.mu.Unlock()
+}
+
+func (s *Sunrise) Done() <-chan struct{} {
+	return s.done
+}
+
+// Affects reports whether any of the given lights are part of this sunrise.
+func (s *Sunrise) Affects(lights []hue.Light) bool {
+	return len(SelectLights(lights, s.Lights)) > 0
+}
+
+func (s *Sunrise) Status() SunriseStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SunriseStatus{
+		State:    s.state,
+		Lights:   s.Lights,
+		Start:    s.Start,
+		Duration: s.Duration.String(),
+		Progress: s.progress,
+	}
+}
+
+func (s *Sunrise) setState(state string, progress float64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == SunriseCancelled {
+		return false
+	}
+	s.state = state
+	s.progress = progress
+	return true
+}
+
+func (s *Sunrise) wait(d time.Duration) bool {
+	select {
+	case <-s.cancel:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func (s *Sunrise) run() {
+	defer close(s.done)
+	if !s.wait(s.Start.Sub(time.Now())) || !s.setState(SunriseRunning, 0) {
+		return
+	}
+
+	lights, err := s.palette.GetLights()
+	if err != nil {
+		log.WithField("error", err).Warn("Sunrise failed to get lights")
+		s.setState(SunriseFinished, 0)
+		return
+	}
+	lights = SelectLights(lights, s.Lights)
+	log.WithFields(log.Fields{
+		"lights":   len(lights),
+		"duration": s.Duration,
+	}).Debug("Starting sunrise")
+
+	first := sunriseState(0)
+	first.On = boolPtr(true)
+	first.TransitionTime = uint16Ptr(0)
+	s.apply(lights, first)
+	s.palette.Emit(EventScheduleFired, ScheduleEvent{Schedule: "sunrise", Lights: LightIds(lights), State: &first})
+
+	steps := int((s.Duration + sunriseMaxStep - 1) / sunriseMaxStep)
+	step := s.Duration / time.Duration(steps)
+	for i := 1; i <= steps; i++ {
+		frac := float64(i) / float64(steps)
+		state := sunriseState(frac)
+		state.TransitionTime = uint16Ptr(uint16(step / (100 * time.Millisecond)))
+		s.apply(lights, state)
+		if !s.wait(step) || !s.setState(SunriseRunning, frac) {
+			log.Debug("Sunrise cancelled")
+			return
+		}
+	}
+	s.setState(SunriseFinished, 1)
+	log.Debug("Sunrise finished")
+}
+
+func (s *Sunrise) apply(lights []hue.Light, state hue.LightState) {
+	for err := range s.palette.SetGroupAs(Change{Caller: "sunrise", Endpoint: "sunrise"}, lights, []hue.LightState{state}) {
+		if err != nil {
+			log.WithField("error", err).Warn("Sunrise failed to set light state")
+		}
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func uint16Ptr(u uint16) *uint16 {
+	return &u
+}
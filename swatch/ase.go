@@ -0,0 +1,178 @@
+package swatch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"unicode/utf16"
+)
+
+// Adobe Swatch Exchange files are big-endian: a signature and version,
+// followed by a count of blocks. Only color entry blocks are read, so groups
+// are flattened.
+const (
+	aseSignature  = "ASEF"
+	aseColorEntry = 0x0001
+	aseNormal     = 2
+	// aseMaxBlock is far longer than any color entry, whose name is at most
+	// 65535 UTF-16 code units.
+	aseMaxBlock = 1 << 18
+)
+
+var errInvalidASE = errors.New("invalid Adobe Swatch Exchange file")
+
+func decodeASE(r io.Reader) ([]Color, error) {
+	var header struct {
+		Signature    [4]byte
+		Major, Minor uint16
+		Blocks       uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, errInvalidASE
+	}
+	if string(header.Signature[:]) != aseSignature {
+		return nil, errInvalidASE
+	}
+
+	// The block count and lengths come from the file, so nothing is
+	// allocated from them up front; a block's bytes are only kept as they're
+	// actually read.
+	var colors []Color
+	for i := uint32(0); i < header.Blocks; i++ {
+		var blockType uint16
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &blockType); err != nil {
+			return nil, errInvalidASE
+		}
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, errInvalidASE
+		}
+		if blockType != aseColorEntry {
+			if _, err := io.CopyN(ioutil.Discard, r, int64(length)); err != nil {
+				return nil, errInvalidASE
+			}
+			continue
+		}
+		if length > aseMaxBlock {
+			return nil, errInvalidASE
+		}
+		var block bytes.Buffer
+		if _, err := io.CopyN(&block, r, int64(length)); err != nil {
+			return nil, errInvalidASE
+		}
+		c, err := decodeASEColor(&block)
+		if err != nil {
+			return nil, err
+		}
+		colors = append(colors, c)
+	}
+	return colors, nil
+}
+
+func decodeASEColor(r io.Reader) (Color, error) {
+	var nameLength uint16
+	if err := binary.Read(r, binary.BigEndian, &nameLength); err != nil {
+		return Color{}, errInvalidASE
+	}
+	name := make([]uint16, nameLength)
+	if err := binary.Read(r, binary.BigEndian, name); err != nil {
+		return Color{}, errInvalidASE
+	}
+	for len(name) > 0 && name[len(name)-1] == 0 {
+		name = name[:len(name)-1]
+	}
+
+	var model [4]byte
+	if _, err := io.ReadFull(r, model[:]); err != nil {
+		return Color{}, errInvalidASE
+	}
+	var values []float32
+	switch string(model[:]) {
+	case "RGB ", "LAB ":
+		values = make([]float32, 3)
+	case "CMYK":
+		values = make([]float32, 4)
+	case "Gray":
+		values = make([]float32, 1)
+	default:
+		return Color{}, fmt.Errorf("unsupported swatch color model %q", model[:])
+	}
+	if err := binary.Read(r, binary.BigEndian, values); err != nil {
+		return Color{}, errInvalidASE
+	}
+
+	c := Color{Name: string(utf16.Decode(name))}
+	switch string(model[:]) {
+	case "RGB ":
+		c.R, c.G, c.B = clampByte(float64(values[0])), clampByte(float64(values[1])), clampByte(float64(values[2]))
+	case "CMYK":
+		k := 1 - float64(values[3])
+		c.R = clampByte((1 - float64(values[0])) * k)
+		c.G = clampByte((1 - float64(values[1])) * k)
+		c.B = clampByte((1 - float64(values[2])) * k)
+	case "Gray":
+		c.R = clampByte(float64(values[0]))
+		c.G, c.B = c.R, c.R
+	case "LAB ":
+		c.R, c.G, c.B = labToRGB(float64(values[0])*100, float64(values[1]), float64(values[2]))
+	}
+	return c, nil
+}
+
+func encodeASE(w io.Writer, colors []Color) error {
+	var buf bytes.Buffer
+	buf.WriteString(aseSignature)
+	binary.Write(&buf, binary.BigEndian, []uint16{1, 0})
+	binary.Write(&buf, binary.BigEndian, uint32(len(colors)))
+
+	for _, c := range colors {
+		name := utf16.Encode([]rune(c.Name))
+		name = append(name, 0)
+		var block bytes.Buffer
+		binary.Write(&block, binary.BigEndian, uint16(len(name)))
+		binary.Write(&block, binary.BigEndian, name)
+		block.WriteString("RGB ")
+		binary.Write(&block, binary.BigEndian, []float32{
+			float32(c.R) / 0xff,
+			float32(c.G) / 0xff,
+			float32(c.B) / 0xff,
+		})
+		binary.Write(&block, binary.BigEndian, uint16(aseNormal))
+
+		binary.Write(&buf, binary.BigEndian, uint16(aseColorEntry))
+		binary.Write(&buf, binary.BigEndian, uint32(block.Len()))
+		buf.Write(block.Bytes())
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// labToRGB converts a D50 CIE L*a*b* color, as stored by Adobe tools, to sRGB.
+func labToRGB(l, a, b float64) (uint8, uint8, uint8) {
+	fy := (l + 16) / 116
+	fx := fy + a/500
+	fz := fy - b/200
+	finv := func(t float64) float64 {
+		if t*t*t > 216.0/24389 {
+			return t * t * t
+		}
+		return (116*t - 16) * 27 / 24389
+	}
+	x, y, z := finv(fx)*0.96422, finv(fy), finv(fz)*0.82521
+
+	// Bradford-adapted D50 XYZ to linear sRGB
+	lr := 3.1338561*x - 1.6168667*y - 0.4906146*z
+	lg := -0.9787684*x + 1.9161415*y + 0.0334540*z
+	lb := 0.0719453*x - 0.2289914*y + 1.4052427*z
+	gamma := func(v float64) float64 {
+		if v <= 0.0031308 {
+			return 12.92 * v
+		}
+		return 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return clampByte(gamma(lr)), clampByte(gamma(lg)), clampByte(gamma(lb))
+}
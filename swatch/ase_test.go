@@ -0,0 +1,26 @@
+package swatch
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeASEMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"bad signature", "ASEX\x00\x01\x00\x00\x00\x00\x00\x01"},
+		// A huge block count and nothing after it must not be preallocated.
+		{"huge block count", "ASEF\x00\x01\x00\x00\xff\xff\xff\xff"},
+		{"huge color block", "ASEF\x00\x01\x00\x00\x00\x00\x00\x01\x00\x01\xff\xff\xff\xff"},
+		{"huge other block", "ASEF\x00\x01\x00\x00\x00\x00\x00\x01\xc0\x01\xff\xff\xff\xff"},
+		{"truncated block", "ASEF\x00\x01\x00\x00\x00\x00\x00\x01\x00\x01\x00\x00\x00\x10\x00\x01"},
+	}
+	for _, test := range tests {
+		if colors, err := Decode(strings.NewReader(test.input), ASE); err == nil {
+			t.Errorf("%s: decoded %v, want an error", test.name, colors)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package swatch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	cssProperty = regexp.MustCompile(`--([A-Za-z0-9_-]+)\s*:\s*([^;}]+)`)
+	cssRGB      = regexp.MustCompile(`^rgba?\(\s*(\d+)\s*[, ]\s*(\d+)\s*[, ]\s*(\d+)`)
+	cssNameChar = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// decodeCSS reads every custom property whose value is a hex or rgb() color,
+// ignoring the rest.
+func decodeCSS(r io.Reader) ([]Color, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var colors []Color
+	for _, match := range cssProperty.FindAllStringSubmatch(string(b), -1) {
+		c, ok := parseCSSColor(strings.TrimSpace(match[2]))
+		if !ok {
+			continue
+		}
+		c.Name = match[1]
+		colors = append(colors, c)
+	}
+	return colors, nil
+}
+
+func parseCSSColor(value string) (Color, bool) {
+	if strings.HasPrefix(value, "#") {
+		c, err := ParseHex(value)
+		return c, err == nil
+	}
+	m := cssRGB.FindStringSubmatch(strings.ToLower(value))
+	if m == nil {
+		return Color{}, false
+	}
+	var rgb [3]uint8
+	for i := range rgb {
+		v, err := strconv.ParseUint(m[i+1], 10, 8)
+		if err != nil {
+			return Color{}, false
+		}
+		rgb[i] = uint8(v)
+	}
+	return Color{R: rgb[0], G: rgb[1], B: rgb[2]}, true
+}
+
+func encodeCSS(w io.Writer, colors []Color) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, ":root {")
+	used := make(map[string]bool, len(colors))
+	for i, c := range colors {
+		name := cssName(c.Name, i)
+		if used[name] {
+			name = fmt.Sprintf("%s-%d", name, i+1)
+		}
+		used[name] = true
+		fmt.Fprintf(bw, "  --%s: %s;\n", name, c.Hex())
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func cssName(name string, i int) string {
+	name = strings.Trim(cssNameChar.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if name == "" {
+		return fmt.Sprintf("color-%d", i+1)
+	}
+	return name
+}
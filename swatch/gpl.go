@@ -0,0 +1,57 @@
+package swatch
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const gplHeader = "GIMP Palette"
+
+var errInvalidGPL = errors.New("invalid GIMP palette")
+
+func decodeGPL(r io.Reader) ([]Color, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != gplHeader {
+		return nil, errInvalidGPL
+	}
+	var colors []Color
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") ||
+			strings.HasPrefix(line, "Name:") || strings.HasPrefix(line, "Columns:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("invalid GIMP palette line %q", line)
+		}
+		var rgb [3]uint8
+		for i := range rgb {
+			v, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("invalid GIMP palette line %q", line)
+			}
+			rgb[i] = uint8(v)
+		}
+		colors = append(colors, Color{
+			Name: strings.Join(fields[3:], " "),
+			R:    rgb[0],
+			G:    rgb[1],
+			B:    rgb[2],
+		})
+	}
+	return colors, scanner.Err()
+}
+
+func encodeGPL(w io.Writer, colors []Color) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s\nName: palette\nColumns: %d\n#\n", gplHeader, len(colors))
+	for _, c := range colors {
+		fmt.Fprintf(bw, "%3d %3d %3d\t%s\n", c.R, c.G, c.B, c.Name)
+	}
+	return bw.Flush()
+}
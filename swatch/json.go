@@ -0,0 +1,37 @@
+package swatch
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// The JSON format is a plain array of hex color strings.
+
+func decodeJSON(r io.Reader) ([]Color, error) {
+	var hexes []string
+	if err := json.NewDecoder(r).Decode(&hexes); err != nil {
+		return nil, err
+	}
+	colors := make([]Color, len(hexes))
+	for i, hex := range hexes {
+		c, err := ParseHex(hex)
+		if err != nil {
+			return nil, err
+		}
+		colors[i] = c
+	}
+	return colors, nil
+}
+
+func encodeJSON(w io.Writer, colors []Color) error {
+	hexes := make([]string, len(colors))
+	for i, c := range colors {
+		hexes[i] = c.Hex()
+	}
+	b, err := json.MarshalIndent(hexes, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,48 @@
+package swatch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Paint.NET palettes hold one AARRGGBB hex color per line, with ';' comments.
+// The format has no names, so names are written as trailing comments.
+
+func decodePaintNET(r io.Reader) ([]Color, error) {
+	scanner := bufio.NewScanner(r)
+	var colors []Color
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		fields := strings.Fields(line)
+		v, err := strconv.ParseUint(fields[0], 16, 32)
+		if err != nil || len(fields[0]) != 8 {
+			return nil, fmt.Errorf("invalid Paint.NET palette line %q", line)
+		}
+		var name string
+		if len(fields) > 1 && strings.HasPrefix(fields[1], ";") {
+			name = strings.TrimSpace(strings.TrimPrefix(strings.Join(fields[1:], " "), ";"))
+		}
+		colors = append(colors, Color{Name: name, R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)})
+	}
+	return colors, scanner.Err()
+}
+
+func encodePaintNET(w io.Writer, colors []Color) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "; paint.net Palette File")
+	fmt.Fprintf(bw, "; Colors: %d\n", len(colors))
+	for _, c := range colors {
+		if c.Name != "" {
+			fmt.Fprintf(bw, "FF%02X%02X%02X ; %s\n", c.R, c.G, c.B, c.Name)
+		} else {
+			fmt.Fprintf(bw, "FF%02X%02X%02X\n", c.R, c.G, c.B)
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,145 @@
+// Package swatch reads and writes palettes in the file formats used by common
+// design tools.
+package swatch
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	ASE      = "ase"
+	GPL      = "gpl"
+	PaintNET = "paintnet"
+	CSS      = "css"
+	JSON     = "json"
+)
+
+// Formats lists every supported format name.
+var Formats = []string{ASE, GPL, PaintNET, CSS, JSON}
+
+var ErrUnknownFormat = errors.New("unknown palette format")
+
+// Color is a named sRGB color. It implements color.Color.
+type Color struct {
+	Name    string
+	R, G, B uint8
+}
+
+func (c Color) RGBA() (r, g, b, a uint32) {
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: 0xff}.RGBA()
+}
+
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// FromColor converts any color.Color to a named swatch color.
+func FromColor(name string, c color.Color) Color {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return Color{Name: name, R: rgba.R, G: rgba.G, B: rgba.B}
+}
+
+// ParseHex parses "#rgb" and "#rrggbb" colors, with or without the leading
+// '#'.
+func ParseHex(s string) (Color, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return Color{}, fmt.Errorf("invalid hex color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid hex color %q", s)
+	}
+	return Color{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v)}, nil
+}
+
+func Decode(r io.Reader, format string) ([]Color, error) {
+	switch format {
+	case ASE:
+		return decodeASE(r)
+	case GPL:
+		return decodeGPL(r)
+	case PaintNET:
+		return decodePaintNET(r)
+	case CSS:
+		return decodeCSS(r)
+	case JSON:
+		return decodeJSON(r)
+	}
+	return nil, ErrUnknownFormat
+}
+
+func Encode(w io.Writer, format string, colors []Color) error {
+	switch format {
+	case ASE:
+		return encodeASE(w, colors)
+	case GPL:
+		return encodeGPL(w, colors)
+	case PaintNET:
+		return encodePaintNET(w, colors)
+	case CSS:
+		return encodeCSS(w, colors)
+	case JSON:
+		return encodeJSON(w, colors)
+	}
+	return ErrUnknownFormat
+}
+
+// FormatFromFilename guesses a format from a file extension, returning "" if
+// the extension isn't recognized. Paint.NET palettes are plain ".txt" files.
+func FormatFromFilename(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ase":
+		return ASE
+	case ".gpl":
+		return GPL
+	case ".txt":
+		return PaintNET
+	case ".css":
+		return CSS
+	case ".json":
+		return JSON
+	}
+	return ""
+}
+
+func Extension(format string) string {
+	switch format {
+	case PaintNET:
+		return ".txt"
+	case ASE, GPL, CSS, JSON:
+		return "." + format
+	}
+	return ""
+}
+
+func ContentType(format string) string {
+	switch format {
+	case ASE:
+		return "application/octet-stream"
+	case CSS:
+		return "text/css; charset=utf-8"
+	case JSON:
+		return "application/json; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+func clampByte(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 0xff
+	}
+	return uint8(v*0xff + 0.5)
+}
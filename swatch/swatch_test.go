@@ -0,0 +1,167 @@
+package swatch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		format string
+		input  string
+		want   []Color
+	}{
+		{
+			GPL,
+			"GIMP Palette\nName: test\nColumns: 2\n#\n255   0   0\tRed\n  0 128 255\tSky Blue\n",
+			[]Color{{"Red", 255, 0, 0}, {"Sky Blue", 0, 128, 255}},
+		},
+		{
+			PaintNET,
+			"; paint.net Palette File\nFFFF0000 ; Red\nff0080ff\n",
+			[]Color{{"Red", 255, 0, 0}, {"", 0, 128, 255}},
+		},
+		{
+			CSS,
+			":root {\n  --red: #ff0000;\n  --sky: rgb(0, 128, 255);\n  --gap: 4px;\n}\n",
+			[]Color{{"red", 255, 0, 0}, {"sky", 0, 128, 255}},
+		},
+		{
+			CSS,
+			".a{--short:#f00}",
+			[]Color{{"short", 255, 0, 0}},
+		},
+		{
+			JSON,
+			`["#ff0000", "0080ff", "#fff"]`,
+			[]Color{{"", 255, 0, 0}, {"", 0, 128, 255}, {"", 255, 255, 255}},
+		},
+	}
+	for _, test := range tests {
+		got, err := Decode(strings.NewReader(test.input), test.format)
+		if err != nil {
+			t.Errorf("%s %q: %v", test.format, test.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s %q: got %v, want %v", test.format, test.input, got, test.want)
+		}
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		format string
+		input  string
+	}{
+		{GPL, "Not a palette\n255 0 0\n"},
+		{GPL, "GIMP Palette\n255 0\n"},
+		{GPL, "GIMP Palette\n256 0 0 Too bright\n"},
+		{PaintNET, "FF0000\n"},
+		{PaintNET, "FFGG0000\n"},
+		{JSON, `{"red": "#ff0000"}`},
+		{JSON, `["#ff00"]`},
+		{"sketch", ""},
+	}
+	for _, test := range tests {
+		if colors, err := Decode(strings.NewReader(test.input), test.format); err == nil {
+			t.Errorf("%s %q: decoded %v, want an error", test.format, test.input, colors)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	colors := []Color{{"Red", 255, 0, 0}, {"Sky Blue", 0, 128, 255}, {"Grey", 17, 17, 17}}
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		if err := Encode(&buf, format, colors); err != nil {
+			t.Errorf("%s: encode: %v", format, err)
+			continue
+		}
+		got, err := Decode(&buf, format)
+		if err != nil {
+			t.Errorf("%s: decode: %v", format, err)
+			continue
+		}
+		want := colors
+		switch format {
+		case JSON:
+			// JSON palettes have no names.
+			want = []Color{{"", 255, 0, 0}, {"", 0, 128, 255}, {"", 17, 17, 17}}
+		case CSS:
+			// CSS names are custom property names.
+			want = []Color{{"red", 255, 0, 0}, {"sky-blue", 0, 128, 255}, {"grey", 17, 17, 17}}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", format, got, want)
+		}
+	}
+}
+
+func TestDecodeASEModels(t *testing.T) {
+	block := func(name string, model string, values ...float32) []byte {
+		var b bytes.Buffer
+		var units []uint16
+		for _, r := range name {
+			units = append(units, uint16(r))
+		}
+		units = append(units, 0)
+		writeBE(&b, uint16(len(units)))
+		writeBE(&b, units)
+		b.WriteString(model)
+		writeBE(&b, values)
+		writeBE(&b, uint16(aseNormal))
+		var entry bytes.Buffer
+		writeBE(&entry, uint16(aseColorEntry))
+		writeBE(&entry, uint32(b.Len()))
+		entry.Write(b.Bytes())
+		return entry.Bytes()
+	}
+	var file bytes.Buffer
+	file.WriteString(aseSignature)
+	writeBE(&file, []uint16{1, 0})
+	writeBE(&file, uint32(4))
+	// A group start block, which is skipped.
+	writeBE(&file, uint16(0xc001))
+	writeBE(&file, uint32(2))
+	writeBE(&file, uint16(0))
+	file.Write(block("cyan", "CMYK", 1, 0, 0, 0))
+	file.Write(block("mid", "Gray", 0.5))
+	file.Write(block("white", "LAB ", 1, 0, 0))
+
+	got, err := Decode(&file, ASE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Color{{"cyan", 0, 255, 255}, {"mid", 128, 128, 128}, {"white", 255, 255, 255}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Color
+		ok    bool
+	}{
+		{"#ff8000", Color{R: 255, G: 128}, true},
+		{"FF8000", Color{R: 255, G: 128}, true},
+		{" #f80 ", Color{R: 255, G: 136}, true},
+		{"#ff80", Color{}, false},
+		{"#gg8000", Color{}, false},
+	}
+	for _, test := range tests {
+		got, err := ParseHex(test.input)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("ParseHex(%q) = %v, %v; want %v, ok %t", test.input, got, err, test.want, test.ok)
+		}
+	}
+}
+
+func writeBE(b *bytes.Buffer, v interface{}) {
+	binary.Write(b, binary.BigEndian, v)
+}
@@ -0,0 +1,168 @@
+package palette
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/BrianBland/go-hue"
+)
+
+// Token scopes, each allowing everything the previous one does.
+const (
+	ScopeRead    = "read"
+	ScopeControl = "control"
+	ScopeAdmin   = "admin"
+)
+
+var Scopes = []string{ScopeRead, ScopeControl, ScopeAdmin}
+
+var (
+	ErrUnknownToken = errors.New("Unknown token")
+	ErrTokenExists  = errors.New("A token with that name already exists")
+)
+
+// Token is an API token as stored in the config. Only a hash of the secret is
+// kept; the secret itself is shown once, when the token is created.
+type Token struct {
+	Name    string    `json:"name"`
+	Hash    string    `json:"hash,omitempty"`
+	Scope   string    `json:"scope"`
+	Lights  []string  `json:"lights,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+func ValidScope(scope string) error {
+	if scopeLevel(scope) < 0 {
+		return fmt.Errorf("unknown scope %q", scope)
+	}
+	return nil
+}
+
+func scopeLevel(scope string) int {
+	for i, s := range Scopes {
+		if s == scope {
+			return i
+		}
+	}
+	return -1
+}
+
+// Allows reports whether the token's scope includes scope.
+func (t Token) Allows(scope string) bool {
+	return scopeLevel(t.Scope) >= scopeLevel(scope) && scopeLevel(scope) >= 0
+}
+
+// AllowedLights returns the lights the token may see and change.
+func (t Token) AllowedLights(lights []hue.Light) []hue.Light {
+	return SelectLights(lights, t.Lights)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Tokens lists the configured tokens, without their hashes.
+func (p *Palette) Tokens() []Token {
+	p.refresh()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tokens := make([]Token, len(p.state.Tokens))
+	for i, token := range p.state.Tokens {
+		token.Hash = ""
+		tokens[i] = token
+	}
+	sort.Sort(byTokenName(tokens))
+	return tokens
+}
+
+// CreateToken adds a token and saves it to the config, returning its secret.
+// An empty light list lets the token use every light.
+func (p *Palette) CreateToken(name, scope string, lights []string) (string, Token, error) {
+	if err := ValidScope(scope); err != nil {
+		return "", Token{}, err
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", Token{}, err
+	}
+	secret := hex.EncodeToString(b)
+	token := Token{
+		Name:    name,
+		Hash:    hashSecret(secret),
+		Scope:   scope,
+		Lights:  lights,
+		Created: time.Now().UTC(),
+	}
+
+	err := p.update(func(st *state) error {
+		for _, existing := range st.Tokens {
+			if existing.Name == name {
+				return ErrTokenExists
+			}
+		}
+		st.Tokens = append(st.Tokens, token)
+		return nil
+	})
+	if err != nil {
+		return "", Token{}, err
+	}
+	token.Hash = ""
+	return secret, token, nil
+}
+
+func (p *Palette) RevokeToken(name string) error {
+	return p.update(func(st *state) error {
+		for i, token := range st.Tokens {
+			if token.Name == name {
+				st.Tokens = append(st.Tokens[:i], st.Tokens[i+1:]...)
+				return nil
+			}
+		}
+		return ErrUnknownToken
+	})
+}
+
+// AuthRequired reports whether any tokens exist. Until one is created the
+// server accepts every request.
+func (p *Palette) AuthRequired() bool {
+	p.refresh()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.state.Tokens) > 0
+}
+
+// Authenticate returns the token whose secret is given.
+func (p *Palette) Authenticate(secret string) (Token, bool) {
+	hash := []byte(hashSecret(secret))
+	p.refresh()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, token := range p.state.Tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(token.Hash)) == 1 {
+			token.Hash = ""
+			return token, true
+		}
+	}
+	return Token{}, false
+}
+
+type byTokenName []Token
+
+func (s byTokenName) Len() int {
+	return len(s)
+}
+
+func (s byTokenName) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s byTokenName) Less(i, j int) bool {
+	return s[i].Name < s[j].Name
+}
@@ -0,0 +1,147 @@
+package palette
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BrianBland/go-hue"
+)
+
+func TestHashSecret(t *testing.T) {
+	tests := []struct {
+		secret, hash string
+	}{
+		{"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+	for _, test := range tests {
+		if hash := hashSecret(test.secret); hash != test.hash {
+			t.Errorf("hashSecret(%q) = %s, want %s", test.secret, hash, test.hash)
+		}
+	}
+}
+
+func TestTokenAllows(t *testing.T) {
+	tests := []struct {
+		token, scope string
+		allowed      bool
+	}{
+		{ScopeRead, ScopeRead, true},
+		{ScopeRead, ScopeControl, false},
+		{ScopeRead, ScopeAdmin, false},
+		{ScopeControl, ScopeRead, true},
+		{ScopeControl, ScopeControl, true},
+		{ScopeControl, ScopeAdmin, false},
+		{ScopeAdmin, ScopeRead, true},
+		{ScopeAdmin, ScopeControl, true},
+		{ScopeAdmin, ScopeAdmin, true},
+		{ScopeAdmin, "root", false},
+		{"root", ScopeRead, false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		if allowed := (Token{Scope: test.token}).Allows(test.scope); allowed != test.allowed {
+			t.Errorf("%q token allows %q: %t, want %t", test.token, test.scope, allowed, test.allowed)
+		}
+	}
+}
+
+func TestTokenAllowedLights(t *testing.T) {
+	lights := []hue.Light{
+		{Id: "1", Name: "living room"},
+		{Id: "2", Name: "living lamp"},
+		{Id: "3", Name: "kitchen"},
+	}
+	tests := []struct {
+		patterns []string
+		want     []string
+	}{
+		{nil, []string{"1", "2", "3"}},
+		{[]string{"3"}, []string{"3"}},
+		{[]string{"living*"}, []string{"1", "2"}},
+		{[]string{"kitchen", "1"}, []string{"1", "3"}},
+		{[]string{"garage"}, nil},
+	}
+	for _, test := range tests {
+		var ids []string
+		for _, light := range (Token{Lights: test.patterns}).AllowedLights(lights) {
+			ids = append(ids, light.Id)
+		}
+		if !reflect.DeepEqual(ids, test.want) {
+			t.Errorf("token for %v allows %v, want %v", test.patterns, ids, test.want)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "palette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p, err := LoadFromConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.AuthRequired() {
+		t.Error("auth required without any tokens")
+	}
+	if _, _, err := p.CreateToken("bad", "root", nil); err == nil {
+		t.Error("created a token with an unknown scope")
+	}
+
+	secret, token, err := p.CreateToken("kitchen", ScopeControl, []string{"3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Hash != "" || strings.Contains(secret, token.Name) || len(secret) != 48 {
+		t.Errorf("created %+v with secret %q", token, secret)
+	}
+	if _, _, err := p.CreateToken("kitchen", ScopeRead, nil); err != ErrTokenExists {
+		t.Errorf("creating a second kitchen token: %v, want %v", err, ErrTokenExists)
+	}
+	if !p.AuthRequired() {
+		t.Error("auth not required with a token")
+	}
+
+	// The config keeps only the hash, and a fresh load authenticates against it.
+	b, err := ioutil.ReadFile(p.store.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), secret) || !strings.Contains(string(b), hashSecret(secret)) {
+		t.Errorf("config holds %s", b)
+	}
+	loaded, err := LoadFromConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticated, ok := loaded.Authenticate(secret)
+	if !ok || !reflect.DeepEqual(authenticated, token) {
+		t.Errorf("authenticated %+v, %t; want %+v", authenticated, ok, token)
+	}
+	for _, wrong := range []string{"", hashSecret(secret), secret[1:], strings.ToUpper(secret)} {
+		if _, ok := loaded.Authenticate(wrong); ok {
+			t.Errorf("authenticated %q", wrong)
+		}
+	}
+
+	if tokens := loaded.Tokens(); len(tokens) != 1 || tokens[0].Hash != "" {
+		t.Errorf("listed %+v", tokens)
+	}
+	if err := loaded.RevokeToken("garage"); err != ErrUnknownToken {
+		t.Errorf("revoking an unknown token: %v, want %v", err, ErrUnknownToken)
+	}
+	if err := loaded.RevokeToken("kitchen"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.Authenticate(secret); ok {
+		t.Error("authenticated a revoked token")
+	}
+	if loaded.AuthRequired() {
+		t.Error("auth required after revoking every token")
+	}
+}
@@ -0,0 +1,528 @@
+package palette
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BrianBland/go-hue"
+	log "github.com/Sirupsen/logrus"
+)
+
+// Webhook event types.
+const (
+	EventLightChanged      = "light.changed"
+	EventSchemeApplied     = "scheme.applied"
+	EventSceneRecalled     = "scene.recalled"
+	EventBridgeUnreachable = "bridge.unreachable"
+	EventScheduleFired     = "schedule.fired"
+	// EventPing is only sent by TestWebhook.
+	EventPing = "ping"
+)
+
+var EventTypes = []string{
+	EventLightChanged,
+	EventSchemeApplied,
+	EventSceneRecalled,
+	EventBridgeUnreachable,
+	EventScheduleFired,
+}
+
+const (
+	DefaultWebhookAttempts    = 5
+	DefaultWebhookBackoff     = 2 * time.Second
+	DefaultWebhookTimeout     = 10 * time.Second
+	DefaultWebhookConcurrency = 8
+	DefaultWebhookQueueSize   = 1000
+	DefaultDeliveryLogSize    = 500
+
+	// statusTooManyRequests isn't defined by net/http in Go 1.4.
+	statusTooManyRequests = 429
+
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the body,
+	// keyed with the webhook's secret.
+	SignatureHeader = "X-Palette-Signature"
+	EventHeader     = "X-Palette-Event"
+	DeliveryHeader  = "X-Palette-Delivery"
+)
+
+var (
+	ErrUnknownWebhook = errors.New("Unknown webhook")
+	ErrWebhookBusy    = errors.New("Too many webhook deliveries waiting")
+)
+
+// Webhook is a URL that events are POSTed to as JSON. An empty event list
+// subscribes to every event.
+type Webhook struct {
+	Name    string    `json:"name"`
+	URL     string    `json:"url"`
+	Events  []string  `json:"events,omitempty"`
+	Secret  string    `json:"secret,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Wants reports whether the webhook subscribes to events of type typ.
+func (w Webhook) Wants(typ string) bool {
+	if len(w.Events) == 0 {
+		return typ != EventPing
+	}
+	for _, event := range w.Events {
+		if event == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateWebhook checks the URL and event types of a webhook.
+func ValidateWebhook(w Webhook) error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http or https URL", w.URL)
+	}
+	for _, event := range w.Events {
+		if !validEvent(event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	return nil
+}
+
+func validEvent(typ string) bool {
+	for _, t := range EventTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the body POSTed to webhooks.
+type Event struct {
+	Id   string      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// WebhookDelivery records one attempt to deliver an event.
+type WebhookDelivery struct {
+	Id       string    `json:"id"`
+	Webhook  string    `json:"webhook"`
+	Event    string    `json:"event"`
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Attempt  int       `json:"attempt"`
+	Status   int       `json:"status,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Duration float64   `json:"durationSeconds"`
+	// Retry is when the next attempt will be made, if any.
+	Retry *time.Time `json:"retry,omitempty"`
+}
+
+func (d WebhookDelivery) OK() bool {
+	return d.Error == "" && d.Status >= 200 && d.Status < 300
+}
+
+// ScheduleEvent is the data of schedule.fired events.
+type ScheduleEvent struct {
+	Schedule string          `json:"schedule"`
+	Lights   []string        `json:"lights"`
+	State    *hue.LightState `json:"state,omitempty"`
+}
+
+// LightIds returns the IDs of lights, for events.
+func LightIds(lights []hue.Light) []string {
+	ids := make([]string, len(lights))
+	for i, light := range lights {
+		ids[i] = light.Id
+	}
+	return ids
+}
+
+// Webhooks lists the configured webhooks, without their secrets.
+func (p *Palette) Webhooks() []Webhook {
+	p.refresh()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	webhooks := make([]Webhook, len(p.state.Webhooks))
+	for i, webhook := range p.state.Webhooks {
+		webhook.Secret = ""
+		webhooks[i] = webhook
+	}
+	sort.Sort(byWebhookName(webhooks))
+	return webhooks
+}
+
+func (p *Palette) webhook(name string) (Webhook, bool) {
+	p.refresh()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, webhook := range p.state.Webhooks {
+		if webhook.Name == name {
+			return webhook, true
+		}
+	}
+	return Webhook{}, false
+}
+
+// SaveWebhook adds or replaces the named webhook, returning it with its
+// secret. Without a secret, a replaced webhook keeps its old one and a new
+// webhook gets a random one.
+func (p *Palette) SaveWebhook(w Webhook) (Webhook, error) {
+	if err := ValidateWebhook(w); err != nil {
+		return Webhook{}, err
+	}
+	var secret string
+	if w.Secret == "" {
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			return Webhook{}, err
+		}
+		secret = hex.EncodeToString(b)
+	}
+	err := p.update(func(st *state) error {
+		for i, existing := range st.Webhooks {
+			if existing.Name != w.Name {
+				continue
+			}
+			w.Created = existing.Created
+			if w.Secret == "" {
+				w.Secret = existing.Secret
+			}
+			st.Webhooks[i] = w
+			return nil
+		}
+		if w.Secret == "" {
+			w.Secret = secret
+		}
+		w.Created = time.Now().UTC()
+		st.Webhooks = append(st.Webhooks, w)
+		return nil
+	})
+	return w, err
+}
+
+func (p *Palette) DeleteWebhook(name string) error {
+	return p.update(func(st *state) error {
+		for i, webhook := range st.Webhooks {
+			if webhook.Name == name {
+				st.Webhooks = append(st.Webhooks[:i], st.Webhooks[i+1:]...)
+				return nil
+			}
+		}
+		return ErrUnknownWebhook
+	})
+}
+
+// WantsEvent reports whether events of type typ would be delivered anywhere,
+// so that they needn't be looked for otherwise.
+func (p *Palette) WantsEvent(typ string) bool {
+	if p.WebhookSender() == nil {
+		return false
+	}
+	p.refresh()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, webhook := range p.state.Webhooks {
+		if webhook.Wants(typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// Emit sends an event to every webhook subscribed to it, in the background.
+// Without a webhook sender, events go nowhere.
+func (p *Palette) Emit(typ string, data interface{}) {
+	sender := p.WebhookSender()
+	if sender == nil {
+		return
+	}
+	p.refresh()
+	p.mu.Lock()
+	var webhooks []Webhook
+	for _, webhook := range p.state.Webhooks {
+		if webhook.Wants(typ) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	p.mu.Unlock()
+	if len(webhooks) == 0 {
+		return
+	}
+	id, err := newEventId()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"event": typ,
+			"error": err,
+		}).Error("Failed to create an event ID, dropping the event")
+		return
+	}
+	event := Event{Id: id, Type: typ, Time: time.Now().UTC(), Data: data}
+	for _, webhook := range webhooks {
+		sender.Send(webhook, event)
+	}
+}
+
+// TestWebhook sends a ping event to the named webhook once, without
+// retrying, and returns how it went.
+func (p *Palette) TestWebhook(name string) (WebhookDelivery, error) {
+	webhook, ok := p.webhook(name)
+	if !ok {
+		return WebhookDelivery{}, ErrUnknownWebhook
+	}
+	sender := p.WebhookSender()
+	if sender == nil {
+		sender = NewWebhookSender()
+	}
+	id, err := newEventId()
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	event := Event{
+		Id:   id,
+		Type: EventPing,
+		Time: time.Now().UTC(),
+		Data: map[string]string{"webhook": name},
+	}
+	return sender.attempt(webhook, event, 1, false), nil
+}
+
+// SetWebhookSender makes Emit deliver events through s, which retries with
+// the webhooks' settings at the time.
+func (p *Palette) SetWebhookSender(s *WebhookSender) {
+	if s != nil && s.Lookup == nil {
+		s.Lookup = p.webhook
+	}
+	p.mu.Lock()
+	p.webhooks = s
+	p.mu.Unlock()
+}
+
+func (p *Palette) WebhookSender() *WebhookSender {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.webhooks
+}
+
+func newEventId() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature of body for SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookSender delivers events, retrying failed deliveries with exponential
+// backoff, and keeps a log of the most recent attempts in memory.
+type WebhookSender struct {
+	Client      *http.Client
+	MaxAttempts int
+	Backoff     time.Duration
+	// Lookup, if set, returns a webhook's current settings. Retries use them,
+	// and stop once the webhook is gone or no longer wants the event.
+	Lookup func(name string) (Webhook, bool)
+
+	slots chan struct{}
+
+	mu      sync.Mutex
+	pending int
+	log     []WebhookDelivery
+	logSize int
+	next    int
+}
+
+func NewWebhookSender() *WebhookSender {
+	return &WebhookSender{
+		Client:      &http.Client{Timeout: DefaultWebhookTimeout},
+		MaxAttempts: DefaultWebhookAttempts,
+		Backoff:     DefaultWebhookBackoff,
+		slots:       make(chan struct{}, DefaultWebhookConcurrency),
+		logSize:     DefaultDeliveryLogSize,
+	}
+}
+
+// Send delivers an event to a webhook in the background, a few deliveries at
+// a time. Events are dropped, and the drop logged, while too many deliveries
+// are waiting.
+func (s *WebhookSender) Send(webhook Webhook, event Event) {
+	s.mu.Lock()
+	full := s.pending >= DefaultWebhookQueueSize
+	if !full {
+		s.pending++
+	}
+	s.mu.Unlock()
+	if full {
+		// The drop is worth recording even without an ID of its own.
+		id, _ := newEventId()
+		s.record(WebhookDelivery{
+			Id:      id,
+			Webhook: webhook.Name,
+			Event:   event.Id,
+			Type:    event.Type,
+			Time:    time.Now().UTC(),
+			Error:   ErrWebhookBusy.Error(),
+		})
+		return
+	}
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			s.pending--
+			s.mu.Unlock()
+		}()
+		backoff := s.Backoff
+		for attempt := 1; ; attempt++ {
+			s.slots <- struct{}{}
+			delivery := s.attempt(webhook, event, attempt, attempt < s.MaxAttempts)
+			<-s.slots
+			if delivery.Retry == nil {
+				return
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+			if s.Lookup != nil {
+				current, ok := s.Lookup(webhook.Name)
+				if !ok || !current.Wants(event.Type) {
+					return
+				}
+				webhook = current
+			}
+		}
+	}()
+}
+
+// attempt POSTs an event once and logs the delivery. Retry is set on the
+// delivery if it failed in a way worth retrying and retry is true.
+func (s *WebhookSender) attempt(webhook Webhook, event Event, attempt int, retry bool) WebhookDelivery {
+	delivery := WebhookDelivery{
+		Webhook: webhook.Name,
+		Event:   event.Id,
+		Type:    event.Type,
+		Time:    time.Now().UTC(),
+		Attempt: attempt,
+	}
+	defer func() {
+		s.record(delivery)
+	}()
+	id, err := newEventId()
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	delivery.Id = id
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "palette")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.Id)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+
+	start := time.Now()
+	resp, err := s.Client.Do(req)
+	delivery.Duration = time.Since(start).Seconds()
+	temporary := true
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		resp.Body.Close()
+		delivery.Status = resp.StatusCode
+		if !delivery.OK() {
+			delivery.Error = "receiver responded " + strconv.Itoa(resp.StatusCode)
+			temporary = temporaryStatus(resp.StatusCode)
+		}
+	}
+	if delivery.OK() {
+		return delivery
+	}
+	if retry && temporary {
+		next := time.Now().UTC().Add(s.Backoff << uint(attempt-1))
+		delivery.Retry = &next
+	}
+	log.WithFields(log.Fields{
+		"webhook": webhook.Name,
+		"event":   event.Type,
+		"attempt": attempt,
+		"error":   delivery.Error,
+	}).Warn("Webhook delivery failed")
+	return delivery
+}
+
+// temporaryStatus reports whether a failed delivery's response status is
+// worth retrying. Other client errors won't go away by trying again.
+func temporaryStatus(code int) bool {
+	return code >= 500 || code == statusTooManyRequests || code == http.StatusRequestTimeout
+}
+
+func (s *WebhookSender) record(delivery WebhookDelivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.log) < s.logSize {
+		s.log = append(s.log, delivery)
+		return
+	}
+	s.log[s.next] = delivery
+	s.next = (s.next + 1) % s.logSize
+}
+
+// Deliveries returns the logged deliveries to the named webhook, or to every
+// webhook if name is empty, newest first, up to limit if it's positive.
+func (s *WebhookSender) Deliveries(name string, limit int) []WebhookDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deliveries []WebhookDelivery
+	for i := len(s.log) - 1; i >= 0; i-- {
+		delivery := s.log[(s.next+i)%len(s.log)]
+		if name != "" && delivery.Webhook != name {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+		if limit > 0 && len(deliveries) == limit {
+			break
+		}
+	}
+	return deliveries
+}
+
+type byWebhookName []Webhook
+
+func (s byWebhookName) Len() int {
+	return len(s)
+}
+
+func (s byWebhookName) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s byWebhookName) Less(i, j int) bool {
+	return s[i].Name < s[j].Name
+}
@@ -0,0 +1,154 @@
+package palette
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret, body, signature string
+	}{
+		{"", "", "sha256=b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad"},
+		{"key", "The quick brown fox jumps over the lazy dog", "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{"It's a Secret to Everybody", "Hello, World!", "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"},
+	}
+	for _, test := range tests {
+		if signature := Sign(test.secret, []byte(test.body)); signature != test.signature {
+			t.Errorf("Sign(%q, %q) = %s, want %s", test.secret, test.body, signature, test.signature)
+		}
+	}
+}
+
+func TestTemporaryStatus(t *testing.T) {
+	tests := []struct {
+		status    int
+		temporary bool
+	}{
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusNotFound, false},
+		{http.StatusGone, false},
+		{http.StatusRequestTimeout, true},
+		{statusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, test := range tests {
+		if temporary := temporaryStatus(test.status); temporary != test.temporary {
+			t.Errorf("temporaryStatus(%d) = %t, want %t", test.status, temporary, test.temporary)
+		}
+	}
+}
+
+func TestWebhookAttempt(t *testing.T) {
+	tests := []struct {
+		status  int
+		retry   bool
+		retried bool
+	}{
+		{http.StatusOK, true, false},
+		{http.StatusNoContent, true, false},
+		{http.StatusNotFound, true, false},
+		{statusTooManyRequests, true, true},
+		{http.StatusRequestTimeout, true, true},
+		{http.StatusServiceUnavailable, true, true},
+		// The last attempt isn't retried, however it fails.
+		{http.StatusServiceUnavailable, false, false},
+	}
+	webhook := Webhook{Name: "chat", Secret: "shh"}
+	event := Event{Id: "0123456789abcdef", Type: EventSceneRecalled, Time: time.Now().UTC(), Data: map[string]string{"scene": "evening"}}
+	for _, test := range tests {
+		var header http.Header
+		var body []byte
+		receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			header = r.Header
+			body, _ = ioutil.ReadAll(r.Body)
+			rw.WriteHeader(test.status)
+		}))
+		webhook.URL = receiver.URL
+		sender := NewWebhookSender()
+		delivery := sender.attempt(webhook, event, 2, test.retry)
+		receiver.Close()
+
+		ok := test.status < 300
+		if delivery.Status != test.status || delivery.OK() != ok || (delivery.Error == "") != ok {
+			t.Errorf("%d: delivery %+v", test.status, delivery)
+		}
+		if (delivery.Retry != nil) != test.retried {
+			t.Errorf("%d, retry %t: retry at %v, want retried %t", test.status, test.retry, delivery.Retry, test.retried)
+		}
+		if delivery.Id == "" || delivery.Event != event.Id || delivery.Attempt != 2 || delivery.Webhook != webhook.Name {
+			t.Errorf("%d: delivery %+v", test.status, delivery)
+		}
+		if header.Get(SignatureHeader) != Sign(webhook.Secret, body) ||
+			header.Get(EventHeader) != event.Type || header.Get(DeliveryHeader) != event.Id {
+			t.Errorf("%d: sent headers %v", test.status, header)
+		}
+		var sent Event
+		if err := json.Unmarshal(body, &sent); err != nil || sent.Id != event.Id || sent.Type != event.Type {
+			t.Errorf("%d: sent %s, %v", test.status, body, err)
+		}
+		if logged := sender.Deliveries("", 0); len(logged) != 1 || logged[0].Id != delivery.Id {
+			t.Errorf("%d: logged %+v", test.status, logged)
+		}
+	}
+}
+
+func TestDeliveryLog(t *testing.T) {
+	sender := NewWebhookSender()
+	sender.logSize = 4
+	for i := 1; i <= 6; i++ {
+		name := "even"
+		if i%2 == 1 {
+			name = "odd"
+		}
+		sender.record(WebhookDelivery{Id: strconv.Itoa(i), Webhook: name})
+	}
+	tests := []struct {
+		name  string
+		limit int
+		ids   []string
+	}{
+		// Only the newest four are kept, newest first.
+		{"", 0, []string{"6", "5", "4", "3"}},
+		{"", 2, []string{"6", "5"}},
+		{"odd", 0, []string{"5", "3"}},
+		{"even", 1, []string{"6"}},
+		{"other", 0, nil},
+	}
+	for _, test := range tests {
+		var ids []string
+		for _, delivery := range sender.Deliveries(test.name, test.limit) {
+			ids = append(ids, delivery.Id)
+		}
+		if !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("Deliveries(%q, %d) = %v, want %v", test.name, test.limit, ids, test.ids)
+		}
+	}
+}
+
+func TestWebhookWants(t *testing.T) {
+	tests := []struct {
+		events []string
+		typ    string
+		wants  bool
+	}{
+		{nil, EventLightChanged, true},
+		{nil, EventPing, false},
+		{[]string{EventSceneRecalled}, EventSceneRecalled, true},
+		{[]string{EventSceneRecalled}, EventLightChanged, false},
+	}
+	for _, test := range tests {
+		if wants := (Webhook{Events: test.events}).Wants(test.typ); wants != test.wants {
+			t.Errorf("webhook for %v wants %s: %t, want %t", test.events, test.typ, wants, test.wants)
+		}
+	}
+}